@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/goccy/go-json"
+	"github.com/mr-tron/base58"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/TBD54566975/did-dht/config"
+	"github.com/TBD54566975/did-dht/internal"
+	"github.com/TBD54566975/did-dht/internal/cli"
+	"github.com/TBD54566975/did-dht/internal/did"
+	"github.com/TBD54566975/did-dht/internal/util"
+	"github.com/TBD54566975/did-dht/pkg/dht"
+)
+
+var gatewayURL string
+
+func init() {
+	rootCmd.AddCommand(keyCmd)
+	keyCmd.AddCommand(keyPublishCmd)
+	keyCmd.AddCommand(keyResolveCmd)
+	keyCmd.PersistentFlags().StringVar(&gatewayURL, "gateway", "", "did:dht gateway to use instead of the DHT")
+}
+
+var keyCmd = &cobra.Command{
+	Use:   "key",
+	Short: "Manage did:key and did:jwk identities published through did:dht",
+	Long:  `Manage Ed25519 did:key and did:jwk identities published through did:dht https://did-dht.com/registry/#interoperable-did-methods.`,
+}
+
+var keyPublishCmd = &cobra.Command{
+	Use:   "publish",
+	Short: "Create a did:key and publish its DID Document to the DHT",
+	Long:  `Create a did:key and publish its DID Document to the DHT, printing the did:jwk sharing its key.`,
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		pubKey, privKey, err := util.GenerateKeypair()
+		if err != nil {
+			logrus.WithError(err).Error("failed to generate keypair")
+			return err
+		}
+
+		// the document is published under the did:dht identifier sharing the did:key's identity key
+		doc, err := did.CreateDIDDHTDID(pubKey, did.CreateDIDDHTOpts{})
+		if err != nil {
+			logrus.WithError(err).Error("failed to create did document")
+			return err
+		}
+		dhtID := did.DHT(doc.ID)
+		keyID, err := did.KeyFromDHT(dhtID)
+		if err != nil {
+			logrus.WithError(err).Error("failed to create did:key")
+			return err
+		}
+		jwkID, err := did.JWKFromDHT(dhtID)
+		if err != nil {
+			logrus.WithError(err).Error("failed to create did:jwk")
+			return err
+		}
+		packet, err := dhtID.ToDNSPacket(*doc, nil, nil, nil)
+		if err != nil {
+			logrus.WithError(err).Error("failed to create dns packet")
+			return err
+		}
+		putReq, err := dht.CreateDNSPublishRequest(privKey, *packet)
+		if err != nil {
+			logrus.WithError(err).Error("failed to create put request")
+			return err
+		}
+
+		if gatewayURL != "" {
+			client, err := did.NewGatewayClient(gatewayURL)
+			if err != nil {
+				logrus.WithError(err).Error("failed to create gateway client")
+				return err
+			}
+			if err = client.PutDocument(context.Background(), doc.ID, *putReq); err != nil {
+				logrus.WithError(err).Error("failed to publish did:key to gateway")
+				return err
+			}
+		} else {
+			d, err := dht.NewDHT(config.GetDefaultBootstrapPeers())
+			if err != nil {
+				logrus.WithError(err).Error("failed to create dht")
+				return err
+			}
+			if _, err = d.Put(context.Background(), *putReq); err != nil {
+				logrus.WithError(err).Error("failed to put did:key into dht")
+				return err
+			}
+		}
+
+		// write the identity to the diddht file
+		identity := internal.Identity{
+			Base58PublicKey:  base58.Encode(pubKey),
+			Base58PrivateKey: base58.Encode(privKey),
+		}
+		if err = cli.Write(keyID, identity); err != nil {
+			logrus.WithError(err).Error("failed to write identity to diddht file")
+			return err
+		}
+
+		fmt.Printf("Published did:key: %s, as: %s, also resolvable as: %s\n", keyID, dhtID, jwkID)
+		return nil
+	},
+}
+
+var keyResolveCmd = &cobra.Command{
+	Use:   "resolve",
+	Short: "Resolve a did:key or did:jwk through did:dht",
+	Long:  `Resolve a did:key or did:jwk through did:dht, falling back to expanding its key if nothing is published for it.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id := args[0]
+		if !did.IsInterop(id) {
+			return fmt.Errorf("not a did:key or did:jwk: %s", id)
+		}
+
+		var source did.ResolutionSource
+		if gatewayURL != "" {
+			client, err := did.NewGatewayClient(gatewayURL)
+			if err != nil {
+				logrus.WithError(err).Error("failed to create gateway client")
+				return err
+			}
+			source = client
+		} else {
+			d, err := dht.NewDHT(config.GetDefaultBootstrapPeers())
+			if err != nil {
+				logrus.WithError(err).Error("failed to create dht")
+				return err
+			}
+			source = dhtSource{did.NewDHTResolutionSource(d)}
+		}
+
+		result := did.NewResolver(source).Resolve(context.Background(), id)
+		resultJSON, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			logrus.WithError(err).Error("failed to marshal resolution result")
+			return err
+		}
+		fmt.Println(string(resultJSON))
+		if result.ResolutionMetadata.Error != "" {
+			return fmt.Errorf("failed to resolve %s: %s", id, result.ResolutionMetadata.ErrorMessage)
+		}
+		return nil
+	},
+}
+
+// dhtSource treats a failure to find a record in the DHT as the record not being found, so a did:key or did:jwk
+// with nothing published falls back to its expansion
+type dhtSource struct {
+	did.DHTResolutionSource
+}
+
+func (s dhtSource) GetResolutionRecord(ctx context.Context, id did.DHT) (*did.ResolutionRecord, error) {
+	record, err := s.DHTResolutionSource.GetResolutionRecord(ctx, id)
+	if err != nil {
+		logrus.WithError(err).Debug("record not found in dht")
+		return nil, nil
+	}
+	return record, nil
+}
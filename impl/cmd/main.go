@@ -27,6 +27,11 @@ import (
 //	@contact.email	tbd-developer@squareup.com
 //	@license.name	Apache 2.0
 //	@license.url	http://www.apache.org/licenses/LICENSE-2.0.html
+//
+//	@securityDefinitions.apikey	BearerAuth
+//	@in							header
+//	@name						Authorization
+//	@description				Admin token as "Bearer <token>"
 func main() {
 	logrus.SetFormatter(&logrus.JSONFormatter{})
 	logrus.SetReportCaller(true)
@@ -54,10 +59,12 @@ func run() error {
 	}
 
 	// set up telemetry
-	if cfg.ServerConfig.Telemetry {
-		// add trace hook to logrus
-		logrus.AddHook(&int.TraceHook{})
-		if err = telemetry.SetupTelemetry(ctx); err != nil {
+	if cfg.ServerConfig.Telemetry || cfg.ServerConfig.Metrics {
+		if cfg.ServerConfig.Telemetry {
+			// add trace hook to logrus
+			logrus.AddHook(&int.TraceHook{})
+		}
+		if err = telemetry.SetupTelemetry(ctx, cfg); err != nil {
 			logrus.WithContext(ctx).WithError(err).Fatal("error initializing telemetry")
 		}
 		defer telemetry.Shutdown(ctx)
@@ -20,6 +20,9 @@ const (
 	DefaultEnvPath    = "config/config.env"
 	Extension         = ".toml"
 
+	// MinDifficulty is the lowest retention challenge difficulty allowed by the spec https://did-dht.com/#retained-did-set
+	MinDifficulty = 26
+
 	EnvironmentDev  Environment = "dev"
 	EnvironmentTest Environment = "test"
 	EnvironmentProd Environment = "prod"
@@ -29,6 +32,8 @@ const (
 	BootstrapPeers EnvironmentVariable = "BOOTSTRAP_PEERS"
 	StorageURI     EnvironmentVariable = "STORAGE_URI"
 	LogLevel       EnvironmentVariable = "LOG_LEVEL"
+	// AdminToken The bearer token required by the admin API.
+	AdminToken EnvironmentVariable = "ADMIN_TOKEN"
 )
 
 type (
@@ -41,9 +46,11 @@ func (e EnvironmentVariable) String() string {
 }
 
 type Config struct {
-	Log          LogConfig        `toml:"log"`
-	ServerConfig ServerConfig     `toml:"server"`
-	DHTConfig    DHTServiceConfig `toml:"dht"`
+	Log             LogConfig        `toml:"log"`
+	ServerConfig    ServerConfig     `toml:"server"`
+	DHTConfig       DHTServiceConfig `toml:"dht"`
+	RetentionConfig RetentionConfig  `toml:"retention"`
+	TelemetryConfig TelemetryConfig  `toml:"telemetry"`
 }
 
 type ServerConfig struct {
@@ -53,13 +60,107 @@ type ServerConfig struct {
 	BaseURL     string      `toml:"base_url"`
 	StorageURI  string      `toml:"storage_uri"`
 	Telemetry   bool        `toml:"telemetry"`
+	Metrics     bool        `toml:"metrics"`
+	// AdminToken is the bearer token required by the /admin API, which is disabled if empty. Prefer setting it with
+	// the ADMIN_TOKEN environment variable.
+	AdminToken string `toml:"admin_token"`
+	// TrustedProxies are the IPs or CIDRs of proxies trusted to report the client IP in the X-Forwarded-For and
+	// X-Real-IP headers, empty to use the IP of the connection
+	TrustedProxies []string        `toml:"trusted_proxies"`
+	RateLimit      RateLimitConfig `toml:"rate_limit"`
+}
+
+// RateLimitConfig configures token bucket rate limits on the DHT, DID, and challenge APIs, per client IP and per record
+type RateLimitConfig struct {
+	Enabled bool `toml:"enabled"`
+	// ReadsPerSecond and ReadBurst limit each client's requests which get records, DIDs, and challenges, zero for no
+	// limit
+	ReadsPerSecond float64 `toml:"reads_per_second"`
+	ReadBurst      int     `toml:"read_burst"`
+	// WritesPerSecond and WriteBurst limit each client's requests which put or delete records, zero for no limit
+	WritesPerSecond float64 `toml:"writes_per_second"`
+	WriteBurst      int     `toml:"write_burst"`
+	// RecordWriteIntervalMinutes is the interval at which each record may be updated, from any client, once its burst
+	// of RecordWriteBurst updates is used, zero for no limit. Only valid new versions of the record which are stored
+	// count as updates. The spec recommends updating at most every 2 hours.
+	RecordWriteIntervalMinutes int `toml:"record_write_interval_minutes"`
+	RecordWriteBurst           int `toml:"record_write_burst"`
 }
 
 type DHTServiceConfig struct {
-	BootstrapPeers   []string `toml:"bootstrap_peers"`
-	RepublishCRON    string   `toml:"republish_cron"`
-	CacheTTLSeconds  int      `toml:"cache_ttl_seconds"`
-	CacheSizeLimitMB int      `toml:"cache_size_limit_mb"`
+	BootstrapPeers []string `toml:"bootstrap_peers"`
+	// RepublishCRON is the schedule on which stored records are reconciled with the republish schedule
+	RepublishCRON string `toml:"republish_cron"`
+	// RepublishIntervalMinutes is the number of minutes between republishes of each record
+	RepublishIntervalMinutes int `toml:"republish_interval_minutes"`
+	// RepublishConcurrency is the number of records republished at once, zero to derive it from the DHT's send limiter
+	RepublishConcurrency int `toml:"republish_concurrency"`
+	// RetryBaseDelaySeconds is the delay before a record which failed to be put into the DHT is first retried, doubling
+	// with each consecutive failure
+	RetryBaseDelaySeconds int `toml:"retry_base_delay_seconds"`
+	// RetryMaxDelayMinutes caps the delay between retries of a record
+	RetryMaxDelayMinutes int `toml:"retry_max_delay_minutes"`
+	// RetryDeadLetterAttempts is the number of consecutive failures after which a record is dead-lettered and no longer
+	// retried, zero to retry indefinitely
+	RetryDeadLetterAttempts int `toml:"retry_dead_letter_attempts"`
+	// OutboxDrainTimeoutSeconds is how long shutdown waits for queued records to be put into the DHT, the remaining
+	// records are put on restart
+	OutboxDrainTimeoutSeconds int `toml:"outbox_drain_timeout_seconds"`
+	CacheTTLSeconds           int `toml:"cache_ttl_seconds"`
+	CacheSizeLimitMB          int `toml:"cache_size_limit_mb"`
+	// HistoryLimit is the number of sequence numbers kept per DID for historical resolution, zero for no limit
+	HistoryLimit int `toml:"history_limit"`
+	// TombstoneDays is the number of days after a record is deleted that its key is not stored, cached, or republished
+	// again, zero to never store it again
+	TombstoneDays int `toml:"tombstone_days"`
+}
+
+// RetentionConfig configures the Retained DID Set https://did-dht.com/#retained-did-set
+type RetentionConfig struct {
+	Enabled bool `toml:"enabled"`
+	// Difficulty is the base number of leading zero bits a Retention Solution must have, no less than MinDifficulty
+	Difficulty int `toml:"difficulty"`
+	// MaxDifficulty is the highest difficulty the gateway raises to as it nears capacity, no less than Difficulty, zero
+	// for no maximum
+	MaxDifficulty int `toml:"max_difficulty"`
+	// RecordCapacity is the number of stored records at which retention is temporarily disabled, zero for no limit
+	RecordCapacity int `toml:"record_capacity"`
+	// WriteRateCapacity is the number of writes per minute at which retention is temporarily disabled, zero for no limit
+	WriteRateCapacity int `toml:"write_rate_capacity"`
+	// ExpiryDays is the number of days a DID is retained after a valid Retention Solution is accepted
+	ExpiryDays int `toml:"expiry_days"`
+	// RepublishUnretained controls whether DIDs that have never been added to the set, or whose retention has expired
+	// and are not purged, are republished
+	RepublishUnretained bool `toml:"republish_unretained"`
+	// PurgeExpired controls whether DIDs are deleted from storage once their retention has expired
+	PurgeExpired bool `toml:"purge_expired"`
+	// HashSource is the source of challenge hashes, either "random" or "bitcoin"
+	HashSource string `toml:"hash_source"`
+	// BitcoinBlockHashURL is the URL of a provider returning the most recent Bitcoin block hash as plain text
+	BitcoinBlockHashURL string `toml:"bitcoin_block_hash_url"`
+	// ChallengeRotationMinutes is the number of minutes after which the challenge hash is rotated
+	ChallengeRotationMinutes int `toml:"challenge_rotation_minutes"`
+}
+
+// TelemetryConfig configures how traces and metrics are exported when telemetry is enabled
+type TelemetryConfig struct {
+	// Exporter is where traces and metrics are exported, one of "otlp-http", "otlp-grpc", "stdout", or "file"
+	Exporter string `toml:"exporter"`
+	// Endpoint is the host and port of the OTLP collector, empty to use the OTEL_EXPORTER_OTLP_ENDPOINT environment
+	// variable
+	Endpoint string `toml:"endpoint"`
+	// Insecure connects to the OTLP collector without TLS
+	Insecure bool `toml:"insecure"`
+	// FilePath is the file traces and metrics are appended to by the file exporter
+	FilePath string `toml:"file_path"`
+	// SampleRatio is the fraction of traces sampled, between 0 and 1, sampling every trace if it is not set. Spans
+	// whose parent was sampled are always sampled.
+	SampleRatio *float64 `toml:"sample_ratio"`
+	// MetricIntervalSeconds is the interval at which metrics are exported
+	MetricIntervalSeconds int `toml:"metric_interval_seconds"`
+	// ResourceAttributes are added to the attributes describing the gateway, which include its service name, version,
+	// and environment
+	ResourceAttributes map[string]string `toml:"resource_attributes"`
 }
 
 type LogConfig struct {
@@ -75,12 +176,48 @@ func GetDefaultConfig() Config {
 			BaseURL:     "http://localhost:8305",
 			StorageURI:  "bolt://diddht.db",
 			Telemetry:   false,
+			Metrics:     true,
+			RateLimit: RateLimitConfig{
+				Enabled:                    true,
+				ReadsPerSecond:             10,
+				ReadBurst:                  50,
+				WritesPerSecond:            1,
+				WriteBurst:                 10,
+				RecordWriteIntervalMinutes: 120,
+				RecordWriteBurst:           5,
+			},
 		},
 		DHTConfig: DHTServiceConfig{
-			BootstrapPeers:   GetDefaultBootstrapPeers(),
-			RepublishCRON:    "0 */3 * * *",
-			CacheTTLSeconds:  600,
-			CacheSizeLimitMB: 1000,
+			BootstrapPeers:            GetDefaultBootstrapPeers(),
+			RepublishCRON:             "0 */3 * * *",
+			RepublishIntervalMinutes:  120,
+			RepublishConcurrency:      0,
+			RetryBaseDelaySeconds:     60,
+			RetryMaxDelayMinutes:      60,
+			RetryDeadLetterAttempts:   10,
+			OutboxDrainTimeoutSeconds: 10,
+			CacheTTLSeconds:           600,
+			CacheSizeLimitMB:          1000,
+			HistoryLimit:              100,
+			TombstoneDays:             30,
+		},
+		RetentionConfig: RetentionConfig{
+			Enabled:                  true,
+			Difficulty:               MinDifficulty,
+			MaxDifficulty:            32,
+			RecordCapacity:           0,
+			WriteRateCapacity:        0,
+			ExpiryDays:               7,
+			RepublishUnretained:      true,
+			PurgeExpired:             false,
+			HashSource:               "random",
+			BitcoinBlockHashURL:      "https://blockstream.info/api/blocks/tip/hash",
+			ChallengeRotationMinutes: 10,
+		},
+		TelemetryConfig: TelemetryConfig{
+			Exporter:              "otlp-http",
+			FilePath:              "telemetry.json",
+			MetricIntervalSeconds: 60,
 		},
 		Log: LogConfig{
 			Level: logrus.DebugLevel.String(),
@@ -114,9 +251,31 @@ func LoadConfig(path string) (*Config, error) {
 		return nil, errors.Wrap(err, "apply env variables")
 	}
 
+	if err = validateConfig(cfg); err != nil {
+		return nil, errors.Wrap(err, "invalid config")
+	}
+
 	return &cfg, nil
 }
 
+// validateConfig checks the values which are not enforced where they are used
+func validateConfig(cfg Config) error {
+	if ratio := cfg.TelemetryConfig.SampleRatio; ratio != nil && (*ratio < 0 || *ratio > 1) {
+		return fmt.Errorf("telemetry sample ratio %v is not between 0 and 1", *ratio)
+	}
+
+	retention := cfg.RetentionConfig
+	if retention.Enabled {
+		if retention.Difficulty < MinDifficulty {
+			return fmt.Errorf("retention difficulty %d is less than the minimum of %d", retention.Difficulty, MinDifficulty)
+		}
+		if retention.MaxDifficulty != 0 && retention.MaxDifficulty < retention.Difficulty {
+			return fmt.Errorf("retention max difficulty %d is less than the difficulty %d", retention.MaxDifficulty, retention.Difficulty)
+		}
+	}
+	return nil
+}
+
 func checkValidConfigPath(path string) (bool, error) {
 	// no path, load default config
 	defaultConfig := false
@@ -157,6 +316,11 @@ func applyEnvVariables(cfg *Config) error {
 		cfg.ServerConfig.StorageURI = storage
 	}
 
+	adminToken, present := os.LookupEnv(AdminToken.String())
+	if present {
+		cfg.ServerConfig.AdminToken = adminToken
+	}
+
 	levelString, present := os.LookupEnv(LogLevel.String())
 	if present {
 		_, err := logrus.ParseLevel(levelString)
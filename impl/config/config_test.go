@@ -0,0 +1,67 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig(t *testing.T) {
+	t.Run("test default config", func(t *testing.T) {
+		cfg, err := LoadConfig("")
+		require.NoError(t, err)
+		assert.Equal(t, GetDefaultConfig(), *cfg)
+	})
+
+	t.Run("test config file", func(t *testing.T) {
+		// tests run from this directory
+		cfg, err := LoadConfig("config.toml")
+		require.NoError(t, err)
+		assert.Equal(t, MinDifficulty, cfg.RetentionConfig.Difficulty)
+	})
+
+	t.Run("test invalid retention difficulty", func(t *testing.T) {
+		for name, retention := range map[string]string{
+			"below minimum":         "enabled = true\ndifficulty = 20\nmax_difficulty = 32",
+			"max below difficulty":  "enabled = true\ndifficulty = 28\nmax_difficulty = 27",
+			"below minimum, no max": "enabled = true\ndifficulty = 25\nmax_difficulty = 0",
+		} {
+			path := filepath.Join(t.TempDir(), "config.toml")
+			require.NoError(t, os.WriteFile(path, []byte("[retention]\n"+retention), 0600))
+			_, err := LoadConfig(path)
+			assert.ErrorContains(t, err, "invalid config", name)
+		}
+
+		// difficulty is not checked when retention is disabled, and zero is no maximum
+		for _, retention := range []string{"enabled = false\ndifficulty = 0", "enabled = true\ndifficulty = 26\nmax_difficulty = 0"} {
+			path := filepath.Join(t.TempDir(), "config.toml")
+			require.NoError(t, os.WriteFile(path, []byte("[retention]\n"+retention), 0600))
+			_, err := LoadConfig(path)
+			assert.NoError(t, err, retention)
+		}
+	})
+	t.Run("test telemetry sample ratio", func(t *testing.T) {
+		for _, telemetry := range []string{"sample_ratio = -0.1", "sample_ratio = 1.5"} {
+			path := filepath.Join(t.TempDir(), "config.toml")
+			require.NoError(t, os.WriteFile(path, []byte("[telemetry]\n"+telemetry), 0600))
+			_, err := LoadConfig(path)
+			assert.ErrorContains(t, err, "invalid config", telemetry)
+		}
+
+		// zero samples no traces, and an unset ratio samples every trace
+		path := filepath.Join(t.TempDir(), "config.toml")
+		require.NoError(t, os.WriteFile(path, []byte("[telemetry]\nsample_ratio = 0"), 0600))
+		cfg, err := LoadConfig(path)
+		require.NoError(t, err)
+		require.NotNil(t, cfg.TelemetryConfig.SampleRatio)
+		assert.Zero(t, *cfg.TelemetryConfig.SampleRatio)
+
+		require.NoError(t, os.WriteFile(path, []byte("[telemetry]\nexporter = \"stdout\""), 0600))
+		cfg, err = LoadConfig(path)
+		require.NoError(t, err)
+		assert.Nil(t, cfg.TelemetryConfig.SampleRatio)
+	})
+}
@@ -0,0 +1,8 @@
+package dht
+
+import (
+	"github.com/pkg/errors"
+)
+
+// ErrValueNotFound is returned when a lookup in the DHT finishes without any node returning a value for the key
+var ErrValueNotFound = errors.New("value not found")
@@ -0,0 +1,191 @@
+package did
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/anacrolix/dht/v2/exts/getput"
+	"github.com/anacrolix/torrent/bencode"
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+
+	dhtint "github.com/TBD54566975/did-dht/internal/dht"
+)
+
+// DHTResolutionSourceName is the source reported for records resolved directly from the DHT
+const DHTResolutionSourceName = "dht"
+
+// FullGetter gets mutable items from the DHT, such as a pkg/dht DHT, returning an error wrapping
+// dhtint.ErrValueNotFound if no node has the item
+type FullGetter interface {
+	GetFull(ctx context.Context, key string) (*getput.GetResult, error)
+}
+
+// DHTResolutionSource resolves records directly from the DHT
+type DHTResolutionSource struct {
+	dht FullGetter
+}
+
+// NewDHTResolutionSource returns a new resolution source backed by the given DHT
+func NewDHTResolutionSource(d FullGetter) DHTResolutionSource {
+	return DHTResolutionSource{dht: d}
+}
+
+// GetResolutionRecord gets the record for a DID from the DHT, or nil if no node has a record for the DID
+func (s DHTResolutionSource) GetResolutionRecord(ctx context.Context, id DHT) (*ResolutionRecord, error) {
+	suffix, err := id.Suffix()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get suffix")
+	}
+	got, err := s.dht.GetFull(ctx, suffix)
+	if err != nil {
+		if errors.Is(err, dhtint.ErrValueNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	bBytes, err := got.V.MarshalBencode()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal bencoded payload")
+	}
+	var payload string
+	if err = bencode.Unmarshal(bBytes, &payload); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal bencoded payload")
+	}
+	return &ResolutionRecord{
+		V:      []byte(payload),
+		Seq:    got.Seq,
+		Source: DHTResolutionSourceName,
+	}, nil
+}
+
+// AuthoritativeResolutionSource resolves records from a set of sources and then from the authoritative gateways
+// named in the NS records of the record they return, returning the record with the highest sequence number
+// https://did-dht.com/#read
+type AuthoritativeResolutionSource struct {
+	sources []ResolutionSource
+	// newGatewaySource returns a source for an authoritative gateway's URL
+	newGatewaySource func(gatewayURL string) (ResolutionSource, error)
+}
+
+// NewAuthoritativeResolutionSource returns a new resolution source which queries the given sources, such as a
+// gateway client or the DHT, and then the authoritative gateways of the DID
+func NewAuthoritativeResolutionSource(sources ...ResolutionSource) *AuthoritativeResolutionSource {
+	return &AuthoritativeResolutionSource{
+		sources: sources,
+		newGatewaySource: func(gatewayURL string) (ResolutionSource, error) {
+			return NewGatewayClient(gatewayURL)
+		},
+	}
+}
+
+// WithGatewayClientOptions configures the clients used to query authoritative gateways
+func (a *AuthoritativeResolutionSource) WithGatewayClientOptions(opts ...GatewayClientOption) *AuthoritativeResolutionSource {
+	a.newGatewaySource = func(gatewayURL string) (ResolutionSource, error) {
+		return NewGatewayClient(gatewayURL, opts...)
+	}
+	return a
+}
+
+// GetResolutionRecord returns the record with the highest sequence number for the DID from its sources and its
+// authoritative gateways, or nil if no source has a record for the DID. Errors from individual sources are returned if
+// no source has a record, and otherwise are reported in the record's SourceErrors, including any *VerificationError
+// from a misbehaving gateway.
+func (a *AuthoritativeResolutionSource) GetResolutionRecord(ctx context.Context, id DHT) (*ResolutionRecord, error) {
+	highest, errs := resolveHighest(ctx, id, a.sources)
+	if highest == nil {
+		if len(errs) > 0 {
+			return nil, errors.Wrapf(errs[0], "failed to resolve from %d of %d sources", len(errs), len(a.sources))
+		}
+		return nil, nil
+	}
+	record := *highest
+	record.SourceErrors = append(record.SourceErrors, errs...)
+
+	// repeat resolution against the authoritative gateways of the DID, skipping any already queried
+	queried := make(map[string]bool)
+	for _, source := range a.sources {
+		if client, ok := source.(*GatewayClient); ok {
+			queried[client.gatewayURL] = true
+		}
+	}
+	gateways, err := authoritativeGateways(id, record)
+	if err != nil {
+		record.SourceErrors = append(record.SourceErrors, errors.Wrap(err, "failed to read authoritative gateways"))
+		return &record, nil
+	}
+	var gatewaySources []ResolutionSource
+	for _, gateway := range gateways {
+		gatewayURL := authoritativeGatewayURL(gateway)
+		if queried[gatewayURL] {
+			continue
+		}
+		queried[gatewayURL] = true
+		source, err := a.newGatewaySource(gatewayURL)
+		if err != nil {
+			record.SourceErrors = append(record.SourceErrors, errors.Wrapf(err, "invalid authoritative gateway: %s", gatewayURL))
+			continue
+		}
+		gatewaySources = append(gatewaySources, source)
+	}
+	gatewayRecord, gatewayErrs := resolveHighest(ctx, id, gatewaySources)
+	sourceErrs := append(record.SourceErrors, gatewayErrs...)
+	if gatewayRecord != nil && gatewayRecord.Seq > record.Seq {
+		record = *gatewayRecord
+	}
+	record.SourceErrors = sourceErrs
+	return &record, nil
+}
+
+// resolveHighest queries the sources concurrently, returning the record with the highest sequence number, preferring
+// earlier sources on ties, along with the errors from any sources which failed
+func resolveHighest(ctx context.Context, id DHT, sources []ResolutionSource) (*ResolutionRecord, []error) {
+	records := make([]*ResolutionRecord, len(sources))
+	errs := make([]error, len(sources))
+
+	var wg sync.WaitGroup
+	for i, source := range sources {
+		wg.Add(1)
+		go func(i int, source ResolutionSource) {
+			defer wg.Done()
+			records[i], errs[i] = source.GetResolutionRecord(ctx, id)
+		}(i, source)
+	}
+	wg.Wait()
+
+	var highest *ResolutionRecord
+	var failed []error
+	for i, record := range records {
+		if errs[i] != nil {
+			failed = append(failed, errs[i])
+			continue
+		}
+		if record != nil && (highest == nil || record.Seq > highest.Seq) {
+			highest = record
+		}
+	}
+	return highest, failed
+}
+
+// authoritativeGateways returns the authoritative gateways named in the record's NS records
+func authoritativeGateways(id DHT, record ResolutionRecord) ([]AuthoritativeGateway, error) {
+	msg := new(dns.Msg)
+	if err := msg.Unpack(record.V); err != nil {
+		return nil, err
+	}
+	doc, err := id.FromDNSPacket(msg)
+	if err != nil {
+		return nil, err
+	}
+	return doc.Gateways, nil
+}
+
+// authoritativeGatewayURL returns the URL of an authoritative gateway from the domain name in its NS record
+func authoritativeGatewayURL(gateway AuthoritativeGateway) string {
+	gatewayURL := strings.TrimSuffix(string(gateway), ".")
+	if !strings.HasPrefix(gatewayURL, "http://") && !strings.HasPrefix(gatewayURL, "https://") {
+		gatewayURL = "https://" + gatewayURL
+	}
+	return strings.TrimSuffix(gatewayURL, "/")
+}
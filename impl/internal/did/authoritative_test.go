@@ -0,0 +1,162 @@
+package did
+
+import (
+	"context"
+	"testing"
+
+	"github.com/anacrolix/dht/v2/exts/getput"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	dhtint "github.com/TBD54566975/did-dht/internal/dht"
+)
+
+func TestAuthoritativeResolutionSource(t *testing.T) {
+	ctx := context.Background()
+
+	_, doc, err := GenerateDIDDHT(CreateDIDDHTOpts{})
+	require.NoError(t, err)
+	didID := DHT(doc.ID)
+
+	newRecord := func(t *testing.T, seq int64, source string, gateways ...AuthoritativeGateway) ResolutionRecord {
+		packet, err := didID.ToDNSPacket(*doc, nil, gateways, nil)
+		require.NoError(t, err)
+		v, err := packet.Pack()
+		require.NoError(t, err)
+		return ResolutionRecord{V: v, Seq: seq, Source: source}
+	}
+	sourceFor := func(record ResolutionRecord) ResolutionSource {
+		return stubResolutionSource{records: map[DHT]ResolutionRecord{didID: record}}
+	}
+	failing := stubResolutionSource{err: errors.New("unavailable")}
+	empty := stubResolutionSource{}
+
+	t.Run("test highest sequence number wins", func(t *testing.T) {
+		source := NewAuthoritativeResolutionSource(
+			sourceFor(newRecord(t, 1, "https://a.example")),
+			sourceFor(newRecord(t, 3, DHTResolutionSourceName)),
+			sourceFor(newRecord(t, 2, "https://b.example")),
+			failing,
+			empty,
+		)
+		record, err := source.GetResolutionRecord(ctx, didID)
+		assert.NoError(t, err)
+		require.NotNil(t, record)
+		assert.Equal(t, int64(3), record.Seq)
+		assert.Equal(t, DHTResolutionSourceName, record.Source)
+		assert.Equal(t, []error{failing.err}, record.SourceErrors)
+	})
+
+	t.Run("test authoritative gateways are followed", func(t *testing.T) {
+		var queried []string
+		source := NewAuthoritativeResolutionSource(
+			sourceFor(newRecord(t, 1, "https://a.example", "gw1.example.", "gw2.example.", "gw1.example.")),
+		)
+		source.newGatewaySource = func(gatewayURL string) (ResolutionSource, error) {
+			queried = append(queried, gatewayURL)
+			switch gatewayURL {
+			case "https://gw1.example":
+				return sourceFor(newRecord(t, 5, gatewayURL)), nil
+			default:
+				return failing, nil
+			}
+		}
+
+		record, err := source.GetResolutionRecord(ctx, didID)
+		assert.NoError(t, err)
+		require.NotNil(t, record)
+		assert.Equal(t, int64(5), record.Seq)
+		assert.Equal(t, "https://gw1.example", record.Source)
+		assert.Equal(t, []string{"https://gw1.example", "https://gw2.example"}, queried)
+		assert.Equal(t, []error{failing.err}, record.SourceErrors)
+	})
+
+	t.Run("test misbehaving authoritative gateways are reported", func(t *testing.T) {
+		verificationErr := &VerificationError{DID: didID, Gateway: "https://gw.example", Reason: "invalid signature"}
+		source := NewAuthoritativeResolutionSource(sourceFor(newRecord(t, 1, DHTResolutionSourceName, "gw.example.")))
+		source.newGatewaySource = func(gatewayURL string) (ResolutionSource, error) {
+			return stubResolutionSource{err: verificationErr}, nil
+		}
+
+		record, err := source.GetResolutionRecord(ctx, didID)
+		assert.NoError(t, err)
+		require.NotNil(t, record)
+		assert.Equal(t, DHTResolutionSourceName, record.Source)
+		require.Len(t, record.SourceErrors, 1)
+		var gotErr *VerificationError
+		require.ErrorAs(t, record.SourceErrors[0], &gotErr)
+		assert.Equal(t, "https://gw.example", gotErr.Gateway)
+
+		result := NewResolver(source).Resolve(ctx, doc.ID)
+		assert.Empty(t, result.ResolutionMetadata.Error)
+		assert.Equal(t, []string{verificationErr.Error()}, result.ResolutionMetadata.Warnings)
+	})
+
+	t.Run("test older authoritative gateway records are ignored", func(t *testing.T) {
+		source := NewAuthoritativeResolutionSource(sourceFor(newRecord(t, 5, DHTResolutionSourceName, "gw.example.")))
+		source.newGatewaySource = func(gatewayURL string) (ResolutionSource, error) {
+			return sourceFor(newRecord(t, 4, gatewayURL)), nil
+		}
+
+		record, err := source.GetResolutionRecord(ctx, didID)
+		assert.NoError(t, err)
+		require.NotNil(t, record)
+		assert.Equal(t, int64(5), record.Seq)
+		assert.Equal(t, DHTResolutionSourceName, record.Source)
+	})
+
+	t.Run("test not found and failures", func(t *testing.T) {
+		record, err := NewAuthoritativeResolutionSource(empty, empty).GetResolutionRecord(ctx, didID)
+		assert.NoError(t, err)
+		assert.Nil(t, record)
+
+		record, err = NewAuthoritativeResolutionSource(empty, failing).GetResolutionRecord(ctx, didID)
+		assert.Error(t, err)
+		assert.Nil(t, record)
+	})
+
+	t.Run("test records missing from the dht are not found", func(t *testing.T) {
+		notFound := NewDHTResolutionSource(stubFullGetter{err: errors.Wrap(dhtint.ErrValueNotFound, "failed to get key")})
+		record, err := notFound.GetResolutionRecord(ctx, didID)
+		assert.NoError(t, err)
+		assert.Nil(t, record)
+
+		result := NewResolver(NewAuthoritativeResolutionSource(empty, notFound)).Resolve(ctx, doc.ID)
+		assert.Equal(t, ResolutionErrorNotFound, result.ResolutionMetadata.Error)
+		assert.Empty(t, result.ResolutionMetadata.Warnings)
+
+		// a record held only by another source is resolved without a warning for the dht
+		source := NewAuthoritativeResolutionSource(sourceFor(newRecord(t, 1, "https://a.example")), notFound)
+		result = NewResolver(source).Resolve(ctx, doc.ID)
+		assert.Empty(t, result.ResolutionMetadata.Error)
+		assert.Empty(t, result.ResolutionMetadata.Warnings)
+
+		// other dht failures are still errors
+		record, err = NewDHTResolutionSource(stubFullGetter{err: context.DeadlineExceeded}).GetResolutionRecord(ctx, didID)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Nil(t, record)
+	})
+
+	t.Run("test resolution reports the source", func(t *testing.T) {
+		source := NewAuthoritativeResolutionSource(sourceFor(newRecord(t, 1, "https://a.example")))
+		result := NewResolver(source).Resolve(ctx, doc.ID)
+		assert.Empty(t, result.ResolutionMetadata.Error)
+		assert.Equal(t, "https://a.example", result.DocumentMetadata.Source)
+	})
+}
+
+func TestAuthoritativeGatewayURL(t *testing.T) {
+	assert.Equal(t, "https://gateway.example", authoritativeGatewayURL("gateway.example."))
+	assert.Equal(t, "https://gateway.example", authoritativeGatewayURL("gateway.example"))
+	assert.Equal(t, "http://localhost:8305", authoritativeGatewayURL("http://localhost:8305/"))
+}
+
+// stubFullGetter fails every get from the DHT with err
+type stubFullGetter struct {
+	err error
+}
+
+func (g stubFullGetter) GetFull(context.Context, string) (*getput.GetResult, error) {
+	return nil, g.err
+}
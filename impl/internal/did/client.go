@@ -2,38 +2,175 @@ package did
 
 import (
 	"bytes"
+	"context"
+	"encoding/base64"
 	"encoding/binary"
+	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/TBD54566975/ssi-sdk/did"
 	"github.com/anacrolix/dht/v2/bep44"
+	"github.com/anacrolix/torrent/bencode"
+	"github.com/goccy/go-json"
 	"github.com/miekg/dns"
 	"github.com/pkg/errors"
+	"github.com/tv42/zbase32"
 )
 
-// GatewayClient is the client for the Gateway API
+const (
+	defaultClientTimeout  = 10 * time.Second
+	defaultMaxRetries     = 3
+	defaultInitialBackoff = 250 * time.Millisecond
+	defaultMaxBackoff     = 5 * time.Second
+)
+
+// GatewayClient is the client for the Gateway API https://did-dht.com/#gateway-api
 type GatewayClient struct {
 	gatewayURL string
 	client     *http.Client
+
+	// maxRetries is the number of times a request is retried after a 429 or 5xx response
+	maxRetries     int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+}
+
+// GatewayClientOption configures a GatewayClient
+type GatewayClientOption func(*GatewayClient)
+
+// WithHTTPClient sets the HTTP client used to make requests, which defaults to a client with a 10 second timeout
+func WithHTTPClient(client *http.Client) GatewayClientOption {
+	return func(c *GatewayClient) {
+		c.client = client
+	}
+}
+
+// WithRetries sets the number of times a request is retried after a 429 or 5xx response, which defaults to 3.
+// Zero disables retries.
+func WithRetries(maxRetries int) GatewayClientOption {
+	return func(c *GatewayClient) {
+		c.maxRetries = maxRetries
+	}
+}
+
+// WithBackoff sets the delay before the first retry, which doubles for each following retry up to maxBackoff
+func WithBackoff(initialBackoff, maxBackoff time.Duration) GatewayClientOption {
+	return func(c *GatewayClient) {
+		c.initialBackoff = initialBackoff
+		c.maxBackoff = maxBackoff
+	}
 }
 
 // NewGatewayClient returns a new instance of the Gateway client
-func NewGatewayClient(gatewayURL string) (*GatewayClient, error) {
+func NewGatewayClient(gatewayURL string, opts ...GatewayClientOption) (*GatewayClient, error) {
 	if _, err := url.Parse(gatewayURL); err != nil {
 		return nil, err
 	}
-	client := http.DefaultClient
-	client.Timeout = time.Second * 10
-	return &GatewayClient{
-		gatewayURL: gatewayURL,
-		client:     client,
-	}, nil
+	c := &GatewayClient{
+		gatewayURL:     strings.TrimSuffix(gatewayURL, "/"),
+		client:         &http.Client{Timeout: defaultClientTimeout},
+		maxRetries:     defaultMaxRetries,
+		initialBackoff: defaultInitialBackoff,
+		maxBackoff:     defaultMaxBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.client == nil {
+		return nil, errors.New("http client cannot be nil")
+	}
+	return c, nil
+}
+
+// StatusError is returned when a did:dht Gateway responds with an unsuccessful status code
+type StatusError struct {
+	// Method is the HTTP method of the request
+	Method string
+	// URL is the URL of the request
+	URL string
+	// StatusCode is the status code of the gateway's response
+	StatusCode int
+	// Message is the error message sent by the gateway, if any
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	msg := fmt.Sprintf("%s %s failed with status code: %d", e.Method, e.URL, e.StatusCode)
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+// IsNotFound returns true if the error is a *StatusError for a resource the gateway does not have
+func IsNotFound(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound
+}
+
+// GatewayRecord is a BEP44 record from a did:dht Gateway, whose signature has been verified against the record's
+// key, which for a DID is its identity key
+type GatewayRecord struct {
+	// Seq is the verified sequence number of the record
+	Seq int64
+	// V is the raw payload of the record, the DNS packet of the DID
+	V []byte
+	// Sig is the signature over the sequence number and payload
+	Sig [64]byte
+	// Document is the DID document, types, and authoritative gateways decoded from the payload, if the record is
+	// for a DID
+	Document *DIDDHTDocument
+}
+
+// GetDHTRecord gets the BEP44 record for a z-base-32 encoded ed25519 public key from a did:dht Gateway, verifying
+// its signature against the key https://did-dht.com/#get-a-did
+// A *VerificationError is returned if the gateway's response cannot be verified.
+func (c *GatewayClient) GetDHTRecord(ctx context.Context, key string) (*GatewayRecord, error) {
+	publicKey, err := zbase32.DecodeString(key)
+	if err != nil || len(publicKey) != 32 {
+		return nil, errors.Errorf("invalid key: %s", key)
+	}
+	body, err := c.do(ctx, http.MethodGet, "/"+key, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.verifyRecord(DHT(Prefix+":"+key), publicKey, body)
+}
+
+// PutDHTRecord puts a BEP44 record for a z-base-32 encoded ed25519 public key to a did:dht Gateway
+// https://did-dht.com/#register-or-update-a-did
+func (c *GatewayClient) PutDHTRecord(ctx context.Context, key string, put bep44.Put) error {
+	v, ok := put.V.([]byte)
+	if !ok {
+		return errors.New("put value must be a byte slice")
+	}
+
+	// prepare request as sig:seq:v
+	var seqBuf [8]byte
+	binary.BigEndian.PutUint64(seqBuf[:], uint64(put.Seq))
+	reqBytes := append(put.Sig[:], append(seqBuf[:], v...)...)
+
+	_, err := c.do(ctx, http.MethodPut, "/"+key, "application/octet-stream", reqBytes)
+	return err
 }
 
 // GetDIDDocument gets a DID document, its types, and authoritative gateways, from a did:dht Gateway
-func (c *GatewayClient) GetDIDDocument(id string) (*DIDDHTDocument, error) {
+func (c *GatewayClient) GetDIDDocument(ctx context.Context, id string) (*DIDDHTDocument, error) {
+	record, err := c.GetDIDRecord(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return record.Document, nil
+}
+
+// GetDIDRecord gets a DID's record from a did:dht Gateway, verifying its signature before decoding it.
+// A *VerificationError is returned if the gateway's response cannot be verified.
+func (c *GatewayClient) GetDIDRecord(ctx context.Context, id string) (*GatewayRecord, error) {
 	d := DHT(id)
 	if !d.IsValid() {
 		return nil, errors.New("invalid did")
@@ -42,27 +179,18 @@ func (c *GatewayClient) GetDIDDocument(id string) (*DIDDHTDocument, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get suffix")
 	}
-	resp, err := http.Get(c.gatewayURL + "/" + suffix)
+	record, err := c.GetDHTRecord(ctx, suffix)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to get did document")
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return nil, errors.Errorf("failed to get did document, status code: %d", resp.StatusCode)
-	}
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to read response body")
+		return nil, err
 	}
-	msg := new(dns.Msg)
-	if err = msg.Unpack(body[72:]); err != nil {
-		return nil, errors.Wrap(err, "failed to unpack records")
+	if err = record.decode(d); err != nil {
+		return nil, err
 	}
-	return d.FromDNSPacket(msg)
+	return record, nil
 }
 
-// PutDocument puts a bep44.Put message to a did:dht Gateway
-func (c *GatewayClient) PutDocument(id string, put bep44.Put) error {
+// PutDocument puts a bep44.Put message for a DID to a did:dht Gateway
+func (c *GatewayClient) PutDocument(ctx context.Context, id string, put bep44.Put) error {
 	d := DHT(id)
 	if !d.IsValid() {
 		return errors.New("invalid did")
@@ -71,23 +199,332 @@ func (c *GatewayClient) PutDocument(id string, put bep44.Put) error {
 	if err != nil {
 		return errors.Wrap(err, "failed to get suffix")
 	}
+	return c.PutDHTRecord(ctx, suffix, put)
+}
 
-	// prepare request as sig:seq:v
-	var seqBuf [8]byte
-	binary.BigEndian.PutUint64(seqBuf[:], uint64(put.Seq))
-	reqBytes := append(put.Sig[:], append(seqBuf[:], put.V.([]byte)...)...)
+// DIDResponse is a did:dht Gateway's response to a DID resolution request https://did-dht.com/#resolving-a-did
+type DIDResponse struct {
+	// DID is the DID Document reconstructed by the gateway, which MUST NOT be trusted; use Record instead
+	DID did.Document `json:"did"`
+	// DHT is the unpadded base64URL-encoded BEP44 payload as 64 bytes sig, 8 bytes u64 big-endian seq, and v
+	DHT string `json:"dht"`
+	// Types is the list of indexed types for the DID, if any
+	Types []TypeIndex `json:"types,omitempty"`
+	// SequenceNumbers is a sorted list of seen sequence numbers for the DID
+	SequenceNumbers []int64 `json:"sequence_numbers,omitempty"`
+	// Expiry is the unix timestamp in seconds at which the DID will be evicted from the Retained DID Set
+	Expiry int64 `json:"expiry,omitempty"`
+	// Deactivated is true if the DID has been deactivated
+	Deactivated bool `json:"deactivated,omitempty"`
+
+	// Record is the verified record decoded from DHT, which is nil for a did:key or did:jwk with nothing published
+	Record *GatewayRecord `json:"-"`
+}
+
+// GetDID resolves a DID from a did:dht Gateway's DID API, verifying the record it returns
+func (c *GatewayClient) GetDID(ctx context.Context, id string) (*DIDResponse, error) {
+	return c.getDID(ctx, id, nil)
+}
 
-	req, err := http.NewRequest(http.MethodPut, c.gatewayURL+"/"+suffix, bytes.NewReader(reqBytes))
+// GetDIDAtSequence resolves a DID at a given sequence number from a did:dht Gateway's DID API, verifying the record
+// it returns https://did-dht.com/#historical-resolution
+func (c *GatewayClient) GetDIDAtSequence(ctx context.Context, id string, seq int64) (*DIDResponse, error) {
+	return c.getDID(ctx, id, &seq)
+}
+
+func (c *GatewayClient) getDID(ctx context.Context, id string, seq *int64) (*DIDResponse, error) {
+	d := DHT(id)
+	if !d.IsValid() {
+		return nil, errors.New("invalid did")
+	}
+	identityKey, err := d.IdentityKey()
 	if err != nil {
-		return errors.Wrap(err, "could not construct http put request")
+		return nil, errors.Wrap(err, "failed to get identity key")
+	}
+	path := "/did/" + url.PathEscape(id)
+	if seq != nil {
+		path += "?seq=" + strconv.FormatInt(*seq, 10)
 	}
-	resp, err := c.client.Do(req)
+	body, err := c.do(ctx, http.MethodGet, path, "", nil)
 	if err != nil {
-		return errors.Wrap(err, "could not put document")
+		return nil, err
+	}
+
+	var resp DIDResponse
+	if err = json.Unmarshal(body, &resp); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal did response")
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return errors.New("unsuccessful")
+	if resp.DHT == "" && IsInterop(id) && seq == nil {
+		// nothing is published for the DID, so it is expanded here rather than trusting the gateway's expansion
+		doc, err := d.Expand()
+		if err != nil {
+			return nil, err
+		}
+		resp.DID = *doc
+		return &resp, nil
 	}
+	payload, err := base64.RawURLEncoding.DecodeString(resp.DHT)
+	if err != nil {
+		return nil, &VerificationError{DID: d, Gateway: c.gatewayURL, Reason: "invalid dht payload"}
+	}
+	record, err := c.verifyRecord(d, identityKey, payload)
+	if err != nil {
+		return nil, err
+	}
+	if seq != nil && record.Seq != *seq {
+		return nil, &VerificationError{DID: d, Gateway: c.gatewayURL, Reason: fmt.Sprintf("requested seq %d but got %d", *seq, record.Seq)}
+	}
+	if err = record.decode(d); err != nil {
+		return nil, err
+	}
+	resp.Record = record
+	return &resp, nil
+}
+
+// PutDIDResponse is a did:dht Gateway's response to a DID registration or update request
+type PutDIDResponse struct {
+	// Expiry is the unix timestamp in seconds at which the DID will be evicted from the Retained DID Set, if retained
+	Expiry int64 `json:"expiry,omitempty"`
+}
+
+// PutDID registers or updates a DID with a did:dht Gateway's DID API, optionally adding it to the Retained DID Set
+// with a retention solution https://did-dht.com/#register-or-update-a-did
+func (c *GatewayClient) PutDID(ctx context.Context, id string, put bep44.Put, retentionSolution string) (*PutDIDResponse, error) {
+	d := DHT(id)
+	if !d.IsValid() {
+		return nil, errors.New("invalid did")
+	}
+	v, ok := put.V.([]byte)
+	if !ok {
+		return nil, errors.New("put value must be a byte slice")
+	}
+	reqBytes, err := json.Marshal(map[string]any{
+		"did":                id,
+		"sig":                base64.RawURLEncoding.EncodeToString(put.Sig[:]),
+		"seq":                put.Seq,
+		"v":                  base64.RawURLEncoding.EncodeToString(v),
+		"retention_solution": retentionSolution,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal put did request")
+	}
+	body, err := c.do(ctx, http.MethodPut, "/did/"+url.PathEscape(id), "application/json", reqBytes)
+	if err != nil {
+		return nil, err
+	}
+	var resp PutDIDResponse
+	if err = json.Unmarshal(body, &resp); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal put did response")
+	}
+	return &resp, nil
+}
+
+// ChallengeResponse is a did:dht Gateway's current retention challenge https://did-dht.com/#get-the-current-challenge
+type ChallengeResponse struct {
+	// Hash is the current hash which is to be used as input for computing a retention solution
+	Hash string `json:"hash"`
+	// HashSource is the source of the hash, e.g. bitcoin
+	HashSource string `json:"hash_source,omitempty"`
+	// Difficulty is the number of bits of leading zeros a retention solution must contain
+	Difficulty int `json:"difficulty"`
+	// Expiry is the unix timestamp in seconds at which the challenge expires
+	Expiry int64 `json:"expiry"`
+}
+
+// GetChallenge gets the current retention challenge from a did:dht Gateway
+func (c *GatewayClient) GetChallenge(ctx context.Context) (*ChallengeResponse, error) {
+	body, err := c.do(ctx, http.MethodGet, "/challenge", "", nil)
+	if err != nil {
+		return nil, err
+	}
+	var resp ChallengeResponse
+	if err = json.Unmarshal(body, &resp); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal challenge response")
+	}
+	return &resp, nil
+}
+
+// TypeDescription is a type indexed by a did:dht Gateway https://did-dht.com/#get-the-current-list-of-supported-types
+type TypeDescription struct {
+	// Type is the type index
+	Type TypeIndex `json:"type"`
+	// Description is the name of the type in the registry
+	Description string `json:"description"`
+}
+
+// ListTypes lists the types indexed by a did:dht Gateway
+func (c *GatewayClient) ListTypes(ctx context.Context) ([]TypeDescription, error) {
+	body, err := c.do(ctx, http.MethodGet, "/did/types", "", nil)
+	if err != nil {
+		return nil, err
+	}
+	var resp []TypeDescription
+	if err = json.Unmarshal(body, &resp); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal types response")
+	}
+	return resp, nil
+}
+
+// ListDIDsForType lists the DIDs a did:dht Gateway has indexed under a type, skipping offset DIDs and returning at
+// most limit DIDs https://did-dht.com/#get-a-given-type
+func (c *GatewayClient) ListDIDsForType(ctx context.Context, t TypeIndex, offset, limit int) ([]string, error) {
+	query := url.Values{}
+	query.Set("offset", strconv.Itoa(offset))
+	query.Set("limit", strconv.Itoa(limit))
+	body, err := c.do(ctx, http.MethodGet, fmt.Sprintf("/did/types/%d?%s", t, query.Encode()), "", nil)
+	if err != nil {
+		return nil, err
+	}
+	var resp []string
+	if err = json.Unmarshal(body, &resp); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal dids for type response")
+	}
+	return resp, nil
+}
+
+// ResolveDID resolves a DID from a did:dht Gateway to a DID Resolution result https://did-dht.com/#resolving-a-did
+func (c *GatewayClient) ResolveDID(ctx context.Context, id string) ResolutionResult {
+	return NewResolver(c).Resolve(ctx, id)
+}
+
+// GetResolutionRecord gets the verified record for a DID from a did:dht Gateway, or nil if the gateway does not have it
+func (c *GatewayClient) GetResolutionRecord(ctx context.Context, id DHT) (*ResolutionRecord, error) {
+	suffix, err := id.Suffix()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get suffix")
+	}
+	record, err := c.GetDHTRecord(ctx, suffix)
+	if err != nil {
+		if IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &ResolutionRecord{
+		V:       record.V,
+		Seq:     record.Seq,
+		Gateway: c.gatewayURL,
+		Source:  c.gatewayURL,
+	}, nil
+}
+
+// do sends a request to the gateway and returns the body of a successful response. Requests which receive a 429 or
+// 5xx response, other than 501, are retried with exponential backoff, honoring any Retry-After header. Unsuccessful
+// responses are returned as a *StatusError.
+func (c *GatewayClient) do(ctx context.Context, method, path, contentType string, body []byte) ([]byte, error) {
+	for attempt := 0; ; attempt++ {
+		var reqBody io.Reader
+		if body != nil {
+			reqBody = bytes.NewReader(body)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, c.gatewayURL+path, reqBody)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not construct http %s request", strings.ToLower(method))
+		}
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		resp, err := c.client.Do(req)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to %s %s", method, req.URL)
+		}
+		respBody, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read response body")
+		}
+		if resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices {
+			return respBody, nil
+		}
+
+		statusErr := &StatusError{
+			Method:     method,
+			URL:        req.URL.String(),
+			StatusCode: resp.StatusCode,
+			Message:    errorMessage(respBody),
+		}
+		if attempt >= c.maxRetries || !isRetryable(resp.StatusCode) {
+			return nil, statusErr
+		}
+		select {
+		case <-ctx.Done():
+			return nil, errors.Wrapf(ctx.Err(), "gave up retrying after: %s", statusErr)
+		case <-time.After(c.backoff(attempt, resp.Header.Get("Retry-After"))):
+		}
+	}
+}
+
+// backoff returns the delay before the given retry attempt, preferring the delay in seconds requested by the gateway
+func (c *GatewayClient) backoff(attempt int, retryAfter string) time.Duration {
+	if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds >= 0 {
+		return min(time.Duration(seconds)*time.Second, c.maxBackoff)
+	}
+	delay := c.initialBackoff
+	for i := 0; i < attempt && delay < c.maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, c.maxBackoff)
+}
+
+func isRetryable(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests ||
+		(statusCode >= http.StatusInternalServerError && statusCode != http.StatusNotImplemented)
+}
+
+// errorMessage returns the error message from a gateway error response, which is sent as a JSON string
+func errorMessage(body []byte) string {
+	var msg string
+	if err := json.Unmarshal(body, &msg); err == nil {
+		return msg
+	}
+	return strings.TrimSpace(string(body))
+}
+
+// verifyRecord parses a sig:seq:v payload for a DID and verifies it as a BEP44 record signed by the given key
+func (c *GatewayClient) verifyRecord(d DHT, publicKey []byte, payload []byte) (*GatewayRecord, error) {
+	if len(payload) < 72 {
+		return nil, &VerificationError{DID: d, Gateway: c.gatewayURL, Reason: "invalid response body"}
+	}
+	var record GatewayRecord
+	copy(record.Sig[:], payload[:64])
+	record.Seq = int64(binary.BigEndian.Uint64(payload[64:72]))
+	record.V = payload[72:]
+
+	bv, err := bencode.Marshal(record.V)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to bencode record payload")
+	}
+	if !bep44.Verify(publicKey, nil, record.Seq, bv, record.Sig[:]) {
+		return nil, &VerificationError{DID: d, Gateway: c.gatewayURL, Reason: "invalid signature"}
+	}
+	return &record, nil
+}
+
+// decode decodes the record's payload as the DNS packet of the given DID
+func (r *GatewayRecord) decode(id DHT) error {
+	msg := new(dns.Msg)
+	if err := msg.Unpack(r.V); err != nil {
+		return errors.Wrap(err, "failed to unpack records")
+	}
+	doc, err := id.FromDNSPacket(msg)
+	if err != nil {
+		return err
+	}
+	r.Document = doc
 	return nil
 }
+
+// VerificationError is returned when a record from a did:dht Gateway cannot be verified against the DID's identity
+// key, which means the gateway is misbehaving and should not be trusted https://did-dht.com/#read
+type VerificationError struct {
+	// DID is the DID the record was requested for
+	DID DHT
+	// Gateway is the URL of the gateway the record came from
+	Gateway string
+	// Reason describes why the record could not be verified
+	Reason string
+}
+
+func (e *VerificationError) Error() string {
+	return "failed to verify record for " + string(e.DID) + " from gateway " + e.Gateway + ": " + e.Reason
+}
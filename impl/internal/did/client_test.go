@@ -1,10 +1,14 @@
 package did
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
 	"github.com/anacrolix/dht/v2/bep44"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -31,10 +35,10 @@ func TestClient(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotEmpty(t, bep44Put)
 
-	err = client.PutDocument(doc.ID, *bep44Put)
+	err = client.PutDocument(context.Background(), doc.ID, *bep44Put)
 	assert.NoError(t, err)
 
-	gotDID, err := client.GetDIDDocument(doc.ID)
+	gotDID, err := client.GetDIDDocument(context.Background(), doc.ID)
 	assert.NoError(t, err)
 	assert.EqualValues(t, *doc, gotDID.Doc)
 
@@ -42,6 +46,69 @@ func TestClient(t *testing.T) {
 	t.Logf("time to put and get: %s", since)
 }
 
+func TestClientVerification(t *testing.T) {
+	sk, doc, err := GenerateDIDDHT(CreateDIDDHTOpts{})
+	require.NoError(t, err)
+	packet, err := DHT(doc.ID).ToDNSPacket(*doc, nil, nil, nil)
+	require.NoError(t, err)
+	putMsg, err := dht.CreateDNSPublishRequest(sk, *packet)
+	require.NoError(t, err)
+	record := dht.RecordFromBEP44(putMsg)
+	valid := record.Response().Bytes()
+
+	var body []byte
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(body)
+	}))
+	defer gateway.Close()
+
+	client, err := NewGatewayClient(gateway.URL)
+	require.NoError(t, err)
+
+	t.Run("test verified record", func(t *testing.T) {
+		body = valid
+		gotRecord, err := client.GetDIDRecord(context.Background(), doc.ID)
+		require.NoError(t, err)
+		assert.Equal(t, record.SequenceNumber, gotRecord.Seq)
+		assert.Equal(t, putMsg.V, gotRecord.V)
+		assert.Equal(t, record.Signature, gotRecord.Sig)
+		require.NotNil(t, gotRecord.Document)
+		assert.EqualValues(t, *doc, gotRecord.Document.Doc)
+
+		gotDID, err := client.GetDIDDocument(context.Background(), doc.ID)
+		require.NoError(t, err)
+		assert.EqualValues(t, *doc, gotDID.Doc)
+	})
+
+	t.Run("test unverifiable records", func(t *testing.T) {
+		tamperedSeq := append([]byte{}, valid...)
+		tamperedSeq[71]++
+		tamperedV := append([]byte{}, valid...)
+		tamperedV[len(tamperedV)-1]++
+		tamperedSig := append([]byte{}, valid...)
+		tamperedSig[0]++
+
+		for name, tampered := range map[string][]byte{
+			"seq":       tamperedSeq,
+			"v":         tamperedV,
+			"sig":       tamperedSig,
+			"too short": valid[:71],
+		} {
+			body = tampered
+			gotDID, err := client.GetDIDDocument(context.Background(), doc.ID)
+			assert.Nil(t, gotDID, name)
+
+			var verificationErr *VerificationError
+			require.True(t, errors.As(err, &verificationErr), name)
+			assert.Equal(t, DHT(doc.ID), verificationErr.DID)
+			assert.Equal(t, gateway.URL, verificationErr.Gateway)
+
+			_, err = client.GetResolutionRecord(context.Background(), DHT(doc.ID))
+			assert.True(t, errors.As(err, &verificationErr), name)
+		}
+	})
+}
+
 func TestClientInvalidGateway(t *testing.T) {
 	g, err := NewGatewayClient("\n")
 	assert.Error(t, err)
@@ -53,15 +120,15 @@ func TestInvalidDIDDocument(t *testing.T) {
 	require.NoError(t, err)
 	require.NotEmpty(t, client)
 
-	gotDID, err := client.GetDIDDocument("this is not a valid did")
+	gotDID, err := client.GetDIDDocument(context.Background(), "this is not a valid did")
 	assert.Error(t, err)
 	assert.Empty(t, gotDID)
 
-	gotDID, err = client.GetDIDDocument("did:dht:example")
+	gotDID, err = client.GetDIDDocument(context.Background(), "did:dht:example")
 	assert.EqualError(t, err, "invalid did")
 	assert.Empty(t, gotDID)
 
-	gotDID, err = client.GetDIDDocument("did:dht:i9xkp8ddcbcg8jwq54ox699wuzxyifsqx4jru45zodqu453ksz6y")
+	gotDID, err = client.GetDIDDocument(context.Background(), "did:dht:i9xkp8ddcbcg8jwq54ox699wuzxyifsqx4jru45zodqu453ksz6y")
 	assert.Error(t, err) // this should error because the gateway URL is invalid
 	assert.Empty(t, gotDID)
 
@@ -69,16 +136,113 @@ func TestInvalidDIDDocument(t *testing.T) {
 	require.NoError(t, err)
 	require.NotEmpty(t, client)
 
-	gotDID, err = client.GetDIDDocument("did:dht:i9xkp8ddcbcg8jwq54ox699wuzxyifsqx4jru45zodqu453ksz6y")
+	gotDID, err = client.GetDIDDocument(context.Background(), "did:dht:i9xkp8ddcbcg8jwq54ox699wuzxyifsqx4jru45zodqu453ksz6y")
 	assert.Error(t, err) // this should error because the gateway URL will return a non-200
 	assert.Empty(t, gotDID)
 
-	err = client.PutDocument("did:dht:example", bep44.Put{})
+	err = client.PutDocument(context.Background(), "did:dht:example", bep44.Put{})
 	assert.Error(t, err)
 
-	err = client.PutDocument("did:dht:i9xkp8ddcbcg8jwq54ox699wuzxyifsqx4jru45zodqu453ksz6y", bep44.Put{
+	err = client.PutDocument(context.Background(), "did:dht:i9xkp8ddcbcg8jwq54ox699wuzxyifsqx4jru45zodqu453ksz6y", bep44.Put{
 		K: &[32]byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
 		V: []byte{0, 0, 0},
 	})
 	assert.Error(t, err)
 }
+
+func TestClientRetries(t *testing.T) {
+	var attempts int
+	var statuses []int
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := statuses[min(attempts, len(statuses)-1)]
+		attempts++
+		if status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "0")
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`"gateway error"`))
+	}))
+	defer gateway.Close()
+
+	client, err := NewGatewayClient(gateway.URL, WithRetries(2), WithBackoff(time.Millisecond, 10*time.Millisecond))
+	require.NoError(t, err)
+
+	t.Run("test retry until success", func(t *testing.T) {
+		attempts, statuses = 0, []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK}
+		err := client.PutDHTRecord(context.Background(), "i9xkp8ddcbcg8jwq54ox699wuzxyifsqx4jru45zodqu453ksz6y", bep44.Put{V: []byte{0}})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, attempts)
+	})
+
+	t.Run("test retries exhausted", func(t *testing.T) {
+		attempts, statuses = 0, []int{http.StatusInternalServerError}
+		_, err := client.GetChallenge(context.Background())
+		assert.Equal(t, 3, attempts)
+
+		var statusErr *StatusError
+		require.True(t, errors.As(err, &statusErr))
+		assert.Equal(t, http.StatusInternalServerError, statusErr.StatusCode)
+		assert.Equal(t, http.MethodGet, statusErr.Method)
+		assert.Equal(t, gateway.URL+"/challenge", statusErr.URL)
+		assert.Equal(t, "gateway error", statusErr.Message)
+	})
+
+	t.Run("test no retry for client errors", func(t *testing.T) {
+		for _, status := range []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusNotImplemented} {
+			attempts, statuses = 0, []int{status}
+			_, err := client.ListTypes(context.Background())
+			assert.Equal(t, 1, attempts)
+
+			var statusErr *StatusError
+			require.True(t, errors.As(err, &statusErr))
+			assert.Equal(t, status, statusErr.StatusCode)
+			assert.Equal(t, status == http.StatusNotFound, IsNotFound(err))
+		}
+	})
+
+	t.Run("test context cancellation stops retries", func(t *testing.T) {
+		slowClient, err := NewGatewayClient(gateway.URL, WithBackoff(time.Hour, time.Hour))
+		require.NoError(t, err)
+
+		attempts, statuses = 0, []int{http.StatusBadGateway}
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err = slowClient.ListDIDsForType(ctx, Organization, 0, 10)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, 1, attempts)
+	})
+}
+
+func TestClientHTTPClient(t *testing.T) {
+	var userAgent string
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.Header.Get("User-Agent")
+		_, _ = w.Write([]byte(`[{"type":1,"description":"Organization"}]`))
+	}))
+	defer gateway.Close()
+
+	httpClient := &http.Client{Transport: userAgentTransport{"did-dht-test"}}
+	client, err := NewGatewayClient(gateway.URL+"/", WithHTTPClient(httpClient))
+	require.NoError(t, err)
+
+	types, err := client.ListTypes(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []TypeDescription{{Type: Organization, Description: "Organization"}}, types)
+	assert.Equal(t, "did-dht-test", userAgent)
+
+	// the default client must not be modified
+	assert.Zero(t, http.DefaultClient.Timeout)
+
+	_, err = NewGatewayClient(gateway.URL, WithHTTPClient(nil))
+	assert.Error(t, err)
+}
+
+type userAgentTransport struct {
+	userAgent string
+}
+
+func (t userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.userAgent)
+	return http.DefaultTransport.RoundTrip(req)
+}
@@ -36,6 +36,9 @@ const (
 	// Version corresponds to the version fo the specification https://did-dht.com/#dids-as-dns-records
 	Version int = 0
 
+	// Deactivated is the value of the root record of a deactivated DID https://did-dht.com/#deactivate
+	Deactivated = "deactivated"
+
 	Discoverable           TypeIndex = 0
 	Organization           TypeIndex = 1
 	GovernmentOrganization TypeIndex = 2
@@ -46,6 +49,43 @@ const (
 	FinancialInstitution   TypeIndex = 7
 )
 
+// typeDescriptions are the names of the indexed types in the registry https://did-dht.com/registry/#indexed-types
+var typeDescriptions = map[TypeIndex]string{
+	Discoverable:           "Discoverable",
+	Organization:           "Organization",
+	GovernmentOrganization: "Government Organization",
+	Corporation:            "Corporation",
+	LocalBusiness:          "Local Business",
+	SoftwarePackage:        "Software Package",
+	WebApplication:         "Web App",
+	FinancialInstitution:   "Financial Institution",
+}
+
+// IndexedTypes returns all types in the registry in order of their type index
+func IndexedTypes() []TypeIndex {
+	return []TypeIndex{
+		Discoverable,
+		Organization,
+		GovernmentOrganization,
+		Corporation,
+		LocalBusiness,
+		SoftwarePackage,
+		WebApplication,
+		FinancialInstitution,
+	}
+}
+
+// IsRegistered returns true if the type is in the registry
+func (t TypeIndex) IsRegistered() bool {
+	_, ok := typeDescriptions[t]
+	return ok
+}
+
+// Description returns the name of the type in the registry, or an empty string if it is not registered
+func (t TypeIndex) Description() string {
+	return typeDescriptions[t]
+}
+
 func (d DHT) IsValid() bool {
 	suffix, err := d.Suffix()
 	if err != nil {
@@ -59,11 +99,19 @@ func (d DHT) String() string {
 	return string(d)
 }
 
-// Suffix returns the value without the `did:dht` prefix
+// Suffix returns the value without the `did:dht` prefix. For an interoperable did:key or did:jwk it is the z-base-32
+// encoded identity key it shares with a did:dht identifier.
 func (d DHT) Suffix() (string, error) {
 	if suffix, ok := strings.CutPrefix(string(d), Prefix+":"); ok {
 		return suffix, nil
 	}
+	if IsInterop(string(d)) {
+		identityKey, err := interopIdentityKey(string(d))
+		if err != nil {
+			return "", err
+		}
+		return zbase32.EncodeToString(identityKey), nil
+	}
 	return "", fmt.Errorf("invalid did:dht prefix: %s", d)
 }
 
@@ -263,8 +311,21 @@ func GetDIDDHTIdentifier(pubKey []byte) string {
 	return strings.Join([]string{Prefix, zbase32.EncodeToString(pubKey)}, ":")
 }
 
-// ToDNSPacket converts a DID DHT Document to a DNS packet with an optional list of types to include
+// ToDNSPacket converts a DID DHT Document to a DNS packet with an optional list of types to include. The packet uses
+// name compression and must fit in a BEP44 record, otherwise a DNSPacketSizeError names the properties to remove.
 func (d DHT) ToDNSPacket(doc did.Document, types []TypeIndex, gateways []AuthoritativeGateway, previousDID *PreviousDID) (*dns.Msg, error) {
+	msg, err := d.dnsPacket(doc, types, gateways, previousDID)
+	if err != nil {
+		return nil, err
+	}
+	if size := msg.Len(); size > MaxDNSPacketSize {
+		return nil, newDNSPacketSizeError(size, dnsPacketSize(doc, msg).Records)
+	}
+	return msg, nil
+}
+
+// dnsPacket builds the DNS packet of a DID DHT Document without checking its size
+func (d DHT) dnsPacket(doc did.Document, types []TypeIndex, gateways []AuthoritativeGateway, previousDID *PreviousDID) (*dns.Msg, error) {
 	var records []dns.RR
 	var rootRecord []string
 	keyLookup := make(map[string]string)
@@ -337,14 +398,14 @@ func (d DHT) ToDNSPacket(doc did.Document, types []TypeIndex, gateways []Authori
 
 	// add all gateways
 	for _, gateway := range gateways {
-		gatewayAnswer := dns.TXT{
+		gatewayAnswer := dns.NS{
 			Hdr: dns.RR_Header{
 				Name:   fmt.Sprintf("_did.%s.", suffix),
 				Rrtype: dns.TypeNS,
 				Class:  dns.ClassINET,
 				Ttl:    7200,
 			},
-			Txt: chunkTextRecord(string(gateway)),
+			Ns: dns.Fqdn(string(gateway)),
 		}
 		records = append(records, &gatewayAnswer)
 	}
@@ -380,9 +441,9 @@ func (d DHT) ToDNSPacket(doc did.Document, types []TypeIndex, gateways []Authori
 			return nil, fmt.Errorf("failed to calculate JWK thumbprint: %v", err)
 		}
 
-		// only include the id if it's not the JWK thumbprint
+		// only include the id if it's not the default, which is 0 for the identity key and the JWK thumbprint otherwise
 		unqualifiedVMID := strings.TrimPrefix(vm.ID, doc.ID+"#")
-		if unqualifiedVMID != thumbprint {
+		if unqualifiedVMID != thumbprint && vm.ID != doc.ID+"#0" {
 			txtRecord += fmt.Sprintf("id=%s;", unqualifiedVMID)
 		}
 		txtRecord += fmt.Sprintf("t=%d;k=%s", keyType, base64.RawURLEncoding.EncodeToString(pubKeyBytes))
@@ -534,7 +595,36 @@ func (d DHT) ToDNSPacket(doc did.Document, types []TypeIndex, gateways []Authori
 			Response:      true,
 			Authoritative: true,
 		},
-		Answer: records,
+		Compress: true,
+		Answer:   records,
+	}, nil
+}
+
+// ToDeactivatedDNSPacket returns a DNS packet deactivating the DID, which contains only a root record with the
+// deactivated value https://did-dht.com/#deactivate
+func (d DHT) ToDeactivatedDNSPacket() (*dns.Msg, error) {
+	suffix, err := d.Suffix()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get suffix while encoding DNS packet")
+	}
+
+	return &dns.Msg{
+		MsgHdr: dns.MsgHdr{
+			Id:            0,
+			Response:      true,
+			Authoritative: true,
+		},
+		Answer: []dns.RR{
+			&dns.TXT{
+				Hdr: dns.RR_Header{
+					Name:   fmt.Sprintf("_did.%s.", suffix),
+					Rrtype: dns.TypeTXT,
+					Class:  dns.ClassINET,
+					Ttl:    7200,
+				},
+				Txt: []string{Deactivated},
+			},
+		},
 	}, nil
 }
 
@@ -568,6 +658,8 @@ type DIDDHTDocument struct {
 	Types       []TypeIndex            `json:"types,omitempty"`
 	Gateways    []AuthoritativeGateway `json:"gateways,omitempty"`
 	PreviousDID *PreviousDID           `json:"previousDid,omitempty"`
+	// Deactivated is true if the DID has been deactivated, in which case the document contains only its ID
+	Deactivated bool `json:"deactivated,omitempty"`
 }
 
 // FromDNSPacket converts a DNS packet to a DID DHT Document
@@ -721,12 +813,6 @@ func (d DHT) FromDNSPacket(msg *dns.Msg) (*DIDDHTDocument, error) {
 					}
 					types = append(types, TypeIndex(tInt))
 				}
-			} else if record.Hdr.Name == fmt.Sprintf("_did.%s.", suffix) && record.Hdr.Rrtype == dns.TypeNS {
-				if record.Txt[0] == "" {
-					return nil, fmt.Errorf("gateway record is empty")
-				}
-				unchunkedTextRecord := unchunkTextRecord(record.Txt)
-				gateways = append(gateways, AuthoritativeGateway(unchunkedTextRecord))
 			} else if record.Hdr.Name == "_prv._did." && record.Hdr.Rrtype == dns.TypeTXT {
 				unchunkedTextRecord := unchunkTextRecord(record.Txt)
 				data := parseTxtData(unchunkedTextRecord)
@@ -740,6 +826,9 @@ func (d DHT) FromDNSPacket(msg *dns.Msg) (*DIDDHTDocument, error) {
 				}
 			} else if record.Hdr.Name == fmt.Sprintf("_did.%s.", suffix) && record.Hdr.Rrtype == dns.TypeTXT {
 				unchunkedTextRecord := unchunkTextRecord(record.Txt)
+				if unchunkedTextRecord == Deactivated {
+					return &DIDDHTDocument{Doc: did.Document{ID: didID}, Deactivated: true}, nil
+				}
 				rootItems := strings.Split(unchunkedTextRecord, ";")
 
 				seenVersion := false
@@ -784,6 +873,13 @@ func (d DHT) FromDNSPacket(msg *dns.Msg) (*DIDDHTDocument, error) {
 					return nil, fmt.Errorf("root record missing version identifier")
 				}
 			}
+		case *dns.NS:
+			if record.Hdr.Name == fmt.Sprintf("_did.%s.", suffix) {
+				if record.Ns == "" {
+					return nil, fmt.Errorf("gateway record is empty")
+				}
+				gateways = append(gateways, AuthoritativeGateway(record.Ns))
+			}
 		}
 	}
 
@@ -10,6 +10,7 @@ import (
 	"github.com/TBD54566975/ssi-sdk/cryptosuite"
 	"github.com/TBD54566975/ssi-sdk/did"
 	"github.com/goccy/go-json"
+	"github.com/miekg/dns"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -167,6 +168,24 @@ func TestToDNSPacket(t *testing.T) {
 		require.Equal(t, didDHTDoc.Gateways, []AuthoritativeGateway{"gateway1.example-did-dht-gateway.com."})
 
 		assert.EqualValues(t, *doc, didDHTDoc.Doc)
+
+		// gateways are NS records, and survive encoding to the wire format
+		var ns []dns.RR
+		for _, rr := range packet.Answer {
+			if rr.Header().Rrtype == dns.TypeNS {
+				ns = append(ns, rr)
+			}
+		}
+		require.Len(t, ns, 1)
+		assert.Equal(t, "gateway1.example-did-dht-gateway.com.", ns[0].(*dns.NS).Ns)
+
+		packed, err := packet.Pack()
+		require.NoError(t, err)
+		unpacked := new(dns.Msg)
+		require.NoError(t, unpacked.Unpack(packed))
+		didDHTDoc, err = didID.FromDNSPacket(unpacked)
+		require.NoError(t, err)
+		assert.Equal(t, []AuthoritativeGateway{"gateway1.example-did-dht-gateway.com."}, didDHTDoc.Gateways)
 	})
 
 	t.Run("doc with multiple keys and services - test to dns packet round trip", func(t *testing.T) {
@@ -593,3 +612,52 @@ func TestCreationFailures(t *testing.T) {
 	})
 
 }
+
+func TestTypeIndex(t *testing.T) {
+	indexedTypes := IndexedTypes()
+	assert.Len(t, indexedTypes, 8)
+	for i, typeIndex := range indexedTypes {
+		assert.Equal(t, TypeIndex(i), typeIndex)
+		assert.True(t, typeIndex.IsRegistered())
+		assert.NotEmpty(t, typeIndex.Description())
+	}
+
+	assert.Equal(t, "Government Organization", GovernmentOrganization.Description())
+	assert.Equal(t, "Web App", WebApplication.Description())
+
+	assert.False(t, TypeIndex(8).IsRegistered())
+	assert.Empty(t, TypeIndex(8).Description())
+}
+
+func TestDeactivation(t *testing.T) {
+	_, doc, err := GenerateDIDDHT(CreateDIDDHTOpts{})
+	require.NoError(t, err)
+	didID := DHT(doc.ID)
+
+	packet, err := didID.ToDeactivatedDNSPacket()
+	require.NoError(t, err)
+	require.Len(t, packet.Answer, 1)
+
+	// round trip through the wire format
+	packetBytes, err := packet.Pack()
+	require.NoError(t, err)
+	msg := new(dns.Msg)
+	require.NoError(t, msg.Unpack(packetBytes))
+
+	didDHTDoc, err := didID.FromDNSPacket(msg)
+	require.NoError(t, err)
+	assert.True(t, didDHTDoc.Deactivated)
+	assert.Equal(t, doc.ID, didDHTDoc.Doc.ID)
+	assert.Empty(t, didDHTDoc.Doc.VerificationMethod)
+	assert.Empty(t, didDHTDoc.Types)
+
+	// active documents are not deactivated
+	packet, err = didID.ToDNSPacket(*doc, nil, nil, nil)
+	require.NoError(t, err)
+	didDHTDoc, err = didID.FromDNSPacket(packet)
+	require.NoError(t, err)
+	assert.False(t, didDHTDoc.Deactivated)
+
+	_, err = DHT("did:example:1234").ToDeactivatedDNSPacket()
+	assert.Error(t, err)
+}
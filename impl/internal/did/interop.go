@@ -0,0 +1,131 @@
+package did
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"strings"
+
+	"github.com/TBD54566975/ssi-sdk/crypto"
+	"github.com/TBD54566975/ssi-sdk/crypto/jwx"
+	"github.com/TBD54566975/ssi-sdk/did"
+	"github.com/TBD54566975/ssi-sdk/did/jwk"
+	"github.com/TBD54566975/ssi-sdk/did/key"
+	"github.com/pkg/errors"
+)
+
+// Interoperability with Ed25519 did:key and did:jwk identifiers https://did-dht.com/registry/#interoperable-did-methods
+// An interoperable DID shares its identity key with a did:dht identifier, under which its DID Document is published
+// to the DHT, so it is accepted anywhere a DHT is: its suffix is the z-base-32 encoding of the shared identity key.
+// When nothing is published for it, an interoperable DID resolves to the expansion of its key.
+
+const (
+	// KeyPrefix is the prefix of did:key identifiers
+	KeyPrefix = key.Prefix
+	// JWKPrefix is the prefix of did:jwk identifiers
+	JWKPrefix = jwk.Prefix
+)
+
+// IsKey returns true if the identifier is a did:key
+func IsKey(id string) bool {
+	return strings.HasPrefix(id, KeyPrefix+":")
+}
+
+// IsJWK returns true if the identifier is a did:jwk
+func IsJWK(id string) bool {
+	return strings.HasPrefix(id, JWKPrefix+":")
+}
+
+// IsInterop returns true if the identifier is of a DID method which interoperates with did:dht
+func IsInterop(id string) bool {
+	return IsKey(id) || IsJWK(id)
+}
+
+// interopIdentityKey returns the Ed25519 key of an interoperable DID, which is its identity key
+func interopIdentityKey(id string) (ed25519.PublicKey, error) {
+	switch {
+	case IsKey(id):
+		pubKey, keyType, err := key.DIDKey(id).Decode()
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid did:key: %s", id)
+		}
+		if keyType != crypto.Ed25519 || len(pubKey) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("unsupported did:key type %s, only Ed25519 keys are interoperable", keyType)
+		}
+		return pubKey, nil
+	case IsJWK(id):
+		doc, err := jwk.JWK(id).Expand()
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid did:jwk: %s", id)
+		}
+		publicKeyJWK := doc.VerificationMethod[0].PublicKeyJWK
+		if publicKeyJWK.KTY != "OKP" || publicKeyJWK.CRV != "Ed25519" {
+			return nil, fmt.Errorf("unsupported did:jwk key type %s %s, only Ed25519 keys are interoperable", publicKeyJWK.KTY, publicKeyJWK.CRV)
+		}
+		pubKey, err := publicKeyJWK.ToPublicKey()
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid did:jwk: %s", id)
+		}
+		edKey, ok := pubKey.(ed25519.PublicKey)
+		if !ok || len(edKey) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid did:jwk: %s", id)
+		}
+		return edKey, nil
+	default:
+		return nil, fmt.Errorf("not an interoperable did: %s", id)
+	}
+}
+
+// ToDIDDHT returns the did:dht identifier sharing the identity key of the DID, which for a did:dht is the DID itself
+func (d DHT) ToDIDDHT() (DHT, error) {
+	suffix, err := d.Suffix()
+	if err != nil {
+		return "", err
+	}
+	return DHT(Prefix + ":" + suffix), nil
+}
+
+// KeyFromDHT returns the did:key identifier sharing the identity key of a did:dht identifier
+func KeyFromDHT(d DHT) (string, error) {
+	identityKey, err := d.IdentityKey()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get identity key")
+	}
+	didKey, err := key.CreateDIDKey(crypto.Ed25519, identityKey)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create did:key")
+	}
+	return didKey.String(), nil
+}
+
+// JWKFromDHT returns the did:jwk identifier sharing the identity key of a did:dht identifier
+func JWKFromDHT(d DHT) (string, error) {
+	identityKey, err := d.IdentityKey()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get identity key")
+	}
+	publicKeyJWK, err := jwx.PublicKeyToPublicKeyJWK(nil, identityKey)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to convert identity key to jwk")
+	}
+	didJWK, err := jwk.CreateDIDJWK(*publicKeyJWK)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create did:jwk")
+	}
+	return didJWK.String(), nil
+}
+
+// Expand returns the DID Document expanded from the key of an interoperable DID, used when nothing is published for
+// it https://w3c-ccg.github.io/did-method-key/#document-creation-algorithm
+// https://github.com/quartzjer/did-jwk/blob/main/spec.md#to-create-the-did-url
+func (d DHT) Expand() (*did.Document, error) {
+	if !IsInterop(d.String()) {
+		return nil, fmt.Errorf("only did:key and did:jwk identifiers can be expanded: %s", d)
+	}
+	if _, err := d.IdentityKey(); err != nil {
+		return nil, err
+	}
+	if IsKey(d.String()) {
+		return key.DIDKey(d).Expand()
+	}
+	return jwk.JWK(d).Expand()
+}
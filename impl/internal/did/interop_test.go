@@ -0,0 +1,106 @@
+package did
+
+import (
+	"testing"
+
+	"github.com/TBD54566975/ssi-sdk/crypto"
+	"github.com/TBD54566975/ssi-sdk/did/jwk"
+	"github.com/TBD54566975/ssi-sdk/did/key"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterop(t *testing.T) {
+	_, doc, err := GenerateDIDDHT(CreateDIDDHTOpts{})
+	require.NoError(t, err)
+	dhtID := DHT(doc.ID)
+
+	keyID, err := KeyFromDHT(dhtID)
+	require.NoError(t, err)
+	jwkID, err := JWKFromDHT(dhtID)
+	require.NoError(t, err)
+
+	t.Run("test interoperable identifiers share a key", func(t *testing.T) {
+		assert.True(t, IsKey(keyID))
+		assert.Contains(t, keyID, "did:key:z6Mk")
+		assert.True(t, IsJWK(jwkID))
+
+		suffix, err := dhtID.Suffix()
+		require.NoError(t, err)
+		for _, id := range []string{keyID, jwkID} {
+			assert.True(t, IsInterop(id))
+			d := DHT(id)
+			assert.True(t, d.IsValid())
+
+			gotSuffix, err := d.Suffix()
+			require.NoError(t, err)
+			assert.Equal(t, suffix, gotSuffix)
+
+			gotDHT, err := d.ToDIDDHT()
+			require.NoError(t, err)
+			assert.Equal(t, dhtID, gotDHT)
+		}
+
+		gotDHT, err := dhtID.ToDIDDHT()
+		require.NoError(t, err)
+		assert.Equal(t, dhtID, gotDHT)
+		assert.False(t, IsInterop(doc.ID))
+	})
+
+	t.Run("test unsupported identifiers", func(t *testing.T) {
+		_, secpKey, err := key.GenerateDIDKey(crypto.SECP256k1)
+		require.NoError(t, err)
+		_, p256JWK, err := jwk.GenerateDIDJWK(crypto.P256)
+		require.NoError(t, err)
+
+		for _, id := range []string{"did:key:abcd", "did:key:", "did:jwk:abcd", "did:example:1234", secpKey.String(), p256JWK.String()} {
+			d := DHT(id)
+			assert.False(t, d.IsValid(), id)
+			_, err = d.Suffix()
+			assert.Error(t, err, id)
+			_, err = d.Expand()
+			assert.Error(t, err, id)
+		}
+
+		_, err = dhtID.Expand()
+		assert.Error(t, err)
+	})
+
+	t.Run("test expand", func(t *testing.T) {
+		keyDoc, err := DHT(keyID).Expand()
+		require.NoError(t, err)
+		assert.Equal(t, keyID, keyDoc.ID)
+		assert.NotEmpty(t, keyDoc.VerificationMethod)
+
+		jwkDoc, err := DHT(jwkID).Expand()
+		require.NoError(t, err)
+		assert.Equal(t, jwkID, jwkDoc.ID)
+		assert.Equal(t, jwkID+"#0", jwkDoc.VerificationMethod[0].ID)
+	})
+
+	t.Run("test dns packet round trip", func(t *testing.T) {
+		dhtPacket, err := dhtID.ToDNSPacket(*doc, []TypeIndex{Organization}, nil, nil)
+		require.NoError(t, err)
+		v, err := dhtPacket.Pack()
+		require.NoError(t, err)
+
+		for _, id := range []string{keyID, jwkID} {
+			msg := new(dns.Msg)
+			require.NoError(t, msg.Unpack(v))
+
+			// the packet of the did:dht identifier decodes as the document of the interoperable DID
+			gotDoc, err := DHT(id).FromDNSPacket(msg)
+			require.NoError(t, err)
+			assert.Equal(t, id, gotDoc.Doc.ID)
+			assert.Equal(t, id+"#0", gotDoc.Doc.VerificationMethod[0].ID)
+			assert.Equal(t, id, gotDoc.Doc.VerificationMethod[0].Controller)
+			assert.Equal(t, []TypeIndex{Organization}, gotDoc.Types)
+
+			// and encodes back to the same packet
+			packet, err := DHT(id).ToDNSPacket(gotDoc.Doc, gotDoc.Types, nil, nil)
+			require.NoError(t, err)
+			assert.Equal(t, dhtPacket.String(), packet.String())
+		}
+	})
+}
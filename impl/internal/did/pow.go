@@ -5,6 +5,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"math/big"
+	"math/rand"
 	"strconv"
 	"strings"
 )
@@ -42,8 +43,9 @@ func solveRetentionChallenge(didIdentifier, inputHash string, difficulty, nonce
 	return hash, hasLeadingZeros(hash, difficulty)
 }
 
-// validateRetentionSolution validates the Retention Solution.
-func validateRetentionSolution(did, hash, retentionSolution string, difficulty int) bool {
+// ValidateRetentionSolution validates a Retention Solution of the form `hash:nonce` for the given DID, hash, and difficulty
+// https://did-dht.com/#validating-a-retention-solution
+func ValidateRetentionSolution(did, hash, retentionSolution string, difficulty int) bool {
 	parts := strings.Split(retentionSolution, ":")
 	if len(parts) != 2 {
 		return false
@@ -64,3 +66,13 @@ func validateRetentionSolution(did, hash, retentionSolution string, difficulty i
 	solutionHash := parts[0]
 	return solutionHash == computedHash
 }
+
+// GenerateRetentionSolution performs the proof of work for the given DID, hash, and difficulty, returning a
+// Retention Solution of the form `hash:nonce` https://did-dht.com/#generating-a-retention-solution
+func GenerateRetentionSolution(did, hash string, difficulty int) string {
+	for nonce := int(rand.Uint32()); ; nonce++ {
+		if solution, ok := solveRetentionChallenge(did, hash, difficulty, nonce); ok {
+			return fmt.Sprintf("%s:%d", solution, nonce)
+		}
+	}
+}
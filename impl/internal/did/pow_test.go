@@ -23,7 +23,7 @@ func TestPOW(t *testing.T) {
 			fmt.Printf("Valid Retention Solution: %v\n", isValid)
 			fmt.Printf("Nonce: %d\n", nonce)
 
-			isValidRetentionSolution := validateRetentionSolution(didIdentifier, inputHash, fmt.Sprintf("%s:%d", solution, nonce), difficulty)
+			isValidRetentionSolution := ValidateRetentionSolution(didIdentifier, inputHash, fmt.Sprintf("%s:%d", solution, nonce), difficulty)
 			fmt.Printf("Validated Solution: %v\n", isValidRetentionSolution)
 			break
 		}
@@ -0,0 +1,222 @@
+package did
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TBD54566975/ssi-sdk/did"
+	"github.com/miekg/dns"
+)
+
+const (
+	// ResolutionContext is the JSON-LD context of a DID Resolution result https://w3c-ccg.github.io/did-resolution/
+	ResolutionContext = "https://w3id.org/did-resolution/v1"
+	// ResolutionContentType is the media type of a DID Resolution result
+	ResolutionContentType = `application/ld+json;profile="https://w3id.org/did-resolution"`
+	// DocumentContentType is the media type of a resolved DID Document
+	DocumentContentType = "application/did+json"
+)
+
+// ResolutionError is a DID Resolution error code https://www.w3.org/TR/did-spec-registries/#error
+type ResolutionError string
+
+const (
+	ResolutionErrorInvalidDID         ResolutionError = "invalidDid"
+	ResolutionErrorNotFound           ResolutionError = "notFound"
+	ResolutionErrorMethodNotSupported ResolutionError = "methodNotSupported"
+	ResolutionErrorInternal           ResolutionError = "internalError"
+)
+
+// ResolutionResult is the result of resolving a DID https://did-dht.com/#resolving-a-did
+type ResolutionResult struct {
+	Context            string             `json:"@context"`
+	Document           *did.Document      `json:"didDocument"`
+	DocumentMetadata   DocumentMetadata   `json:"didDocumentMetadata"`
+	ResolutionMetadata ResolutionMetadata `json:"didResolutionMetadata"`
+}
+
+// DocumentMetadata is the metadata of a resolved DID Document https://did-dht.com/#did-resolution
+type DocumentMetadata struct {
+	// VersionID is the sequence number of the resolved record
+	VersionID string `json:"versionId,omitempty"`
+	// Created is the time of the earliest known version of the DID, as an XML datetime
+	Created string `json:"created,omitempty"`
+	// Updated is the time of the resolved version of the DID, as an XML datetime
+	Updated string `json:"updated,omitempty"`
+	// Deactivated is true if the DID has been deactivated
+	Deactivated bool `json:"deactivated,omitempty"`
+	// Types is the list of indexed types for the DID, if any
+	Types []TypeIndex `json:"types,omitempty"`
+	// Expiry is the time at which the DID will be evicted from the gateway's Retained DID Set, as an XML datetime
+	Expiry string `json:"expiry,omitempty"`
+	// Gateway is the gateway the DID was resolved from
+	Gateway string `json:"gateway,omitempty"`
+	// Source is where the resolved record came from, either a gateway URL or the DHT
+	Source string `json:"source,omitempty"`
+	// EquivalentID lists identifiers equivalent to the resolved DID, such as the did:dht identifier of a did:key or
+	// did:jwk
+	EquivalentID []string `json:"equivalentId,omitempty"`
+	// PreviousDIDs lists the verified predecessors of the DID from its `_prv._did.` chain, starting with the most
+	// recent, if the resolver walks it https://did-dht.com/#rotation
+	PreviousDIDs []string `json:"previousDids,omitempty"`
+}
+
+// ResolutionMetadata is the metadata of a DID Resolution process
+type ResolutionMetadata struct {
+	ContentType string          `json:"contentType,omitempty"`
+	Error       ResolutionError `json:"error,omitempty"`
+	// ErrorMessage is a human-readable description of the error, if any
+	ErrorMessage string `json:"errorMessage,omitempty"`
+	// Warnings describe the failures of sources the DID was not resolved from, such as gateways returning records
+	// which could not be verified
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// ResolutionRecord is a DHT record for a DID along with the gateway's metadata for it
+type ResolutionRecord struct {
+	// V is the DNS packet of the DID
+	V []byte
+	// Seq is the sequence number of the record, a unix timestamp in seconds
+	Seq int64
+	// SequenceNumbers are the sequence numbers known for the DID, if any
+	SequenceNumbers []int64
+	// Expiry is the unix timestamp in seconds at which the DID will be evicted from the Retained DID Set, if retained
+	Expiry int64
+	// Gateway is the gateway the record was resolved from
+	Gateway string
+	// Source is where the record came from, either a gateway URL or DHTResolutionSourceName
+	Source string
+	// SourceErrors are the errors from any sources which failed while the record was resolved from another
+	SourceErrors []error
+}
+
+// ResolutionSource provides the records DIDs are resolved from, such as a gateway's storage or a gateway client
+type ResolutionSource interface {
+	// GetResolutionRecord returns the record for the given DID, or nil if it is not found
+	GetResolutionRecord(ctx context.Context, id DHT) (*ResolutionRecord, error)
+}
+
+// Resolver resolves did:dht DIDs to DID Resolution results
+type Resolver struct {
+	source ResolutionSource
+	// maxPreviousDIDs is the number of links of the `_prv._did.` chain to walk, none by default
+	maxPreviousDIDs int
+}
+
+// NewResolver returns a new resolver which resolves DIDs from the given source
+func NewResolver(source ResolutionSource) *Resolver {
+	return &Resolver{source: source}
+}
+
+// Resolve resolves the given DID, reporting any failure in the result's resolution metadata
+func (r *Resolver) Resolve(ctx context.Context, id string) ResolutionResult {
+	method, _, ok := parseDID(id)
+	if !ok {
+		return resolutionErrorResult(ResolutionErrorInvalidDID, "invalid did: "+id)
+	}
+	if method != string(DHTMethod) && !IsInterop(id) {
+		return resolutionErrorResult(ResolutionErrorMethodNotSupported, "unsupported did method: "+method)
+	}
+	d := DHT(id)
+	dhtID, err := d.ToDIDDHT()
+	if err != nil || !d.IsValid() {
+		return resolutionErrorResult(ResolutionErrorInvalidDID, "invalid did: "+id)
+	}
+
+	// an interoperable DID is published under the did:dht identifier sharing its identity key
+	record, err := r.source.GetResolutionRecord(ctx, dhtID)
+	if err != nil {
+		return resolutionErrorResult(ResolutionErrorInternal, err.Error())
+	}
+	if record == nil {
+		if IsInterop(id) {
+			return expandedResolutionResult(d)
+		}
+		return resolutionErrorResult(ResolutionErrorNotFound, "did not found: "+id)
+	}
+	result := NewResolutionResult(d, *record)
+	if dhtID != d && result.Document != nil {
+		result.DocumentMetadata.EquivalentID = []string{dhtID.String()}
+	}
+	if r.maxPreviousDIDs > 0 && result.Document != nil {
+		result.DocumentMetadata.PreviousDIDs = r.previousDIDs(ctx, dhtID, *record)
+	}
+	return result
+}
+
+// expandedResolutionResult builds the resolution result for an interoperable DID with nothing published for it from
+// the expansion of its key https://did-dht.com/registry/#interoperable-did-methods
+func expandedResolutionResult(id DHT) ResolutionResult {
+	doc, err := id.Expand()
+	if err != nil {
+		return resolutionErrorResult(ResolutionErrorInvalidDID, err.Error())
+	}
+	return ResolutionResult{
+		Context:            ResolutionContext,
+		Document:           doc,
+		ResolutionMetadata: ResolutionMetadata{ContentType: DocumentContentType},
+	}
+}
+
+// NewResolutionResult builds the resolution result for the given DID from its record
+func NewResolutionResult(id DHT, record ResolutionRecord) ResolutionResult {
+	msg := new(dns.Msg)
+	if err := msg.Unpack(record.V); err != nil {
+		return resolutionErrorResult(ResolutionErrorInternal, "failed to unpack dns packet: "+err.Error())
+	}
+	doc, err := id.FromDNSPacket(msg)
+	if err != nil {
+		return resolutionErrorResult(ResolutionErrorInternal, "failed to reconstruct did document: "+err.Error())
+	}
+
+	created := record.Seq
+	for _, seq := range record.SequenceNumbers {
+		created = min(created, seq)
+	}
+	metadata := DocumentMetadata{
+		VersionID:   strconv.FormatInt(record.Seq, 10),
+		Created:     formatResolutionTime(created),
+		Updated:     formatResolutionTime(record.Seq),
+		Deactivated: doc.Deactivated,
+		Types:       doc.Types,
+		Gateway:     record.Gateway,
+		Source:      record.Source,
+	}
+	if record.Expiry > 0 {
+		metadata.Expiry = formatResolutionTime(record.Expiry)
+	}
+
+	resolutionMetadata := ResolutionMetadata{ContentType: DocumentContentType}
+	for _, err = range record.SourceErrors {
+		resolutionMetadata.Warnings = append(resolutionMetadata.Warnings, err.Error())
+	}
+
+	return ResolutionResult{
+		Context:            ResolutionContext,
+		Document:           &doc.Doc,
+		DocumentMetadata:   metadata,
+		ResolutionMetadata: resolutionMetadata,
+	}
+}
+
+func resolutionErrorResult(code ResolutionError, message string) ResolutionResult {
+	return ResolutionResult{
+		Context:            ResolutionContext,
+		ResolutionMetadata: ResolutionMetadata{Error: code, ErrorMessage: message},
+	}
+}
+
+// parseDID splits a DID into its method and method-specific identifier
+func parseDID(id string) (method, identifier string, ok bool) {
+	parts := strings.SplitN(id, ":", 3)
+	if len(parts) != 3 || parts[0] != "did" || parts[1] == "" || parts[2] == "" {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
+
+func formatResolutionTime(unix int64) string {
+	return time.Unix(unix, 0).UTC().Format(time.RFC3339)
+}
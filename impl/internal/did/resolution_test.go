@@ -0,0 +1,174 @@
+package did
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/TBD54566975/did-dht/pkg/dht"
+)
+
+type stubResolutionSource struct {
+	records map[DHT]ResolutionRecord
+	err     error
+}
+
+func (s stubResolutionSource) GetResolutionRecord(_ context.Context, id DHT) (*ResolutionRecord, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	record, ok := s.records[id]
+	if !ok {
+		return nil, nil
+	}
+	return &record, nil
+}
+
+func TestResolver(t *testing.T) {
+	ctx := context.Background()
+
+	_, doc, err := GenerateDIDDHT(CreateDIDDHTOpts{})
+	require.NoError(t, err)
+	didID := DHT(doc.ID)
+	packet, err := didID.ToDNSPacket(*doc, []TypeIndex{Organization}, nil, nil)
+	require.NoError(t, err)
+	v, err := packet.Pack()
+	require.NoError(t, err)
+
+	_, deactivatedDoc, err := GenerateDIDDHT(CreateDIDDHTOpts{})
+	require.NoError(t, err)
+	deactivatedID := DHT(deactivatedDoc.ID)
+	packet, err = deactivatedID.ToDeactivatedDNSPacket()
+	require.NoError(t, err)
+	deactivatedV, err := packet.Pack()
+	require.NoError(t, err)
+
+	resolver := NewResolver(stubResolutionSource{records: map[DHT]ResolutionRecord{
+		didID: {
+			V:               v,
+			Seq:             1700003600,
+			SequenceNumbers: []int64{1700000000, 1700003600},
+			Expiry:          1700600000,
+			Gateway:         "https://gateway.example",
+		},
+		deactivatedID: {V: deactivatedV, Seq: 1700000000},
+	}})
+
+	t.Run("test resolve", func(t *testing.T) {
+		result := resolver.Resolve(ctx, doc.ID)
+		assert.Equal(t, ResolutionContext, result.Context)
+		assert.Empty(t, result.ResolutionMetadata.Error)
+		assert.Equal(t, DocumentContentType, result.ResolutionMetadata.ContentType)
+		require.NotNil(t, result.Document)
+		assert.Equal(t, *doc, *result.Document)
+		assert.Equal(t, DocumentMetadata{
+			VersionID: "1700003600",
+			Created:   "2023-11-14T22:13:20Z",
+			Updated:   "2023-11-14T23:13:20Z",
+			Types:     []TypeIndex{Organization},
+			Expiry:    "2023-11-21T20:53:20Z",
+			Gateway:   "https://gateway.example",
+		}, result.DocumentMetadata)
+	})
+
+	t.Run("test resolve deactivated", func(t *testing.T) {
+		result := resolver.Resolve(ctx, deactivatedDoc.ID)
+		assert.Empty(t, result.ResolutionMetadata.Error)
+		assert.True(t, result.DocumentMetadata.Deactivated)
+		require.NotNil(t, result.Document)
+		assert.Equal(t, deactivatedDoc.ID, result.Document.ID)
+	})
+
+	t.Run("test resolve interoperable dids", func(t *testing.T) {
+		_, missingDoc, err := GenerateDIDDHT(CreateDIDDHTOpts{})
+		require.NoError(t, err)
+
+		for method, fromDHT := range map[string]func(DHT) (string, error){KeyPrefix: KeyFromDHT, JWKPrefix: JWKFromDHT} {
+			// an interoperable DID published under its did:dht identifier resolves to the published document
+			id, err := fromDHT(didID)
+			require.NoError(t, err, method)
+			result := resolver.Resolve(ctx, id)
+			assert.Empty(t, result.ResolutionMetadata.Error, method)
+			require.NotNil(t, result.Document, method)
+			assert.Equal(t, id, result.Document.ID)
+			assert.Equal(t, id+"#0", result.Document.VerificationMethod[0].ID)
+			assert.Equal(t, "1700003600", result.DocumentMetadata.VersionID)
+			assert.Equal(t, []string{didID.String()}, result.DocumentMetadata.EquivalentID)
+
+			// an interoperable DID with nothing published resolves to its expansion
+			missingID, err := fromDHT(DHT(missingDoc.ID))
+			require.NoError(t, err, method)
+			result = resolver.Resolve(ctx, missingID)
+			assert.Empty(t, result.ResolutionMetadata.Error, method)
+			require.NotNil(t, result.Document, method)
+			expanded, err := DHT(missingID).Expand()
+			require.NoError(t, err, method)
+			assert.Equal(t, *expanded, *result.Document)
+			assert.Empty(t, result.DocumentMetadata.VersionID)
+
+			result = resolver.Resolve(ctx, method+":invalid")
+			assert.Equal(t, ResolutionErrorInvalidDID, result.ResolutionMetadata.Error, method)
+		}
+	})
+
+	t.Run("test resolution errors", func(t *testing.T) {
+		_, missingDoc, err := GenerateDIDDHT(CreateDIDDHTOpts{})
+		require.NoError(t, err)
+
+		for id, code := range map[string]ResolutionError{
+			"":                 ResolutionErrorInvalidDID,
+			"not a did":        ResolutionErrorInvalidDID,
+			"did:dht:aaaa":     ResolutionErrorInvalidDID,
+			"did:example:1234": ResolutionErrorMethodNotSupported,
+			missingDoc.ID:      ResolutionErrorNotFound,
+		} {
+			result := resolver.Resolve(ctx, id)
+			assert.Equal(t, code, result.ResolutionMetadata.Error, "unexpected error for %q", id)
+			assert.NotEmpty(t, result.ResolutionMetadata.ErrorMessage)
+			assert.Nil(t, result.Document)
+		}
+
+		result := NewResolver(stubResolutionSource{err: errors.New("unavailable")}).Resolve(ctx, doc.ID)
+		assert.Equal(t, ResolutionErrorInternal, result.ResolutionMetadata.Error)
+	})
+}
+
+func TestClientResolveDID(t *testing.T) {
+	sk, doc, err := GenerateDIDDHT(CreateDIDDHTOpts{})
+	require.NoError(t, err)
+	packet, err := DHT(doc.ID).ToDNSPacket(*doc, nil, nil, nil)
+	require.NoError(t, err)
+	putMsg, err := dht.CreateDNSPublishRequest(sk, *packet)
+	require.NoError(t, err)
+	record := dht.RecordFromBEP44(putMsg)
+
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/"+record.ID() {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(record.Response().Bytes())
+	}))
+	defer gateway.Close()
+
+	client, err := NewGatewayClient(gateway.URL)
+	require.NoError(t, err)
+
+	result := client.ResolveDID(context.Background(), doc.ID)
+	assert.Empty(t, result.ResolutionMetadata.Error)
+	require.NotNil(t, result.Document)
+	assert.Equal(t, *doc, *result.Document)
+	assert.Equal(t, gateway.URL, result.DocumentMetadata.Gateway)
+	assert.Equal(t, time.Unix(record.SequenceNumber, 0).UTC().Format(time.RFC3339), result.DocumentMetadata.Updated)
+
+	_, missingDoc, err := GenerateDIDDHT(CreateDIDDHTOpts{})
+	require.NoError(t, err)
+	result = client.ResolveDID(context.Background(), missingDoc.ID)
+	assert.Equal(t, ResolutionErrorNotFound, result.ResolutionMetadata.Error)
+}
@@ -0,0 +1,196 @@
+package did
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/TBD54566975/ssi-sdk/did"
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+)
+
+// Identity key rotation https://did-dht.com/#rotation
+// A DID's identity key cannot change, so rotating it creates a new DID which links to its predecessor with a
+// `_prv._did.` record signed by the predecessor's identity key. The predecessor may be republished with its
+// controller set to the new DID.
+
+// RotateDIDDHTOpts is a set of options for rotating the identity key of a did:dht identifier
+type RotateDIDDHTOpts struct {
+	// UpdatePrevious republishes the previous DID with its controller set to the new DID
+	UpdatePrevious bool
+}
+
+// Rotation is the result of rotating the identity key of a did:dht identifier. Its packets are to be signed and
+// published by the identity key of the DID they belong to.
+type Rotation struct {
+	// PrivateKey is the new identity key
+	PrivateKey ed25519.PrivateKey
+	// Document is the DID Document of the new DID, along with its types, gateways and link to the previous DID
+	Document DIDDHTDocument
+	// Packet is the DNS packet of the new DID
+	Packet *dns.Msg
+	// PreviousDocument is the DID Document of the previous DID with its controller set to the new DID, if updated
+	PreviousDocument *DIDDHTDocument
+	// PreviousPacket is the DNS packet of the previous DID, if updated
+	PreviousPacket *dns.Msg
+}
+
+// RotateDIDDHT generates a new identity key for the given DID, carrying over its verification methods other than the
+// identity key, its services, types and gateways to the new DID, which is linked to the previous one
+func RotateDIDDHT(previousPrivateKey ed25519.PrivateKey, previous DIDDHTDocument, opts RotateDIDDHTOpts) (*Rotation, error) {
+	previousID := DHT(previous.Doc.ID)
+	identityKey, err := previousID.IdentityKey()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get identity key of the previous DID: %s", previousID)
+	}
+	if !identityKey.Equal(previousPrivateKey.Public()) {
+		return nil, fmt.Errorf("private key is not the identity key of the previous DID: %s", previousID)
+	}
+	if previous.Deactivated {
+		return nil, fmt.Errorf("cannot rotate a deactivated DID: %s", previousID)
+	}
+
+	createOpts, err := rotationOpts(previous.Doc)
+	if err != nil {
+		return nil, err
+	}
+	privKey, doc, err := GenerateDIDDHT(*createOpts)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate the new DID")
+	}
+	id := DHT(doc.ID)
+
+	previousDID, err := CreatePreviousDIDRecord(previousPrivateKey, previousID, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create the previous DID record")
+	}
+	packet, err := id.ToDNSPacket(*doc, previous.Types, previous.Gateways, previousDID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create the new DID's dns packet")
+	}
+	rotation := Rotation{
+		PrivateKey: privKey,
+		Document: DIDDHTDocument{
+			Doc:         *doc,
+			Types:       previous.Types,
+			Gateways:    previous.Gateways,
+			PreviousDID: previousDID,
+		},
+		Packet: packet,
+	}
+
+	if opts.UpdatePrevious {
+		updated := previous
+		updated.Doc.Controller = id.String()
+		previousPacket, err := previousID.ToDNSPacket(updated.Doc, updated.Types, updated.Gateways, updated.PreviousDID)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create the previous DID's dns packet")
+		}
+		rotation.PreviousDocument = &updated
+		rotation.PreviousPacket = previousPacket
+	}
+	return &rotation, nil
+}
+
+// rotationOpts returns the options to create the successor of the given DID Document with, retaining everything but
+// its identity key
+func rotationOpts(doc did.Document) (*CreateDIDDHTOpts, error) {
+	var opts CreateDIDDHTOpts
+	opts.Controller = splitDocumentValue(doc.Controller)
+	opts.AlsoKnownAs = splitDocumentValue(doc.AlsoKnownAs)
+
+	purposes := map[did.PublicKeyPurpose][]did.VerificationMethodSet{
+		did.Authentication:       doc.Authentication,
+		did.AssertionMethod:      doc.AssertionMethod,
+		did.KeyAgreement:         doc.KeyAgreement,
+		did.CapabilityInvocation: doc.CapabilityInvocation,
+		did.CapabilityDelegation: doc.CapabilityDelegation,
+	}
+	for _, vm := range doc.VerificationMethod {
+		if vm.ID == doc.ID+"#0" {
+			continue
+		}
+		if vm.PublicKeyJWK == nil {
+			return nil, fmt.Errorf("verification method %s has no public key jwk", vm.ID)
+		}
+		vmFragment := fragment(doc.ID, vm.ID)
+		publicKeyJWK := *vm.PublicKeyJWK
+		vm.PublicKeyJWK = &publicKeyJWK
+		vm.ID = "#" + vmFragment
+		if vm.Controller == doc.ID {
+			vm.Controller = ""
+		}
+
+		var vmPurposes []did.PublicKeyPurpose
+		for _, purpose := range []did.PublicKeyPurpose{did.Authentication, did.AssertionMethod, did.KeyAgreement, did.CapabilityInvocation, did.CapabilityDelegation} {
+			if slices.ContainsFunc(purposes[purpose], func(set did.VerificationMethodSet) bool {
+				id, ok := set.(string)
+				return ok && fragment(doc.ID, id) == vmFragment
+			}) {
+				vmPurposes = append(vmPurposes, purpose)
+			}
+		}
+		opts.VerificationMethods = append(opts.VerificationMethods, VerificationMethod{
+			VerificationMethod: vm,
+			Purposes:           vmPurposes,
+		})
+	}
+
+	for _, s := range doc.Services {
+		s.ID = fragment(doc.ID, s.ID)
+		opts.Services = append(opts.Services, s)
+	}
+	return &opts, nil
+}
+
+// fragment returns the fragment of an identifier relative to the given DID
+func fragment(id, vmID string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(vmID, id), "#")
+}
+
+// splitDocumentValue returns a DID Document value which is either a single string or a list of strings as a list
+func splitDocumentValue(value any) []string {
+	data := parseServiceData(value)
+	if data == "" {
+		return nil
+	}
+	return strings.Split(data, ",")
+}
+
+// WithPreviousDIDs configures the resolver to walk up to maxDepth links of the `_prv._did.` chain of resolved DIDs,
+// reporting the verified predecessors in the document metadata
+func (r *Resolver) WithPreviousDIDs(maxDepth int) *Resolver {
+	r.maxPreviousDIDs = maxDepth
+	return r
+}
+
+// previousDIDs walks the `_prv._did.` chain of the given DID from its record, returning its predecessors starting
+// with the most recent. Each link is verified by the signature of the predecessor's identity key over its successor's
+// identity key, and the walk stops at the first predecessor which cannot be resolved or links to a DID already seen.
+func (r *Resolver) previousDIDs(ctx context.Context, id DHT, record ResolutionRecord) []string {
+	var previous []string
+	seen := map[DHT]bool{id: true}
+	for len(previous) < r.maxPreviousDIDs {
+		msg := new(dns.Msg)
+		if err := msg.Unpack(record.V); err != nil {
+			break
+		}
+		doc, err := id.FromDNSPacket(msg)
+		if err != nil || doc.PreviousDID == nil || seen[doc.PreviousDID.PreviousDID] {
+			break
+		}
+		id = doc.PreviousDID.PreviousDID
+		seen[id] = true
+		previous = append(previous, id.String())
+
+		next, err := r.source.GetResolutionRecord(ctx, id)
+		if err != nil || next == nil {
+			break
+		}
+		record = *next
+	}
+	return previous
+}
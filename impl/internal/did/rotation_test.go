@@ -0,0 +1,172 @@
+package did
+
+import (
+	"context"
+	"testing"
+
+	"github.com/TBD54566975/ssi-sdk/crypto"
+	"github.com/TBD54566975/ssi-sdk/crypto/jwx"
+	"github.com/TBD54566975/ssi-sdk/cryptosuite"
+	"github.com/TBD54566975/ssi-sdk/did"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotateDIDDHT(t *testing.T) {
+	pubKey, _, err := crypto.GenerateEd25519Key()
+	require.NoError(t, err)
+	pubKeyJWK, err := jwx.PublicKeyToPublicKeyJWK(nil, pubKey)
+	require.NoError(t, err)
+
+	privKey, doc, err := GenerateDIDDHT(CreateDIDDHTOpts{
+		AlsoKnownAs: []string{"did:example:efgh"},
+		VerificationMethods: []VerificationMethod{
+			{
+				VerificationMethod: did.VerificationMethod{
+					ID:           "key1",
+					Type:         cryptosuite.JSONWebKeyType,
+					PublicKeyJWK: pubKeyJWK,
+				},
+				Purposes: []did.PublicKeyPurpose{did.AssertionMethod, did.CapabilityInvocation},
+			},
+		},
+		Services: []did.Service{
+			{
+				ID:              "vcs",
+				Type:            "VerifiableCredentialService",
+				ServiceEndpoint: "https://example.com/vc/",
+			},
+		},
+	})
+	require.NoError(t, err)
+	previous := DIDDHTDocument{Doc: *doc, Types: []TypeIndex{Organization}, Gateways: []AuthoritativeGateway{"gateway.example"}}
+
+	t.Run("test rotate", func(t *testing.T) {
+		rotation, err := RotateDIDDHT(privKey, previous, RotateDIDDHTOpts{})
+		require.NoError(t, err)
+		assert.Nil(t, rotation.PreviousPacket)
+
+		newDoc := rotation.Document.Doc
+		assert.NotEqual(t, doc.ID, newDoc.ID)
+		require.Len(t, newDoc.VerificationMethod, 2)
+		assert.Equal(t, newDoc.ID+"#key1", newDoc.VerificationMethod[1].ID)
+		assert.Equal(t, newDoc.ID, newDoc.VerificationMethod[1].Controller)
+		assert.Equal(t, pubKeyJWK.X, newDoc.VerificationMethod[1].PublicKeyJWK.X)
+		assert.Equal(t, []did.VerificationMethodSet{newDoc.ID + "#0"}, newDoc.Authentication)
+		assert.Equal(t, []did.VerificationMethodSet{newDoc.ID + "#0", newDoc.ID + "#key1"}, newDoc.AssertionMethod)
+		assert.Equal(t, []did.VerificationMethodSet{newDoc.ID + "#0", newDoc.ID + "#key1"}, newDoc.CapabilityInvocation)
+		require.Len(t, newDoc.Services, 1)
+		assert.Equal(t, newDoc.ID+"#vcs", newDoc.Services[0].ID)
+		assert.Equal(t, "did:example:efgh", newDoc.AlsoKnownAs)
+
+		// the previous document is left untouched
+		assert.Equal(t, doc.ID+"#key1", previous.Doc.VerificationMethod[1].ID)
+		assert.Equal(t, doc.ID+"#vcs", previous.Doc.Services[0].ID)
+
+		// the new DID's packet links to the previous DID
+		v, err := rotation.Packet.Pack()
+		require.NoError(t, err)
+		msg := new(dns.Msg)
+		require.NoError(t, msg.Unpack(v))
+		decoded, err := DHT(newDoc.ID).FromDNSPacket(msg)
+		require.NoError(t, err)
+		require.NotNil(t, decoded.PreviousDID)
+		assert.Equal(t, DHT(doc.ID), decoded.PreviousDID.PreviousDID)
+		assert.Equal(t, []TypeIndex{Organization}, decoded.Types)
+		assert.Equal(t, []AuthoritativeGateway{"gateway.example."}, decoded.Gateways)
+
+		identityKey, err := DHT(newDoc.ID).IdentityKey()
+		require.NoError(t, err)
+		assert.True(t, identityKey.Equal(rotation.PrivateKey.Public()))
+	})
+
+	t.Run("test rotate and update previous", func(t *testing.T) {
+		rotation, err := RotateDIDDHT(privKey, previous, RotateDIDDHTOpts{UpdatePrevious: true})
+		require.NoError(t, err)
+		require.NotNil(t, rotation.PreviousDocument)
+		assert.Equal(t, rotation.Document.Doc.ID, rotation.PreviousDocument.Doc.Controller)
+		assert.Nil(t, previous.Doc.Controller)
+
+		v, err := rotation.PreviousPacket.Pack()
+		require.NoError(t, err)
+		msg := new(dns.Msg)
+		require.NoError(t, msg.Unpack(v))
+		decoded, err := DHT(doc.ID).FromDNSPacket(msg)
+		require.NoError(t, err)
+		assert.Equal(t, rotation.Document.Doc.ID, decoded.Doc.Controller)
+	})
+
+	t.Run("test rotate failures", func(t *testing.T) {
+		otherKey, _, err := GenerateDIDDHT(CreateDIDDHTOpts{})
+		require.NoError(t, err)
+		_, err = RotateDIDDHT(otherKey, previous, RotateDIDDHTOpts{})
+		assert.ErrorContains(t, err, "private key is not the identity key")
+
+		deactivated := DIDDHTDocument{Doc: did.Document{ID: doc.ID}, Deactivated: true}
+		_, err = RotateDIDDHT(privKey, deactivated, RotateDIDDHTOpts{})
+		assert.ErrorContains(t, err, "deactivated")
+	})
+}
+
+func TestResolvePreviousDIDs(t *testing.T) {
+	ctx := context.Background()
+	records := make(map[DHT]ResolutionRecord)
+	publish := func(t *testing.T, id string, packet *dns.Msg) {
+		v, err := packet.Pack()
+		require.NoError(t, err)
+		records[DHT(id)] = ResolutionRecord{V: v, Seq: 1700000000}
+	}
+
+	// rotate twice, leaving the first DID unpublished
+	firstKey, first, err := GenerateDIDDHT(CreateDIDDHTOpts{})
+	require.NoError(t, err)
+	second, err := RotateDIDDHT(firstKey, DIDDHTDocument{Doc: *first}, RotateDIDDHTOpts{})
+	require.NoError(t, err)
+	publish(t, second.Document.Doc.ID, second.Packet)
+	third, err := RotateDIDDHT(second.PrivateKey, second.Document, RotateDIDDHTOpts{UpdatePrevious: true})
+	require.NoError(t, err)
+	publish(t, third.Document.Doc.ID, third.Packet)
+	publish(t, second.Document.Doc.ID, third.PreviousPacket)
+
+	source := stubResolutionSource{records: records}
+	thirdID := third.Document.Doc.ID
+
+	t.Run("test previous dids are not walked by default", func(t *testing.T) {
+		result := NewResolver(source).Resolve(ctx, thirdID)
+		assert.Empty(t, result.ResolutionMetadata.Error)
+		assert.Empty(t, result.DocumentMetadata.PreviousDIDs)
+	})
+
+	t.Run("test walk previous dids", func(t *testing.T) {
+		result := NewResolver(source).WithPreviousDIDs(10).Resolve(ctx, thirdID)
+		assert.Empty(t, result.ResolutionMetadata.Error)
+		assert.Equal(t, []string{second.Document.Doc.ID, first.ID}, result.DocumentMetadata.PreviousDIDs)
+
+		result = NewResolver(source).WithPreviousDIDs(1).Resolve(ctx, thirdID)
+		assert.Equal(t, []string{second.Document.Doc.ID}, result.DocumentMetadata.PreviousDIDs)
+
+		result = NewResolver(source).WithPreviousDIDs(10).Resolve(ctx, first.ID)
+		assert.Equal(t, ResolutionErrorNotFound, result.ResolutionMetadata.Error)
+	})
+
+	t.Run("test walk stops at cycles", func(t *testing.T) {
+		// a DID linking back to its successor only reports each DID once
+		secondID := DHT(second.Document.Doc.ID)
+		previousDID, err := CreatePreviousDIDRecord(third.PrivateKey, DHT(thirdID), secondID)
+		require.NoError(t, err)
+		packet, err := secondID.ToDNSPacket(second.Document.Doc, nil, nil, previousDID)
+		require.NoError(t, err)
+		cyclic := make(map[DHT]ResolutionRecord)
+		for id, record := range records {
+			cyclic[id] = record
+		}
+		v, err := packet.Pack()
+		require.NoError(t, err)
+		cyclic[secondID] = ResolutionRecord{V: v, Seq: 1700000000}
+
+		result := NewResolver(stubResolutionSource{records: cyclic}).WithPreviousDIDs(10).Resolve(ctx, thirdID)
+		assert.Empty(t, result.ResolutionMetadata.Error)
+		assert.Equal(t, []string{secondID.String()}, result.DocumentMetadata.PreviousDIDs)
+	})
+}
@@ -0,0 +1,133 @@
+package did
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/TBD54566975/ssi-sdk/did"
+	"github.com/miekg/dns"
+)
+
+// MaxDNSPacketSize is the maximum size of a DID's DNS packet, which is stored as the v of a BEP44 record
+// https://www.bittorrent.org/beps/bep_0044.html
+const MaxDNSPacketSize = 1000
+
+// DNSPacketSize is the size report of a DID's DNS packet
+type DNSPacketSize struct {
+	// Size is the size of the packed packet in bytes, with name compression
+	Size int `json:"size"`
+	// Limit is the maximum size of the packet in bytes
+	Limit int `json:"limit"`
+	// Records is the size of each record of the packet
+	Records []DNSRecordSize `json:"records"`
+}
+
+// DNSRecordSize is the size of a record in a DID's DNS packet
+type DNSRecordSize struct {
+	// Name is the name of the record, such as _k0._did.
+	Name string `json:"name"`
+	// Property is the property of the DID Document the record represents, such as a verification method or service
+	Property string `json:"property"`
+	// Size is the size of the record in bytes, without name compression
+	Size int `json:"size"`
+}
+
+// DNSPacketSizeError is returned when a DID's DNS packet does not fit in a BEP44 record
+type DNSPacketSizeError struct {
+	Size  int
+	Limit int
+	// Overflow are the largest properties which can be removed from the DID Document, whose removal brings the packet
+	// within its limit
+	Overflow []DNSRecordSize
+}
+
+func newDNSPacketSizeError(size int, records []DNSRecordSize) *DNSPacketSizeError {
+	removable := slices.DeleteFunc(slices.Clone(records), func(r DNSRecordSize) bool {
+		return r.Property == rootProperty || r.Property == identityKeyProperty
+	})
+	slices.SortStableFunc(removable, func(a, b DNSRecordSize) int {
+		return b.Size - a.Size
+	})
+
+	var overflow []DNSRecordSize
+	for excess := size - MaxDNSPacketSize; excess > 0 && len(removable) > 0; removable = removable[1:] {
+		overflow = append(overflow, removable[0])
+		excess -= removable[0].Size
+	}
+	return &DNSPacketSizeError{Size: size, Limit: MaxDNSPacketSize, Overflow: overflow}
+}
+
+func (e *DNSPacketSizeError) Error() string {
+	msg := fmt.Sprintf("dns packet is %d bytes, exceeding the limit of %d bytes by %d", e.Size, e.Limit, e.Size-e.Limit)
+	if len(e.Overflow) == 0 {
+		return msg
+	}
+	properties := make([]string, 0, len(e.Overflow))
+	for _, r := range e.Overflow {
+		properties = append(properties, fmt.Sprintf("%s (%d bytes)", r.Property, r.Size))
+	}
+	return msg + ", consider removing: " + strings.Join(properties, ", ")
+}
+
+const (
+	rootProperty        = "root"
+	identityKeyProperty = "identity key"
+)
+
+// DNSPacketSize reports the size of the DNS packet of a DID DHT Document, broken down per record, regardless of whether
+// it fits in a BEP44 record
+func (d DHT) DNSPacketSize(doc did.Document, types []TypeIndex, gateways []AuthoritativeGateway, previousDID *PreviousDID) (*DNSPacketSize, error) {
+	msg, err := d.dnsPacket(doc, types, gateways, previousDID)
+	if err != nil {
+		return nil, err
+	}
+	report := dnsPacketSize(doc, msg)
+	return &report, nil
+}
+
+func dnsPacketSize(doc did.Document, msg *dns.Msg) DNSPacketSize {
+	report := DNSPacketSize{Size: msg.Len(), Limit: MaxDNSPacketSize}
+	for _, rr := range msg.Answer {
+		report.Records = append(report.Records, DNSRecordSize{
+			Name:     rr.Header().Name,
+			Property: recordProperty(doc, rr),
+			Size:     dns.Len(rr),
+		})
+	}
+	return report
+}
+
+// recordProperty names the property of the DID Document a record of its DNS packet represents
+func recordProperty(doc did.Document, rr dns.RR) string {
+	name := rr.Header().Name
+	if ns, ok := rr.(*dns.NS); ok {
+		return "gateway " + ns.Ns
+	}
+	label, _, _ := strings.Cut(name, ".")
+	switch {
+	case label == "_prv":
+		return "previousDid"
+	case label == "_cnt":
+		return "controller"
+	case label == "_aka":
+		return "alsoKnownAs"
+	case label == "_typ":
+		return "types"
+	case label == "_did":
+		return rootProperty
+	case strings.HasPrefix(label, "_k"):
+		if i, err := strconv.Atoi(label[2:]); err == nil && i < len(doc.VerificationMethod) {
+			if doc.VerificationMethod[i].ID == doc.ID+"#0" {
+				return identityKeyProperty
+			}
+			return "verificationMethod " + doc.VerificationMethod[i].ID
+		}
+	case strings.HasPrefix(label, "_s"):
+		if i, err := strconv.Atoi(label[2:]); err == nil && i < len(doc.Services) {
+			return "service " + doc.Services[i].ID
+		}
+	}
+	return name
+}
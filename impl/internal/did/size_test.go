@@ -0,0 +1,97 @@
+package did
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/TBD54566975/ssi-sdk/did"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDNSPacketSize(t *testing.T) {
+	t.Run("test compact packet", func(t *testing.T) {
+		_, doc, err := GenerateDIDDHT(CreateDIDDHTOpts{
+			Services: []did.Service{{ID: "vcs", Type: "VerifiableCredentialService", ServiceEndpoint: "https://example.com/vc/"}},
+		})
+		require.NoError(t, err)
+		didID := DHT(doc.ID)
+
+		packet, err := didID.ToDNSPacket(*doc, []TypeIndex{Organization}, []AuthoritativeGateway{"gateway.example"}, nil)
+		require.NoError(t, err)
+
+		// the identity key's id is implied
+		assert.Contains(t, packet.String(), "_k0._did.\t7200\tIN\tTXT\t\"t=0;k=")
+		assert.NotContains(t, packet.String(), "id=0;")
+
+		// names are compressed
+		compressed, err := packet.Pack()
+		require.NoError(t, err)
+		packet.Compress = false
+		uncompressed, err := packet.Pack()
+		require.NoError(t, err)
+		assert.Less(t, len(compressed), len(uncompressed))
+
+		report, err := didID.DNSPacketSize(*doc, []TypeIndex{Organization}, []AuthoritativeGateway{"gateway.example"}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, len(compressed), report.Size)
+		assert.Equal(t, MaxDNSPacketSize, report.Limit)
+
+		var properties []string
+		for _, r := range report.Records {
+			assert.Positive(t, r.Size)
+			properties = append(properties, r.Property)
+		}
+		assert.ElementsMatch(t, []string{
+			"gateway gateway.example.",
+			identityKeyProperty,
+			"service " + doc.ID + "#vcs",
+			rootProperty,
+			"types",
+		}, properties)
+	})
+
+	t.Run("test overflow", func(t *testing.T) {
+		var services []did.Service
+		for i := range 8 {
+			services = append(services, did.Service{
+				ID:              fmt.Sprintf("service-%d", i),
+				Type:            "LinkedDomains",
+				ServiceEndpoint: "https://" + strings.Repeat("a", 100) + ".example.com",
+			})
+		}
+		_, doc, err := GenerateDIDDHT(CreateDIDDHTOpts{Services: services})
+		require.NoError(t, err)
+		didID := DHT(doc.ID)
+
+		_, err = didID.ToDNSPacket(*doc, nil, nil, nil)
+		require.Error(t, err)
+		var sizeErr *DNSPacketSizeError
+		require.True(t, errors.As(err, &sizeErr))
+		assert.Greater(t, sizeErr.Size, MaxDNSPacketSize)
+		require.NotEmpty(t, sizeErr.Overflow)
+		assert.Contains(t, err.Error(), "consider removing: service "+doc.ID+"#service-")
+
+		// the report is available for packets which do not fit
+		report, err := didID.DNSPacketSize(*doc, nil, nil, nil)
+		require.NoError(t, err)
+		assert.Equal(t, sizeErr.Size, report.Size)
+
+		// removing the overflowing properties brings the packet within the limit
+		overflow := make(map[string]bool)
+		for _, r := range sizeErr.Overflow {
+			overflow[r.Property] = true
+		}
+		var remaining []did.Service
+		for _, s := range doc.Services {
+			if !overflow["service "+s.ID] {
+				remaining = append(remaining, s)
+			}
+		}
+		doc.Services = remaining
+		_, err = didID.ToDNSPacket(*doc, nil, nil, nil)
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,82 @@
+package util
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// keyedLimiterSweepInterval is how often idle buckets are dropped
+const keyedLimiterSweepInterval = time.Minute
+
+// KeyedLimiter holds a token bucket per key. Buckets are dropped once they have been idle long enough to refill, since
+// a new bucket is then equivalent.
+type KeyedLimiter struct {
+	limit rate.Limit
+	burst int
+	// refill is how long an empty bucket takes to refill
+	refill time.Duration
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// NewKeyedLimiter returns a limiter for the given limit and burst per key, or nil if the limit is zero and there is no
+// limit
+func NewKeyedLimiter(limit rate.Limit, burst int) *KeyedLimiter {
+	if limit <= 0 {
+		return nil
+	}
+	burst = max(burst, 1)
+	return &KeyedLimiter{
+		limit:   limit,
+		burst:   burst,
+		refill:  time.Duration(float64(burst) / float64(limit) * float64(time.Second)),
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Reserve takes a token from the key's bucket, returning zero if there was one, or how long until there is one
+func (l *KeyedLimiter) Reserve(key string, now time.Time) time.Duration {
+	if l == nil {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.lastSweep) >= keyedLimiterSweepInterval {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.buckets[key] = b
+	}
+	b.lastSeen = now
+
+	reservation := b.limiter.ReserveN(now, 1)
+	delay := reservation.DelayFrom(now)
+	if delay > 0 {
+		// the request is rejected rather than delayed, so it does not use the token
+		reservation.CancelAt(now)
+	}
+	return delay
+}
+
+// sweep drops the buckets which have refilled since they were last used
+func (l *KeyedLimiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if now.Sub(b.lastSeen) >= l.refill {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
@@ -0,0 +1,29 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/time/rate"
+)
+
+func TestKeyedLimiter(t *testing.T) {
+	assert.Zero(t, NewKeyedLimiter(0, 10).Reserve("key", time.Now()))
+
+	l := NewKeyedLimiter(rate.Limit(1), 2)
+	now := time.Now()
+	assert.Zero(t, l.Reserve("key", now))
+	assert.Zero(t, l.Reserve("key", now))
+	assert.Equal(t, time.Second, l.Reserve("key", now))
+	// rejected requests do not take tokens
+	assert.Equal(t, time.Second, l.Reserve("key", now))
+	assert.Zero(t, l.Reserve("other", now))
+	assert.Zero(t, l.Reserve("key", now.Add(time.Second)))
+
+	// buckets are dropped once they have refilled
+	later := now.Add(keyedLimiterSweepInterval)
+	assert.Zero(t, l.Reserve("new", later))
+	assert.Len(t, l.buckets, 1)
+	assert.Contains(t, l.buckets, "new")
+}
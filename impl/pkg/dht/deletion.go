@@ -0,0 +1,69 @@
+package dht
+
+import (
+	"crypto/ed25519"
+	"encoding/binary"
+	"errors"
+
+	"github.com/tv42/zbase32"
+)
+
+// deletionDomain prefixes the payload of a deletion request, so its signature cannot be mistaken for a BEP44 record's
+// signature, whose payload is a bencoded dictionary
+const deletionDomain = "did-dht:delete:"
+
+// DeletionRequest is a request to delete a record from a gateway, signed by the record's key to prove the requester
+// controls it
+type DeletionRequest struct {
+	Key [32]byte
+	// Timestamp is the unix timestamp in seconds at which the request was signed
+	Timestamp int64
+	Signature [64]byte
+}
+
+// NewDeletionRequest returns a new DeletionRequest with the given key, signature, and timestamp, validating the signature
+func NewDeletionRequest(k []byte, sig []byte, timestamp int64) (*DeletionRequest, error) {
+	if len(k) != ed25519.PublicKeySize {
+		return nil, errors.New("incorrect key length for deletion request")
+	}
+	if len(sig) != ed25519.SignatureSize {
+		return nil, errors.New("incorrect sig length for deletion request")
+	}
+	request := DeletionRequest{Key: [32]byte(k), Timestamp: timestamp, Signature: [64]byte(sig)}
+	if err := request.IsValid(); err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// SignDeletionRequest returns a deletion request for the record of the given key, signed at the given unix timestamp
+// in seconds
+func SignDeletionRequest(key ed25519.PrivateKey, timestamp int64) DeletionRequest {
+	request := DeletionRequest{Key: [32]byte(key.Public().(ed25519.PublicKey)), Timestamp: timestamp}
+	request.Signature = [64]byte(ed25519.Sign(key, request.payload()))
+	return request
+}
+
+// IsValid returns ErrInvalidSignature if the signature does not verify against the key
+func (r DeletionRequest) IsValid() error {
+	if !ed25519.Verify(r.Key[:], r.payload(), r.Signature[:]) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// ID returns the base32 encoded key of the record to delete
+func (r DeletionRequest) ID() string {
+	return zbase32.EncodeToString(r.Key[:])
+}
+
+// Bytes returns the request body of a deletion: 64 bytes sig and 8 bytes u64 big-endian timestamp concatenated
+func (r DeletionRequest) Bytes() []byte {
+	return binary.BigEndian.AppendUint64(r.Signature[:], uint64(r.Timestamp))
+}
+
+// payload returns the signed bytes: the deletion domain, the key, and the 8 bytes u64 big-endian timestamp
+func (r DeletionRequest) payload() []byte {
+	payload := append([]byte(deletionDomain), r.Key[:]...)
+	return binary.BigEndian.AppendUint64(payload, uint64(r.Timestamp))
+}
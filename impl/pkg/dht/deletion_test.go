@@ -0,0 +1,50 @@
+package dht_test
+
+import (
+	"crypto/ed25519"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/TBD54566975/did-dht/internal/util"
+	"github.com/TBD54566975/did-dht/pkg/dht"
+)
+
+func TestDeletionRequest(t *testing.T) {
+	pubKey, privKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	now := time.Now().Unix()
+	signed := dht.SignDeletionRequest(privKey, now)
+	assert.NoError(t, signed.IsValid())
+	assert.Equal(t, util.Z32Encode(pubKey), signed.ID())
+
+	// round trip the request body
+	body := signed.Bytes()
+	require.Len(t, body, 72)
+	request, err := dht.NewDeletionRequest(pubKey, body[:64], int64(binary.BigEndian.Uint64(body[64:])))
+	require.NoError(t, err)
+	assert.Equal(t, signed, *request)
+
+	t.Run("test the signature covers the timestamp", func(t *testing.T) {
+		_, err := dht.NewDeletionRequest(pubKey, signed.Signature[:], now+1)
+		assert.ErrorIs(t, err, dht.ErrInvalidSignature)
+	})
+
+	t.Run("test the signature covers the key", func(t *testing.T) {
+		otherKey, _, err := ed25519.GenerateKey(nil)
+		require.NoError(t, err)
+		_, err = dht.NewDeletionRequest(otherKey, signed.Signature[:], now)
+		assert.ErrorIs(t, err, dht.ErrInvalidSignature)
+	})
+
+	t.Run("test invalid lengths", func(t *testing.T) {
+		_, err := dht.NewDeletionRequest(pubKey[:31], signed.Signature[:], now)
+		assert.EqualError(t, err, "incorrect key length for deletion request")
+		_, err = dht.NewDeletionRequest(pubKey, signed.Signature[:63], now)
+		assert.EqualError(t, err, "incorrect sig length for deletion request")
+	})
+}
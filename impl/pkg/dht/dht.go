@@ -16,8 +16,10 @@ import (
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/metric"
 	"golang.org/x/time/rate"
 
+	dhtint "github.com/TBD54566975/did-dht/internal/dht"
 	"github.com/TBD54566975/did-dht/internal/util"
 	"github.com/TBD54566975/did-dht/pkg/telemetry"
 )
@@ -25,6 +27,8 @@ import (
 // DHT is a wrapper around anacrolix/dht that implements the BEP-44 DHT protocol.
 type DHT struct {
 	*dht.Server
+	sendLimiter  *rate.Limiter
+	routingTable metric.Registration
 }
 
 // NewDHT returns a new instance of DHT with the given bootstrap peers.
@@ -53,7 +57,7 @@ func NewDHT(bootstrapPeers []string) (*DHT, error) {
 	} else {
 		logrus.WithField("bootstrap_peers", tried.NumResponses).Info("bootstrapped DHT successfully")
 	}
-	return &DHT{Server: s}, nil
+	return newDHT(s, c.SendLimiter), nil
 }
 
 // NewTestDHT returns a new instance of DHT that does not make external connections
@@ -78,7 +82,37 @@ func NewTestDHT(t *testing.T, bootstrapPeers ...dht.Addr) *DHT {
 		t.Fatalf("failed to bootstrap: %v", err)
 	}
 
-	return &DHT{Server: s}
+	return newDHT(s, c.SendLimiter)
+}
+
+// newDHT wraps the server, reporting the size of its routing table in metrics until it is closed
+func newDHT(s *dht.Server, sendLimiter *rate.Limiter) *DHT {
+	d := &DHT{Server: s, sendLimiter: sendLimiter}
+	registration, err := telemetry.ObserveRoutingTable(func() (int, int) {
+		stats := s.Stats()
+		return stats.Nodes, stats.GoodNodes
+	})
+	if err != nil {
+		logrus.WithError(err).Warn("failed to observe dht routing table size")
+	} else {
+		d.routingTable = registration
+	}
+	return d
+}
+
+// Close stops reporting the routing table size and closes the server
+func (d *DHT) Close() {
+	if d.routingTable != nil {
+		if err := d.routingTable.Unregister(); err != nil {
+			logrus.WithError(err).Warn("failed to stop observing dht routing table size")
+		}
+	}
+	d.Server.Close()
+}
+
+// SendLimiter returns the limiter shared by all messages the DHT sends
+func (d *DHT) SendLimiter() *rate.Limiter {
+	return d.sendLimiter
 }
 
 // Put puts the given BEP-44 value into the DHT and returns its z32-encoded key.
@@ -86,6 +120,13 @@ func (d *DHT) Put(ctx context.Context, request bep44.Put) (string, error) {
 	ctx, span := telemetry.GetTracer().Start(ctx, "DHT.Put")
 	defer span.End()
 
+	start := time.Now()
+	key, err := d.put(ctx, request)
+	telemetry.RecordDHTOperation(ctx, telemetry.DHTOperationPut, time.Since(start), err)
+	return key, err
+}
+
+func (d *DHT) put(ctx context.Context, request bep44.Put) (string, error) {
 	// Check if there are any nodes in the DHT
 	if len(d.Server.Nodes()) == 0 {
 		logrus.WithContext(ctx).Warn("no nodes available in the DHT for publishing")
@@ -117,9 +158,18 @@ func (d *DHT) GetFull(ctx context.Context, key string) (*getput.GetResult, error
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to decode key [%s]", key)
 	}
+	start := time.Now()
 	res, t, err := getput.Get(ctx, infohash.HashBytes(z32Decoded), d.Server, nil, nil)
+	telemetry.RecordDHTOperation(ctx, telemetry.DHTOperationGet, time.Since(start), err)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get key[%s] from dht; tried %d nodes, got %d responses", key, t.NumAddrsTried, t.NumResponses)
+		if t == nil {
+			return nil, errors.Wrapf(err, "failed to get key[%s] from dht", key)
+		}
+		// the lookup stalls without an error of its own when no node has the value
+		if ctx.Err() == nil {
+			err = dhtint.ErrValueNotFound
+		}
+		return nil, errors.Wrapf(err, "failed to get key[%s] from dht; tried %d nodes, got %d responses", key, t.NumAddrsTried, t.NumResponses)
 	}
 	return &res, nil
 }
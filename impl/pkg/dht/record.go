@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/binary"
 	"errors"
 	"fmt"
 
@@ -14,6 +15,9 @@ import (
 	"github.com/tv42/zbase32"
 )
 
+// ErrInvalidSignature is returned when a BEP44 record's signature does not verify against its key
+var ErrInvalidSignature = errors.New("signature is invalid")
+
 type BEP44Response struct {
 	V   []byte   `validate:"required"`
 	Seq int64    `validate:"required"`
@@ -25,6 +29,13 @@ func (r BEP44Response) Equals(other BEP44Response) bool {
 	return r.Seq == other.Seq && bytes.Equal(r.V, other.V) && r.Sig == other.Sig
 }
 
+// Bytes returns the response as 64 bytes sig, 8 bytes u64 big-endian seq, and 0-1000 bytes of v concatenated
+func (r BEP44Response) Bytes() []byte {
+	var seqBuf [8]byte
+	binary.BigEndian.PutUint64(seqBuf[:], uint64(r.Seq))
+	return append(r.Sig[:], append(seqBuf[:], r.V...)...)
+}
+
 // BEP44Record represents a record in the DHT
 type BEP44Record struct {
 	Value          []byte   `json:"v" validate:"required"`
@@ -33,10 +44,59 @@ type BEP44Record struct {
 	SequenceNumber int64    `json:"seq" validate:"required"`
 }
 
-// FailedRecord represents a record that failed to be written to the DHT
+// FailedRecord represents a record that failed to be written to the DHT, and is retried with exponential backoff until
+// it is written or dead-lettered
 type FailedRecord struct {
-	ID    string `json:"id"`
-	Count int    `json:"count"`
+	ID string `json:"id"`
+	// Count is the number of consecutive failed attempts
+	Count int `json:"count"`
+	// LastError is the error of the most recent failed attempt
+	LastError string `json:"lastError,omitempty"`
+	// FirstFailure and LastFailure are the unix timestamps in seconds of the first and most recent failed attempts
+	FirstFailure int64 `json:"firstFailure,omitempty"`
+	LastFailure  int64 `json:"lastFailure,omitempty"`
+	// NextAttempt is the unix timestamp in seconds at which the record is next retried
+	NextAttempt int64 `json:"nextAttempt,omitempty"`
+	// DeadLettered is set once the record has failed too many times to be retried, leaving it for inspection until it
+	// is next written successfully
+	DeadLettered bool `json:"deadLettered,omitempty"`
+}
+
+// RetainedRecord represents a record in the Retained DID Set https://did-dht.com/#retained-did-set
+type RetainedRecord struct {
+	ID string `json:"id"`
+	// Expiry is the unix timestamp in seconds at which the record will be evicted from the set
+	Expiry int64 `json:"expiry"`
+}
+
+// ScheduledRecord is the republish schedule of a record
+type ScheduledRecord struct {
+	ID string `json:"id"`
+	// Due is the unix timestamp in seconds at which the record is next republished
+	Due int64 `json:"due"`
+}
+
+// OutboxRecord is a record which has been written to storage and is waiting to be put into the DHT
+type OutboxRecord struct {
+	ID string `json:"id"`
+	// Seq is the sequence number of the record which was written
+	Seq int64 `json:"seq"`
+	// Queued is the unix timestamp in seconds at which the record was queued
+	Queued int64 `json:"queued"`
+}
+
+// Tombstone is a deleted record, whose key is not stored, cached, or republished again until the tombstone expires
+type Tombstone struct {
+	ID string `json:"id"`
+	// Deleted is the unix timestamp in seconds at which the record was deleted
+	Deleted int64 `json:"deleted"`
+	// Expiry is the unix timestamp in seconds at which the tombstone expires, zero if it never expires
+	Expiry int64 `json:"expiry"`
+}
+
+// IsActive returns true if the tombstone has not expired at the given unix timestamp in seconds
+func (t Tombstone) IsActive(now int64) bool {
+	return t.Expiry == 0 || t.Expiry > now
 }
 
 // NewBEP44Record returns a new BEP44Record with the given key, value, signature, and sequence number
@@ -78,7 +138,7 @@ func (r BEP44Record) IsValid() error {
 	}
 
 	if !bep44.Verify(r.Key[:], nil, r.SequenceNumber, bv, r.Signature[:]) {
-		return errors.New("signature is invalid")
+		return ErrInvalidSignature
 	}
 	return nil
 }
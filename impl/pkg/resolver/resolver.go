@@ -0,0 +1,62 @@
+package resolver
+
+import (
+	"github.com/TBD54566975/did-dht/internal/did"
+	"github.com/TBD54566975/did-dht/pkg/dht"
+)
+
+// The did:dht resolver, exposed for use outside of this module https://did-dht.com/#resolving-a-did
+type (
+	Resolver           = did.Resolver
+	Result             = did.ResolutionResult
+	DocumentMetadata   = did.DocumentMetadata
+	ResolutionMetadata = did.ResolutionMetadata
+	Error              = did.ResolutionError
+	Record             = did.ResolutionRecord
+	Source             = did.ResolutionSource
+	// VerificationError is returned when a gateway's record cannot be verified against the DID's identity key
+	VerificationError = did.VerificationError
+	// GatewayClientOption configures the client used to query did:dht Gateways, such as its HTTP client and retries
+	GatewayClientOption = did.GatewayClientOption
+)
+
+const (
+	ErrorInvalidDID         = did.ResolutionErrorInvalidDID
+	ErrorNotFound           = did.ResolutionErrorNotFound
+	ErrorMethodNotSupported = did.ResolutionErrorMethodNotSupported
+	ErrorInternal           = did.ResolutionErrorInternal
+
+	ContentType = did.ResolutionContentType
+
+	// DHTSourceName is the source reported for DIDs resolved directly from the DHT
+	DHTSourceName = did.DHTResolutionSourceName
+)
+
+// NewResolver returns a new resolver which resolves DIDs from the given source
+func NewResolver(source Source) *Resolver {
+	return did.NewResolver(source)
+}
+
+// NewGatewayResolver returns a new resolver which resolves DIDs from the did:dht Gateway at the given URL
+func NewGatewayResolver(gatewayURL string, opts ...GatewayClientOption) (*Resolver, error) {
+	client, err := did.NewGatewayClient(gatewayURL, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return did.NewResolver(client), nil
+}
+
+// NewAuthoritativeResolver returns a new resolver which resolves DIDs from the did:dht Gateway at the given URL and,
+// if it is not nil, the DHT, and then from the authoritative gateways of each DID, using the record with the highest
+// sequence number
+func NewAuthoritativeResolver(gatewayURL string, d *dht.DHT, opts ...GatewayClientOption) (*Resolver, error) {
+	client, err := did.NewGatewayClient(gatewayURL, opts...)
+	if err != nil {
+		return nil, err
+	}
+	sources := []Source{client}
+	if d != nil {
+		sources = append(sources, did.NewDHTResolutionSource(d))
+	}
+	return did.NewResolver(did.NewAuthoritativeResolutionSource(sources...).WithGatewayClientOptions(opts...)), nil
+}
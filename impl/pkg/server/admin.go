@@ -0,0 +1,433 @@
+package server
+
+import (
+	"crypto/ed25519"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+
+	"github.com/TBD54566975/did-dht/internal/util"
+	"github.com/TBD54566975/did-dht/pkg/service"
+	"github.com/TBD54566975/did-dht/pkg/telemetry"
+)
+
+const (
+	// defaultPageSize is the number of records listed when no page size is given
+	defaultPageSize = 100
+	// maxPageSize is the largest number of records listed at once
+	maxPageSize = 1000
+)
+
+// AdminRouter is the router for the operator admin API
+type AdminRouter struct {
+	service *service.DHTService
+}
+
+// NewAdminRouter returns a new instance of the admin router
+func NewAdminRouter(service *service.DHTService) (*AdminRouter, error) {
+	return &AdminRouter{service: service}, nil
+}
+
+// adminAuth rejects requests which do not carry the admin token as a bearer token
+func adminAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		got, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			c.Header("WWW-Authenticate", `Bearer realm="admin"`)
+			LoggingRespondErrMsg(c, "missing or invalid admin token", http.StatusUnauthorized)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// AdminRecord is a stored record
+type AdminRecord struct {
+	ID  string `json:"id"`
+	Seq int64  `json:"seq"`
+}
+
+// ListRecordsResponse is a page of stored records
+type ListRecordsResponse struct {
+	Records []AdminRecord `json:"records"`
+	// NextPageToken is passed as the page_token of the request for the next page, and is empty on the last page
+	NextPageToken string `json:"next_page_token,omitempty"`
+}
+
+// ListRecords godoc
+//
+//	@Summary		ListRecords lists the stored records
+//	@Description	ListRecords lists the stored records, paginated by page token
+//	@Tags			Admin
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			page_token	query		string	false	"Token of the page to list, from the previous page"
+//	@Param			page_size	query		integer	false	"Maximum number of records to return"	default(100)
+//	@Success		200			{object}	ListRecordsResponse
+//	@Failure		400			{string}	string	"Invalid request"
+//	@Failure		401			{string}	string	"Unauthorized"
+//	@Failure		500			{string}	string	"Internal server error"
+//	@Router			/admin/records [get]
+func (r *AdminRouter) ListRecords(c *gin.Context) {
+	ctx, span := telemetry.GetTracer().Start(c, "AdminHTTP.ListRecords")
+	defer span.End()
+
+	pageSize, err := GetIntQueryParam(c, "page_size", defaultPageSize)
+	if err != nil || pageSize <= 0 || pageSize > maxPageSize {
+		LoggingRespondErrMsg(c, fmt.Sprintf("page_size must be an integer between 1 and %d", maxPageSize), http.StatusBadRequest)
+		return
+	}
+	var pageToken []byte
+	if token := c.Query("page_token"); token != "" {
+		if pageToken, err = base64.RawURLEncoding.DecodeString(token); err != nil {
+			LoggingRespondErrWithMsg(c, err, "invalid page_token", http.StatusBadRequest)
+			return
+		}
+	}
+
+	records, nextPageToken, err := r.service.ListRecords(ctx, pageToken, pageSize)
+	if err != nil {
+		LoggingRespondErrWithMsg(c, err, "failed to list records", http.StatusInternalServerError)
+		return
+	}
+	resp := ListRecordsResponse{Records: make([]AdminRecord, 0, len(records))}
+	for _, record := range records {
+		resp.Records = append(resp.Records, AdminRecord{ID: record.ID(), Seq: record.SequenceNumber})
+	}
+	if nextPageToken != nil {
+		resp.NextPageToken = base64.RawURLEncoding.EncodeToString(nextPageToken)
+	}
+	Respond(c, resp, http.StatusOK)
+}
+
+// TombstoneResponse is the tombstone of a deleted record, whose key is refused until it expires
+type TombstoneResponse struct {
+	ID string `json:"id"`
+	// Deleted and Expiry are unix timestamps in seconds, Expiry is zero if the tombstone never expires
+	Deleted int64 `json:"deleted"`
+	Expiry  int64 `json:"expiry"`
+}
+
+// DeleteRecord godoc
+//
+//	@Summary		DeleteRecord deletes a record without a request signed by its controller
+//	@Description	DeleteRecord deletes a stored record and tombstones its key, so it is not stored, cached, or republished again until the tombstone expires
+//	@Tags			Admin
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	path		string	true	"DID or z-base-32 encoded ID of the record"
+//	@Success		200	{object}	TombstoneResponse
+//	@Failure		400	{string}	string	"Bad request"
+//	@Failure		401	{string}	string	"Unauthorized"
+//	@Failure		500	{string}	string	"Internal server error"
+//	@Router			/admin/records/{id} [delete]
+func (r *AdminRouter) DeleteRecord(c *gin.Context) {
+	ctx, span := telemetry.GetTracer().Start(c, "AdminHTTP.DeleteRecord")
+	defer span.End()
+
+	id, ok := recordIDFromParam(c)
+	if !ok {
+		return
+	}
+
+	tombstone, err := r.service.ForceDeleteRecord(ctx, id)
+	if err != nil {
+		LoggingRespondErrWithMsg(c, err, fmt.Sprintf("failed to delete record: %s", id), http.StatusInternalServerError)
+		return
+	}
+	Respond(c, TombstoneResponse{ID: tombstone.ID, Deleted: tombstone.Deleted, Expiry: tombstone.Expiry}, http.StatusOK)
+}
+
+// ListTombstones godoc
+//
+//	@Summary		ListTombstones lists the tombstones of deleted records
+//	@Description	ListTombstones lists the tombstones of deleted records, including expired tombstones which have not been pruned
+//	@Tags			Admin
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{array}		TombstoneResponse
+//	@Failure		401	{string}	string	"Unauthorized"
+//	@Failure		500	{string}	string	"Internal server error"
+//	@Router			/admin/tombstones [get]
+func (r *AdminRouter) ListTombstones(c *gin.Context) {
+	ctx, span := telemetry.GetTracer().Start(c, "AdminHTTP.ListTombstones")
+	defer span.End()
+
+	tombstones, err := r.service.ListTombstones(ctx)
+	if err != nil {
+		LoggingRespondErrWithMsg(c, err, "failed to list tombstones", http.StatusInternalServerError)
+		return
+	}
+	resp := make([]TombstoneResponse, 0, len(tombstones))
+	for _, tombstone := range tombstones {
+		resp = append(resp, TombstoneResponse{ID: tombstone.ID, Deleted: tombstone.Deleted, Expiry: tombstone.Expiry})
+	}
+	Respond(c, resp, http.StatusOK)
+}
+
+// LiftTombstone godoc
+//
+//	@Summary		LiftTombstone lifts the tombstone of a deleted record
+//	@Description	LiftTombstone removes the tombstone of a deleted record before it expires, so its key can be stored again
+//	@Tags			Admin
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	path	string	true	"DID or z-base-32 encoded ID of the record"
+//	@Success		200
+//	@Failure		400	{string}	string	"Bad request"
+//	@Failure		401	{string}	string	"Unauthorized"
+//	@Failure		404	{string}	string	"Not found"
+//	@Failure		500	{string}	string	"Internal server error"
+//	@Router			/admin/tombstones/{id} [delete]
+func (r *AdminRouter) LiftTombstone(c *gin.Context) {
+	ctx, span := telemetry.GetTracer().Start(c, "AdminHTTP.LiftTombstone")
+	defer span.End()
+
+	id, ok := recordIDFromParam(c)
+	if !ok {
+		return
+	}
+
+	lifted, err := r.service.LiftTombstone(ctx, id)
+	if err != nil {
+		LoggingRespondErrWithMsg(c, err, fmt.Sprintf("failed to lift tombstone: %s", id), http.StatusInternalServerError)
+		return
+	}
+	if !lifted {
+		LoggingRespondErrMsg(c, fmt.Sprintf("tombstone not found: %s", id), http.StatusNotFound)
+		return
+	}
+	ResponseStatus(c, http.StatusOK)
+}
+
+// FailedRecordResponse is a record which failed to be put into the DHT
+type FailedRecordResponse struct {
+	ID string `json:"id"`
+	// Attempts is the number of consecutive failed puts
+	Attempts  int    `json:"attempts"`
+	LastError string `json:"last_error,omitempty"`
+	// FirstFailure, LastFailure, and NextAttempt are unix timestamps in seconds
+	FirstFailure int64 `json:"first_failure,omitempty"`
+	LastFailure  int64 `json:"last_failure,omitempty"`
+	NextAttempt  int64 `json:"next_attempt,omitempty"`
+	// DeadLettered is set once the record is no longer retried
+	DeadLettered bool `json:"dead_lettered"`
+}
+
+// ListFailedRecords godoc
+//
+//	@Summary		ListFailedRecords lists the records which failed to be put into the DHT
+//	@Description	ListFailedRecords lists the records which failed to be put into the DHT and are retrying or dead-lettered
+//	@Tags			Admin
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{array}		FailedRecordResponse
+//	@Failure		401	{string}	string	"Unauthorized"
+//	@Failure		500	{string}	string	"Internal server error"
+//	@Router			/admin/failed [get]
+func (r *AdminRouter) ListFailedRecords(c *gin.Context) {
+	ctx, span := telemetry.GetTracer().Start(c, "AdminHTTP.ListFailedRecords")
+	defer span.End()
+
+	failedRecords, err := r.service.ListFailedRecords(ctx)
+	if err != nil {
+		LoggingRespondErrWithMsg(c, err, "failed to list failed records", http.StatusInternalServerError)
+		return
+	}
+	resp := make([]FailedRecordResponse, 0, len(failedRecords))
+	for _, failed := range failedRecords {
+		resp = append(resp, FailedRecordResponse{
+			ID:           failed.ID,
+			Attempts:     failed.Count,
+			LastError:    failed.LastError,
+			FirstFailure: failed.FirstFailure,
+			LastFailure:  failed.LastFailure,
+			NextAttempt:  failed.NextAttempt,
+			DeadLettered: failed.DeadLettered,
+		})
+	}
+	Respond(c, resp, http.StatusOK)
+}
+
+// RepublishRecord godoc
+//
+//	@Summary		RepublishRecord puts a stored record into the DHT immediately
+//	@Description	RepublishRecord queues a stored record, including a dead-lettered record, to be put into the DHT immediately
+//	@Tags			Admin
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	path	string	true	"DID or z-base-32 encoded ID of the record"
+//	@Success		202
+//	@Failure		400	{string}	string	"Bad request"
+//	@Failure		401	{string}	string	"Unauthorized"
+//	@Failure		404	{string}	string	"Not found"
+//	@Failure		500	{string}	string	"Internal server error"
+//	@Router			/admin/republish/{id} [post]
+func (r *AdminRouter) RepublishRecord(c *gin.Context) {
+	ctx, span := telemetry.GetTracer().Start(c, "AdminHTTP.RepublishRecord")
+	defer span.End()
+
+	id, ok := recordIDFromParam(c)
+	if !ok {
+		return
+	}
+
+	queued, err := r.service.RepublishRecord(ctx, id)
+	if err != nil {
+		LoggingRespondErrWithMsg(c, err, fmt.Sprintf("failed to republish record: %s", id), http.StatusInternalServerError)
+		return
+	}
+	if queued == nil {
+		LoggingRespondErrMsg(c, fmt.Sprintf("dht record not found: %s", id), http.StatusNotFound)
+		return
+	}
+	ResponseStatus(c, http.StatusAccepted)
+}
+
+// RepublishAll godoc
+//
+//	@Summary		RepublishAll republishes every stored record immediately
+//	@Description	RepublishAll schedules every stored record which should be republished to be republished immediately, in the background
+//	@Tags			Admin
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		202
+//	@Failure		401	{string}	string	"Unauthorized"
+//	@Failure		409	{string}	string	"Already running"
+//	@Failure		500	{string}	string	"Internal server error"
+//	@Router			/admin/republish [post]
+func (r *AdminRouter) RepublishAll(c *gin.Context) {
+	_, span := telemetry.GetTracer().Start(c, "AdminHTTP.RepublishAll")
+	defer span.End()
+
+	if err := r.service.RepublishAll(); err != nil {
+		if errors.Is(err, service.ErrRepublishRunning) {
+			LoggingRespondErrWithMsg(c, err, "failed to republish records", http.StatusConflict)
+			return
+		}
+		LoggingRespondErrWithMsg(c, err, "failed to republish records", http.StatusInternalServerError)
+		return
+	}
+	ResponseStatus(c, http.StatusAccepted)
+}
+
+// RepublishRunResponse is a pass over the stored records which reconciled them with the republish schedule
+type RepublishRunResponse struct {
+	// Trigger is one of startup, schedule, or admin
+	Trigger string `json:"trigger"`
+	// Started and Finished are unix timestamps in seconds
+	Started  int64 `json:"started"`
+	Finished int64 `json:"finished"`
+	// RecordCount is the number of stored records
+	RecordCount int `json:"record_count"`
+	// Scheduled is the number of records scheduled, which for admin runs are due immediately
+	Scheduled int `json:"scheduled"`
+	// Unscheduled is the number of records which are no longer republished
+	Unscheduled int `json:"unscheduled"`
+	// Error is set if the run did not complete
+	Error string `json:"error,omitempty"`
+}
+
+// ListRepublishRuns godoc
+//
+//	@Summary		ListRepublishRuns lists the recent republish runs
+//	@Description	ListRepublishRuns lists the recent passes over the stored records since the gateway started, most recent first
+//	@Tags			Admin
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{array}		RepublishRunResponse
+//	@Failure		401	{string}	string	"Unauthorized"
+//	@Router			/admin/republish/runs [get]
+func (r *AdminRouter) ListRepublishRuns(c *gin.Context) {
+	_, span := telemetry.GetTracer().Start(c, "AdminHTTP.ListRepublishRuns")
+	defer span.End()
+
+	runs := r.service.ListRepublishRuns()
+	resp := make([]RepublishRunResponse, 0, len(runs))
+	for _, run := range runs {
+		resp = append(resp, RepublishRunResponse{
+			Trigger:     string(run.Trigger),
+			Started:     run.Started.Unix(),
+			Finished:    run.Finished.Unix(),
+			RecordCount: run.RecordCount,
+			Scheduled:   run.Scheduled,
+			Unscheduled: run.Unscheduled,
+			Error:       run.Error,
+		})
+	}
+	Respond(c, resp, http.StatusOK)
+}
+
+// EvictCacheResponse reports which caches a key was evicted from
+type EvictCacheResponse struct {
+	// Record is set if the key was in the cache of resolved records
+	Record bool `json:"record"`
+	// BadGet is set if the key was in the cache of keys which recently failed to resolve
+	BadGet bool `json:"bad_get"`
+}
+
+// EvictCache godoc
+//
+//	@Summary		EvictCache evicts a key from the caches
+//	@Description	EvictCache evicts a key from the cache of resolved records and the cache of keys which recently failed to resolve
+//	@Tags			Admin
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	path		string	true	"DID or z-base-32 encoded ID of the record"
+//	@Success		200	{object}	EvictCacheResponse
+//	@Failure		400	{string}	string	"Bad request"
+//	@Failure		401	{string}	string	"Unauthorized"
+//	@Failure		500	{string}	string	"Internal server error"
+//	@Router			/admin/cache/{id} [delete]
+func (r *AdminRouter) EvictCache(c *gin.Context) {
+	_, span := telemetry.GetTracer().Start(c, "AdminHTTP.EvictCache")
+	defer span.End()
+
+	id, ok := recordIDFromParam(c)
+	if !ok {
+		return
+	}
+
+	record, badGet, err := r.service.EvictCache(id)
+	if err != nil {
+		LoggingRespondErrWithMsg(c, err, fmt.Sprintf("failed to evict key: %s", id), http.StatusInternalServerError)
+		return
+	}
+	Respond(c, EvictCacheResponse{Record: record, BadGet: badGet}, http.StatusOK)
+}
+
+// recordIDFromParam returns the z-base-32 encoded record ID of the id path parameter, which may be a DID or the ID
+// itself, responding with an error if it is not a valid identity key
+func recordIDFromParam(c *gin.Context) (string, bool) {
+	param := GetParam(c, IDParam)
+	if param == nil || *param == "" {
+		LoggingRespondErrMsg(c, "missing id param", http.StatusBadRequest)
+		return "", false
+	}
+	id, err := didFromParam(*param).Suffix()
+	if err != nil {
+		LoggingRespondErrWithMsg(c, err, fmt.Sprintf("invalid id: %s", *param), http.StatusBadRequest)
+		return "", false
+	}
+	key, err := util.Z32Decode(id)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		LoggingRespondErrMsg(c, fmt.Sprintf("invalid z32 encoded ed25519 public key: %s", id), http.StatusBadRequest)
+		return "", false
+	}
+	return id, true
+}
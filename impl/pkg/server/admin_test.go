@@ -0,0 +1,177 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/TBD54566975/did-dht/config"
+	"github.com/TBD54566975/did-dht/internal/did"
+	"github.com/TBD54566975/did-dht/pkg/dht"
+)
+
+const testAdminToken = "test-admin-token"
+
+func TestAdminAPI(t *testing.T) {
+	serviceConfig, err := config.LoadConfig("")
+	require.NoError(t, err)
+	serviceConfig.ServerConfig.StorageURI = "bolt://admin.db"
+	serviceConfig.ServerConfig.AdminToken = testAdminToken
+
+	server, err := NewServer(serviceConfig, make(chan os.Signal, 1), dht.NewTestDHT(t))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = server.Shutdown(context.Background())
+		_ = os.Remove("admin.db")
+	})
+
+	serve := func(method, path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, testServerURL+path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		server.Handler.ServeHTTP(w, req)
+		return w
+	}
+
+	// publish a record to administer
+	didID, reqData := generateDIDPutRequest(t)
+	suffix, err := did.DHT(didID).Suffix()
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	server.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodPut, fmt.Sprintf("%s/%s", testServerURL, suffix), bytes.NewReader(reqData)))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	t.Run("test missing or invalid token", func(t *testing.T) {
+		w := serve(http.MethodGet, "/admin/records", "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.NotEmpty(t, w.Header().Get("WWW-Authenticate"))
+
+		w = serve(http.MethodGet, "/admin/records", "wrong-token")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("test list records", func(t *testing.T) {
+		w := serve(http.MethodGet, "/admin/records?page_size=10", testAdminToken)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var resp ListRecordsResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		require.Len(t, resp.Records, 1)
+		assert.Equal(t, suffix, resp.Records[0].ID)
+		assert.Empty(t, resp.NextPageToken)
+
+		w = serve(http.MethodGet, "/admin/records?page_size=0", testAdminToken)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		w = serve(http.MethodGet, "/admin/records?page_token=***", testAdminToken)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("test list failed records", func(t *testing.T) {
+		w := serve(http.MethodGet, "/admin/failed", testAdminToken)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var resp []FailedRecordResponse
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	})
+
+	t.Run("test republish record", func(t *testing.T) {
+		w := serve(http.MethodPost, "/admin/republish/"+didID, testAdminToken)
+		assert.Equal(t, http.StatusAccepted, w.Code)
+
+		w = serve(http.MethodPost, "/admin/republish/"+suffix, testAdminToken)
+		assert.Equal(t, http.StatusAccepted, w.Code)
+
+		unknown, _ := generateDIDPutRequest(t)
+		w = serve(http.MethodPost, "/admin/republish/"+unknown, testAdminToken)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = serve(http.MethodPost, "/admin/republish/invalid", testAdminToken)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("test republish all and list runs", func(t *testing.T) {
+		w := serve(http.MethodPost, "/admin/republish", testAdminToken)
+		assert.Equal(t, http.StatusAccepted, w.Code)
+
+		assert.Eventually(t, func() bool {
+			w := serve(http.MethodGet, "/admin/republish/runs", testAdminToken)
+			require.Equal(t, http.StatusOK, w.Code)
+
+			var runs []RepublishRunResponse
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&runs))
+			return len(runs) > 0 && runs[0].Trigger == "admin" && runs[0].RecordCount == 1 && runs[0].Scheduled == 1
+		}, 15*time.Second, 100*time.Millisecond)
+	})
+
+	t.Run("test evict cache", func(t *testing.T) {
+		// the published record is cached
+		w := serve(http.MethodDelete, "/admin/cache/"+suffix, testAdminToken)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var resp EvictCacheResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		assert.True(t, resp.Record)
+		assert.False(t, resp.BadGet)
+
+		w = serve(http.MethodDelete, "/admin/cache/"+suffix, testAdminToken)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		assert.False(t, resp.Record)
+	})
+
+	t.Run("test delete record and lift tombstone", func(t *testing.T) {
+		w := serve(http.MethodDelete, "/admin/records/"+didID, testAdminToken)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var tombstone TombstoneResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&tombstone))
+		assert.Equal(t, suffix, tombstone.ID)
+		assert.Greater(t, tombstone.Expiry, tombstone.Deleted)
+
+		w = serve(http.MethodGet, "/admin/tombstones", testAdminToken)
+		require.Equal(t, http.StatusOK, w.Code)
+		var tombstones []TombstoneResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&tombstones))
+		assert.Equal(t, []TombstoneResponse{tombstone}, tombstones)
+
+		// the deleted record is no longer accepted, until its tombstone is lifted
+		w = httptest.NewRecorder()
+		server.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodPut, fmt.Sprintf("%s/%s", testServerURL, suffix), bytes.NewReader(reqData)))
+		assert.Equal(t, http.StatusGone, w.Code)
+
+		w = serve(http.MethodDelete, "/admin/tombstones/"+suffix, testAdminToken)
+		assert.Equal(t, http.StatusOK, w.Code)
+		w = serve(http.MethodDelete, "/admin/tombstones/"+suffix, testAdminToken)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = httptest.NewRecorder()
+		server.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodPut, fmt.Sprintf("%s/%s", testServerURL, suffix), bytes.NewReader(reqData)))
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("test admin api disabled without token", func(t *testing.T) {
+		serviceConfig.ServerConfig.AdminToken = ""
+		serviceConfig.ServerConfig.StorageURI = "bolt://admin-disabled.db"
+		server, err := NewServer(serviceConfig, make(chan os.Signal, 1), dht.NewTestDHT(t))
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = server.Shutdown(context.Background())
+			_ = os.Remove("admin-disabled.db")
+		})
+
+		w := httptest.NewRecorder()
+		server.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, testServerURL+"/admin/records", nil))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
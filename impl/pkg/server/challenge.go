@@ -0,0 +1,74 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+
+	"github.com/TBD54566975/did-dht/pkg/service"
+	"github.com/TBD54566975/did-dht/pkg/telemetry"
+)
+
+// ChallengeRouter is the router for the retention challenge API
+type ChallengeRouter struct {
+	service *service.DHTService
+}
+
+// NewChallengeRouter returns a new instance of the challenge router
+func NewChallengeRouter(service *service.DHTService) (*ChallengeRouter, error) {
+	return &ChallengeRouter{service: service}, nil
+}
+
+// GetChallengeResponse is the response to a request for the current retention challenge
+// https://did-dht.com/#get-the-current-challenge
+type GetChallengeResponse struct {
+	// Hash is the current hash which is to be used as input for computing a retention solution
+	Hash string `json:"hash"`
+	// HashSource is the source of the hash, e.g. bitcoin
+	HashSource string `json:"hash_source,omitempty"`
+	// Difficulty is the number of bits of leading zeros a retention solution must contain
+	Difficulty int `json:"difficulty"`
+	// Expiry is the unix timestamp in seconds at which the challenge expires
+	Expiry int64 `json:"expiry"`
+}
+
+// GetChallenge godoc
+//
+//	@Summary		GetChallenge returns the current retention challenge
+//	@Description	GetChallenge returns the current hash and difficulty to compute retention solutions against
+//	@Tags			Challenge
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	GetChallengeResponse
+//	@Failure		429	{string}	string	"Too many requests"
+//	@Failure		500	{string}	string	"Internal server error"
+//	@Failure		501	{string}	string	"Retention not supported by this gateway"
+//	@Failure		503	{string}	string	"Retention temporarily disabled"
+//	@Router			/challenge [get]
+func (r *ChallengeRouter) GetChallenge(c *gin.Context) {
+	ctx, span := telemetry.GetTracer().Start(c, "ChallengeHTTP.GetChallenge")
+	defer span.End()
+
+	challenge, err := r.service.GetChallenge(ctx)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrRetentionDisabled):
+			LoggingRespondErrWithMsg(c, err, "retention not supported by this gateway", http.StatusNotImplemented)
+		case errors.Is(err, service.ErrRetentionUnavailable):
+			LoggingRespondErrWithMsg(c, err, "retention temporarily disabled", http.StatusServiceUnavailable)
+		case errors.Is(err, service.ErrChallengeUnavailable):
+			LoggingRespondErrWithMsg(c, err, "retention challenge unavailable", http.StatusServiceUnavailable)
+		default:
+			LoggingRespondErrWithMsg(c, err, "failed to get retention challenge", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	Respond(c, GetChallengeResponse{
+		Hash:       challenge.Hash,
+		HashSource: challenge.HashSource,
+		Difficulty: challenge.Difficulty,
+		Expiry:     challenge.Expiry,
+	}, http.StatusOK)
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/TBD54566975/did-dht/internal/did"
+)
+
+func TestChallengeRouter(t *testing.T) {
+	dhtSvc := testDHTService(t)
+	challengeRouter, err := NewChallengeRouter(&dhtSvc)
+	require.NoError(t, err)
+	require.NotEmpty(t, challengeRouter)
+
+	didRouter, err := NewDIDRouter(&dhtSvc)
+	require.NoError(t, err)
+	require.NotEmpty(t, didRouter)
+
+	defer dhtSvc.Close()
+
+	getChallenge := func(t *testing.T) GetChallengeResponse {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("%s/challenge", testServerURL), nil)
+		c := newRequestContext(w, req)
+
+		challengeRouter.GetChallenge(c)
+		require.Equal(t, http.StatusOK, w.Result().StatusCode, "unexpected %s", w.Result().Status)
+
+		var resp GetChallengeResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		return resp
+	}
+
+	t.Run("test get challenge", func(t *testing.T) {
+		resp := getChallenge(t)
+		assert.Len(t, resp.Hash, 64)
+		assert.Equal(t, "random", resp.HashSource)
+		assert.Equal(t, 4, resp.Difficulty)
+		assert.Greater(t, resp.Expiry, time.Now().Unix())
+
+		// the challenge is stable until it expires
+		assert.Equal(t, resp, getChallenge(t))
+	})
+
+	t.Run("test put did with a retention solution", func(t *testing.T) {
+		challenge := getChallenge(t)
+
+		didID, reqData := generateDIDPutRequest(t)
+		request := putDIDRequestFromBytes(didID, reqData)
+		request.RetentionSolution = did.GenerateRetentionSolution(didID, challenge.Hash, challenge.Difficulty)
+
+		w := putDID(t, didRouter, didID, request)
+		assert.Equal(t, http.StatusAccepted, w.Result().StatusCode, "unexpected %s", w.Result().Status)
+
+		var putResp PutDIDResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&putResp))
+		assert.Greater(t, putResp.Expiry, time.Now().Unix())
+
+		// the expiry is reported on resolution
+		w = httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("%s/did/%s", testServerURL, didID), nil)
+		c := newRequestContextWithParams(w, req, map[string]string{IDParam: didID})
+
+		didRouter.GetDID(c)
+		require.Equal(t, http.StatusOK, w.Result().StatusCode, "unexpected %s", w.Result().Status)
+
+		var getResp GetDIDResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&getResp))
+		assert.Equal(t, putResp.Expiry, getResp.Expiry)
+	})
+}
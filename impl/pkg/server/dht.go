@@ -6,9 +6,9 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
 
 	"github.com/TBD54566975/did-dht/internal/util"
 	"github.com/TBD54566975/did-dht/pkg/dht"
@@ -37,6 +37,7 @@ func NewDHTRouter(service *service.DHTService) (*DHTRouter, error) {
 //	@Success		200	{array}		byte	"64 bytes sig, 8 bytes u64 big-endian seq, 0-1000 bytes of v."
 //	@Failure		400	{string}	string	"Bad request"
 //	@Failure		404	{string}	string	"Not found"
+//	@Failure		429	{string}	string	"Too many requests"
 //	@Failure		500	{string}	string	"Internal server error"
 //	@Router			/{id} [get]
 func (r *DHTRouter) GetRecord(c *gin.Context) {
@@ -62,9 +63,8 @@ func (r *DHTRouter) GetRecord(c *gin.Context) {
 
 	resp, err := r.service.GetDHT(ctx, *id)
 	if err != nil {
-		// TODO(gabe): provide a more maintainable way to handle custom errors
-		if strings.Contains(err.Error(), "spam") {
-			LoggingRespondErrMsg(c, fmt.Sprintf("too many requests for bad key %s", *id), http.StatusTooManyRequests)
+		if errors.Is(err, service.ErrBadKeyRateLimited) {
+			respondTooManyRequests(c, service.BadGetCacheTTL, fmt.Sprintf("too many requests for bad key %s", *id))
 			return
 		}
 		LoggingRespondErrWithMsg(c, err, fmt.Sprintf("failed to get dht record: %s", *id), http.StatusInternalServerError)
@@ -75,12 +75,8 @@ func (r *DHTRouter) GetRecord(c *gin.Context) {
 		return
 	}
 
-	// Convert int64 to uint64 since binary.PutUint64 expects a uint64 value
-	var seqBuf [8]byte
-	binary.BigEndian.PutUint64(seqBuf[:], uint64(resp.Seq))
 	// sig:seq:v
-	res := append(resp.Sig[:], append(seqBuf[:], resp.V[:]...)...)
-	RespondBytes(c, res, http.StatusOK)
+	RespondBytes(c, resp.Bytes(), http.StatusOK)
 }
 
 // PutRecord godoc
@@ -93,6 +89,9 @@ func (r *DHTRouter) GetRecord(c *gin.Context) {
 //	@Param			request	body	[]byte	true	"64 bytes sig, 8 bytes u64 big-endian seq, 0-1000 bytes of v."
 //	@Success		200
 //	@Failure		400	{string}	string	"Bad request"
+//	@Failure		409	{string}	string	"Conflicting record"
+//	@Failure		410	{string}	string	"Record was deleted"
+//	@Failure		429	{string}	string	"Too many requests"
 //	@Failure		500	{string}	string	"Internal server error"
 //	@Router			/{id} [put]
 func (r *DHTRouter) PutRecord(c *gin.Context) {
@@ -138,9 +137,153 @@ func (r *DHTRouter) PutRecord(c *gin.Context) {
 	}
 
 	if err = r.service.PublishDHT(ctx, *id, *request); err != nil {
-		LoggingRespondErrWithMsg(c, err, fmt.Sprintf("failed to publish dht record: %s", *id), http.StatusInternalServerError)
+		var rateLimitErr *service.RecordRateLimitError
+		switch {
+		case errors.Is(err, service.ErrSequenceNumberTooLow), errors.Is(err, service.ErrSequenceNumberConflict):
+			LoggingRespondErrWithMsg(c, err, fmt.Sprintf("failed to publish dht record: %s", *id), http.StatusConflict)
+		case errors.Is(err, service.ErrSequenceNumberInFuture):
+			LoggingRespondErrWithMsg(c, err, fmt.Sprintf("failed to publish dht record: %s", *id), http.StatusBadRequest)
+		case errors.As(err, &rateLimitErr):
+			respondTooManyRequests(c, rateLimitErr.RetryAfter, fmt.Sprintf("too many updates of %s", *id))
+		case errors.Is(err, service.ErrRecordDeleted):
+			LoggingRespondErrWithMsg(c, err, fmt.Sprintf("failed to publish dht record: %s", *id), http.StatusGone)
+		default:
+			LoggingRespondErrWithMsg(c, err, fmt.Sprintf("failed to publish dht record: %s", *id), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	ResponseStatus(c, http.StatusOK)
+}
+
+// DeleteRecord godoc
+//
+//	@Summary		DeleteRecord deletes a BEP44 DNS record from the gateway
+//	@Description	DeleteRecord deletes a BEP44 DNS record from the gateway, which no longer stores, caches, or republishes it
+//	@Description	until its tombstone expires. The request must be signed by the record's key within 5 minutes of the
+//	@Description	request, over "did-dht:delete:" followed by the 32 byte key and the 8 byte u64 big-endian timestamp.
+//	@Tags			DHT
+//	@Accept			octet-stream
+//	@Param			id		path	string	true	"ID of the record to delete"
+//	@Param			request	body	[]byte	true	"64 bytes sig, 8 bytes u64 big-endian unix timestamp in seconds."
+//	@Success		200
+//	@Failure		400	{string}	string	"Bad request"
+//	@Failure		401	{string}	string	"Invalid signature"
+//	@Failure		429	{string}	string	"Too many requests"
+//	@Failure		500	{string}	string	"Internal server error"
+//	@Router			/{id} [delete]
+func (r *DHTRouter) DeleteRecord(c *gin.Context) {
+	ctx, span := telemetry.GetTracer().Start(c, "DHTHTTP.DeleteRecord")
+	defer span.End()
+
+	id := GetParam(c, IDParam)
+	if id == nil || *id == "" {
+		LoggingRespondErrMsg(c, "missing id param", http.StatusBadRequest)
+		return
+	}
+	key, err := util.Z32Decode(*id)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		LoggingRespondErrMsg(c, fmt.Sprintf("invalid z32 encoded ed25519 public key: %s", *id), http.StatusBadRequest)
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		LoggingRespondErrWithMsg(c, err, fmt.Sprintf("failed to read body for id: %s", *id), http.StatusInternalServerError)
+		return
+	}
+	defer c.Request.Body.Close()
+
+	// 64 byte signature and 8 byte timestamp
+	if len(body) != 72 {
+		LoggingRespondErrMsg(c, fmt.Sprintf("invalid request body for id: %s", *id), http.StatusBadRequest)
+		return
+	}
+	request, err := dht.NewDeletionRequest(key, body[:64], int64(binary.BigEndian.Uint64(body[64:72])))
+	if err != nil {
+		if errors.Is(err, dht.ErrInvalidSignature) {
+			LoggingRespondErrWithMsg(c, err, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+		LoggingRespondErrWithMsg(c, err, "error parsing request", http.StatusBadRequest)
+		return
+	}
+
+	if _, err = r.service.DeleteDHT(ctx, *id, *request); err != nil {
+		if errors.Is(err, service.ErrDeletionRequestExpired) {
+			LoggingRespondErrWithMsg(c, err, fmt.Sprintf("failed to delete dht record: %s", *id), http.StatusBadRequest)
+			return
+		}
+		LoggingRespondErrWithMsg(c, err, fmt.Sprintf("failed to delete dht record: %s", *id), http.StatusInternalServerError)
 		return
 	}
 
 	ResponseStatus(c, http.StatusOK)
 }
+
+// GetRecordStatusResponse is the status of a stored record's latest sequence number in the DHT
+type GetRecordStatusResponse struct {
+	ID string `json:"id"`
+	// Seq is the latest sequence number of the record stored by the gateway
+	Seq int64 `json:"seq"`
+	// Status is one of pending, retrying, dead-lettered, or published
+	Status string `json:"status"`
+	// Queued is the unix timestamp in seconds at which a pending record was queued to be put into the DHT
+	Queued int64 `json:"queued,omitempty"`
+	// Attempts is the number of consecutive failed puts of a retrying or dead-lettered record
+	Attempts int `json:"attempts,omitempty"`
+	// LastError is the error of the most recent failed put
+	LastError string `json:"last_error,omitempty"`
+	// NextAttempt is the unix timestamp in seconds at which a retrying record is next put
+	NextAttempt int64 `json:"next_attempt,omitempty"`
+}
+
+// GetRecordStatus godoc
+//
+//	@Summary		GetRecordStatus returns whether a record has been put into the DHT
+//	@Description	GetRecordStatus returns whether the latest sequence number of a stored BEP44 DNS record has reached the DHT
+//	@Tags			DHT
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		string	true	"ID of the record"
+//	@Success		200	{object}	GetRecordStatusResponse
+//	@Failure		400	{string}	string	"Bad request"
+//	@Failure		404	{string}	string	"Not found"
+//	@Failure		429	{string}	string	"Too many requests"
+//	@Failure		500	{string}	string	"Internal server error"
+//	@Router			/{id}/status [get]
+func (r *DHTRouter) GetRecordStatus(c *gin.Context) {
+	ctx, span := telemetry.GetTracer().Start(c, "DHTHTTP.GetRecordStatus")
+	defer span.End()
+
+	id := GetParam(c, IDParam)
+	if id == nil || *id == "" {
+		LoggingRespondErrMsg(c, "missing id param", http.StatusBadRequest)
+		return
+	}
+	key, err := util.Z32Decode(*id)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		LoggingRespondErrMsg(c, fmt.Sprintf("invalid z32 encoded ed25519 public key: %s", *id), http.StatusBadRequest)
+		return
+	}
+
+	status, err := r.service.GetPublishStatus(ctx, *id)
+	if err != nil {
+		LoggingRespondErrWithMsg(c, err, fmt.Sprintf("failed to get status of dht record: %s", *id), http.StatusInternalServerError)
+		return
+	}
+	if status == nil {
+		LoggingRespondErrMsg(c, fmt.Sprintf("dht record not found: %s", *id), http.StatusNotFound)
+		return
+	}
+
+	Respond(c, GetRecordStatusResponse{
+		ID:          status.ID,
+		Seq:         status.Seq,
+		Status:      string(status.State),
+		Queued:      status.Queued,
+		Attempts:    status.Attempts,
+		LastError:   status.LastError,
+		NextAttempt: status.NextAttempt,
+	}, http.StatusOK)
+}
@@ -2,13 +2,17 @@ package server
 
 import (
 	"bytes"
+	"crypto/ed25519"
 	"encoding/binary"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/gin-gonic/gin"
+	"github.com/goccy/go-json"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -125,6 +129,41 @@ func TestDHTRouter(t *testing.T) {
 		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode, "unexpected %s", w.Result().Status)
 	})
 
+	t.Run("test put conflicting record", func(t *testing.T) {
+		sk, doc, err := did.GenerateDIDDHT(did.CreateDIDDHTOpts{})
+		require.NoError(t, err)
+		packet, err := did.DHT(doc.ID).ToDNSPacket(*doc, nil, nil, nil)
+		require.NoError(t, err)
+		putMsg, err := dht.CreateDNSPublishRequest(sk, *packet)
+		require.NoError(t, err)
+		suffix, err := did.DHT(doc.ID).Suffix()
+		require.NoError(t, err)
+
+		putRecord := func(t *testing.T, record dht.BEP44Record) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("%s/%s", testServerURL, suffix), bytes.NewReader(record.Response().Bytes()))
+			c := newRequestContextWithParams(w, req, map[string]string{IDParam: suffix})
+
+			dhtRouter.PutRecord(c)
+			return w
+		}
+
+		w := putRecord(t, dht.RecordFromBEP44(putMsg))
+		assert.True(t, is2xxResponse(w.Code), "unexpected %s", w.Result().Status)
+
+		// re-sign the record with a lower sequence number
+		putMsg.Seq--
+		putMsg.Sign(sk)
+		w = putRecord(t, dht.RecordFromBEP44(putMsg))
+		assert.Equal(t, http.StatusConflict, w.Result().StatusCode, "unexpected %s", w.Result().Status)
+
+		// re-sign the record with a sequence number too far in the future
+		putMsg.Seq = time.Now().Add(3 * time.Hour).Unix()
+		putMsg.Sign(sk)
+		w = putRecord(t, dht.RecordFromBEP44(putMsg))
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode, "unexpected %s", w.Result().Status)
+	})
+
 	t.Run("test put invalid key ID", func(t *testing.T) {
 		_, reqData := generateDIDPutRequest(t)
 
@@ -160,12 +199,88 @@ func TestDHTRouter(t *testing.T) {
 		c = newRequestContextWithParams(w, req, map[string]string{IDParam: suffix})
 		dhtRouter.GetRecord(c)
 		assert.Equal(t, http.StatusTooManyRequests, w.Result().StatusCode, "unexpected %s", w.Result().Status)
+		assert.Equal(t, "60", w.Result().Header.Get("Retry-After"))
+	})
+
+	t.Run("test delete record", func(t *testing.T) {
+		sk, doc, err := did.GenerateDIDDHT(did.CreateDIDDHTOpts{})
+		require.NoError(t, err)
+		packet, err := did.DHT(doc.ID).ToDNSPacket(*doc, nil, nil, nil)
+		require.NoError(t, err)
+		bep44Put, err := dht.CreateDNSPublishRequest(sk, *packet)
+		require.NoError(t, err)
+		record := dht.RecordFromBEP44(bep44Put)
+		suffix := record.ID()
+		reqData := record.Response().Bytes()
+
+		serve := func(method string, body []byte, handler func(c *gin.Context)) int {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(method, fmt.Sprintf("%s/%s", testServerURL, suffix), bytes.NewReader(body))
+			handler(newRequestContextWithParams(w, req, map[string]string{IDParam: suffix}))
+			return w.Code
+		}
+		require.Equal(t, http.StatusOK, serve(http.MethodPut, reqData, dhtRouter.PutRecord))
+
+		// the request must be signed by the record's key, recently
+		_, otherSK, err := ed25519.GenerateKey(nil)
+		require.NoError(t, err)
+		now := time.Now().Unix()
+		assert.Equal(t, http.StatusUnauthorized, serve(http.MethodDelete, dht.SignDeletionRequest(otherSK, now).Bytes(), dhtRouter.DeleteRecord))
+		assert.Equal(t, http.StatusBadRequest, serve(http.MethodDelete, dht.SignDeletionRequest(sk, now-3600).Bytes(), dhtRouter.DeleteRecord))
+		assert.Equal(t, http.StatusBadRequest, serve(http.MethodDelete, []byte("too short"), dhtRouter.DeleteRecord))
+		assert.Equal(t, http.StatusOK, serve(http.MethodGet, nil, dhtRouter.GetRecord))
+
+		assert.Equal(t, http.StatusOK, serve(http.MethodDelete, dht.SignDeletionRequest(sk, now).Bytes(), dhtRouter.DeleteRecord))
+		assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, nil, dhtRouter.GetRecord))
+		assert.Equal(t, http.StatusGone, serve(http.MethodPut, reqData, dhtRouter.PutRecord))
+	})
+
+	t.Run("test get record status", func(t *testing.T) {
+		didID, reqData := generateDIDPutRequest(t)
+		suffix, err := did.DHT(didID).Suffix()
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("%s/%s", testServerURL, suffix), bytes.NewReader(reqData))
+		c := newRequestContextWithParams(w, req, map[string]string{IDParam: suffix})
+		dhtRouter.PutRecord(c)
+		require.True(t, is2xxResponse(w.Code), "unexpected %s", w.Result().Status)
+
+		w = httptest.NewRecorder()
+		req = httptest.NewRequest(http.MethodGet, fmt.Sprintf("%s/%s/status", testServerURL, suffix), nil)
+		c = newRequestContextWithParams(w, req, map[string]string{IDParam: suffix})
+		dhtRouter.GetRecordStatus(c)
+		require.Equal(t, http.StatusOK, w.Result().StatusCode, "unexpected %s", w.Result().Status)
+
+		var resp GetRecordStatusResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		assert.Equal(t, suffix, resp.ID)
+		assert.Equal(t, int64(binary.BigEndian.Uint64(reqData[64:72])), resp.Seq)
+		assert.Contains(t, []string{"pending", "retrying", "published"}, resp.Status)
+	})
+
+	t.Run("test get record status not found", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		suffix := "uqaj3fcr9db6jg6o9pjs53iuftyj45r46aubogfaceqjbo6pp9sy"
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("%s/%s/status", testServerURL, suffix), nil)
+		c := newRequestContextWithParams(w, req, map[string]string{IDParam: suffix})
+		dhtRouter.GetRecordStatus(c)
+		assert.Equal(t, http.StatusNotFound, w.Result().StatusCode, "unexpected %s", w.Result().Status)
+
+		w = httptest.NewRecorder()
+		req = httptest.NewRequest(http.MethodGet, fmt.Sprintf("%s/%s/status", testServerURL, "invalid"), nil)
+		c = newRequestContextWithParams(w, req, map[string]string{IDParam: "invalid"})
+		dhtRouter.GetRecordStatus(c)
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode, "unexpected %s", w.Result().Status)
 	})
 }
 
 func testDHTService(t *testing.T) service.DHTService {
 	defaultConfig := config.GetDefaultConfig()
 
+	// lower the retention difficulty so solutions can be found quickly
+	defaultConfig.RetentionConfig.Difficulty = 4
+
 	db, err := storage.NewStorage(defaultConfig.ServerConfig.StorageURI)
 	require.NoError(t, err)
 	require.NotEmpty(t, db)
@@ -0,0 +1,469 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	didsdk "github.com/TBD54566975/ssi-sdk/did"
+	ssiutil "github.com/TBD54566975/ssi-sdk/util"
+	"github.com/gin-gonic/gin"
+	"github.com/goccy/go-json"
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/TBD54566975/did-dht/internal/did"
+	"github.com/TBD54566975/did-dht/pkg/dht"
+	"github.com/TBD54566975/did-dht/pkg/service"
+	"github.com/TBD54566975/did-dht/pkg/telemetry"
+)
+
+// DIDRouter is the router for the DID API
+type DIDRouter struct {
+	service *service.DHTService
+}
+
+// NewDIDRouter returns a new instance of the DID router
+func NewDIDRouter(service *service.DHTService) (*DIDRouter, error) {
+	return &DIDRouter{service: service}, nil
+}
+
+// GetDIDResponse is the response to a DID resolution request https://did-dht.com/#resolving-a-did
+type GetDIDResponse struct {
+	// DID is the DID Document reconstructed from the DNS packet, which MUST NOT be trusted without verification
+	DID didsdk.Document `json:"did"`
+	// DHT is the unpadded base64URL-encoded BEP44 payload as 64 bytes sig, 8 bytes u64 big-endian seq, and v.
+	// It is empty for a did:key or did:jwk with nothing published, whose DID Document is expanded from its key.
+	DHT string `json:"dht"`
+	// Types is the list of indexed types for the DID, if any
+	Types []did.TypeIndex `json:"types,omitempty"`
+	// SequenceNumbers is a sorted list of seen sequence numbers for the DID, used with historical resolution
+	SequenceNumbers []int64 `json:"sequence_numbers,omitempty"`
+	// Expiry is the unix timestamp in seconds at which the DID will be evicted from the Retained DID Set
+	Expiry int64 `json:"expiry,omitempty"`
+	// Deactivated is true if the DID has been deactivated https://did-dht.com/#deactivate
+	Deactivated bool `json:"deactivated,omitempty"`
+}
+
+// GetDID godoc
+//
+//	@Summary		GetDID resolves a DID Document from the DHT
+//	@Description	GetDID resolves a DID Document from the DHT, returning the document along with its BEP44 payload, or
+//	@Description	a DID Resolution result if requested with an Accept header of application/ld+json;profile="https://w3id.org/did-resolution"
+//	@Tags			DID
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		string	true	"DID to resolve, a did:dht or an Ed25519 did:key or did:jwk"
+//	@Param			seq	query		int		false	"Sequence number to resolve the DID at, defaults to the latest"
+//	@Success		200	{object}	GetDIDResponse
+//	@Failure		400	{string}	string	"Invalid request"
+//	@Failure		404	{string}	string	"DID not found"
+//	@Failure		410	{object}	did.ResolutionResult	"DID deactivated"
+//	@Failure		429	{string}	string	"Too many requests"
+//	@Failure		500	{string}	string	"Internal server error"
+//	@Failure		501	{object}	did.ResolutionResult	"DID method not supported"
+//	@Router			/did/{id} [get]
+func (r *DIDRouter) GetDID(c *gin.Context) {
+	ctx, span := telemetry.GetTracer().Start(c, "DIDHTTP.GetDID")
+	defer span.End()
+
+	id := GetParam(c, IDParam)
+	if id == nil || *id == "" {
+		LoggingRespondErrMsg(c, "missing id param", http.StatusBadRequest)
+		return
+	}
+
+	// historical resolution https://did-dht.com/#historical-resolution
+	var seq *int64
+	if seqParam, ok := c.GetQuery("seq"); ok {
+		parsed, err := strconv.ParseInt(seqParam, 10, 64)
+		if err != nil {
+			LoggingRespondErrWithMsg(c, err, "invalid seq query param", http.StatusBadRequest)
+			return
+		}
+		seq = &parsed
+	}
+
+	didID := didFromParam(*id)
+	if acceptsResolutionResult(c) {
+		source := &badKeySource{ResolutionSource: r.service.ResolutionSource(seq), id: didID}
+		result := did.NewResolver(source).Resolve(ctx, didID.String())
+		if source.rateLimited {
+			c.Header("Retry-After", strconv.Itoa(int(service.BadGetCacheTTL.Seconds())))
+			writeResolutionResult(c, result, http.StatusTooManyRequests)
+			return
+		}
+		respondResolutionResult(c, result)
+		return
+	}
+
+	if !didID.IsValid() {
+		LoggingRespondErrMsg(c, fmt.Sprintf("invalid did: %s", *id), http.StatusBadRequest)
+		return
+	}
+	suffix, err := didID.Suffix()
+	if err != nil {
+		LoggingRespondErrWithMsg(c, err, fmt.Sprintf("invalid did: %s", *id), http.StatusBadRequest)
+		return
+	}
+
+	var resp *dht.BEP44Response
+	if seq != nil {
+		if resp, err = r.service.GetDHTAtSequence(ctx, suffix, *seq); err != nil {
+			LoggingRespondErrWithMsg(c, err, fmt.Sprintf("failed to get did: %s at sequence number %d", didID, *seq), http.StatusInternalServerError)
+			return
+		}
+		if resp == nil {
+			LoggingRespondErrMsg(c, fmt.Sprintf("did not found: %s at sequence number %d", didID, *seq), http.StatusNotFound)
+			return
+		}
+	} else {
+		resp, err = r.service.GetDHT(ctx, suffix)
+		// an interoperable DID recently not found still resolves to its expansion
+		if errors.Is(err, service.ErrBadKeyRateLimited) && did.IsInterop(didID.String()) {
+			resp, err = nil, nil
+		}
+		if err != nil {
+			if errors.Is(err, service.ErrBadKeyRateLimited) {
+				respondTooManyRequests(c, service.BadGetCacheTTL, fmt.Sprintf("too many requests for bad key %s", suffix))
+				return
+			}
+			LoggingRespondErrWithMsg(c, err, fmt.Sprintf("failed to get did: %s", didID), http.StatusInternalServerError)
+			return
+		}
+		if resp == nil {
+			if did.IsInterop(didID.String()) {
+				respondExpandedDID(c, didID)
+				return
+			}
+			LoggingRespondErrMsg(c, fmt.Sprintf("did not found: %s", didID), http.StatusNotFound)
+			return
+		}
+	}
+
+	msg := new(dns.Msg)
+	if err = msg.Unpack(resp.V); err != nil {
+		LoggingRespondErrWithMsg(c, err, fmt.Sprintf("failed to unpack dns packet for did: %s", didID), http.StatusInternalServerError)
+		return
+	}
+	didDoc, err := didID.FromDNSPacket(msg)
+	if err != nil {
+		LoggingRespondErrWithMsg(c, err, fmt.Sprintf("failed to reconstruct did document for did: %s", didID), http.StatusInternalServerError)
+		return
+	}
+
+	expiry, err := r.service.GetRetentionExpiry(ctx, suffix)
+	if err != nil {
+		LoggingRespondErrWithMsg(c, err, fmt.Sprintf("failed to get retention expiry for did: %s", didID), http.StatusInternalServerError)
+		return
+	}
+
+	seqs, err := r.service.ListSequenceNumbers(ctx, suffix)
+	if err != nil {
+		LoggingRespondErrWithMsg(c, err, fmt.Sprintf("failed to list sequence numbers for did: %s", didID), http.StatusInternalServerError)
+		return
+	}
+
+	Respond(c, GetDIDResponse{
+		DID:             didDoc.Doc,
+		DHT:             base64.RawURLEncoding.EncodeToString(resp.Bytes()),
+		Types:           didDoc.Types,
+		SequenceNumbers: seqs,
+		Expiry:          expiry,
+		Deactivated:     didDoc.Deactivated,
+	}, http.StatusOK)
+}
+
+// PutDIDRequest is the request to register or update a DID https://did-dht.com/#register-or-update-a-did
+type PutDIDRequest struct {
+	// DID is the DID to register or update, which must match the DID in the path
+	DID string `json:"did" validate:"required"`
+	// Sig is the unpadded base64URL-encoded signature of the BEP44 payload
+	Sig string `json:"sig" validate:"required"`
+	// Seq is the sequence number of the BEP44 payload
+	Seq int64 `json:"seq" validate:"required"`
+	// V is the unpadded base64URL-encoded bencoded DNS packet of the BEP44 payload
+	V string `json:"v" validate:"required"`
+	// RetentionSolution is an optional proof of work solution used to add the DID to the Retained DID Set
+	RetentionSolution string `json:"retention_solution,omitempty"`
+}
+
+// PutDIDResponse is the response to a DID registration or update request
+type PutDIDResponse struct {
+	// Expiry is the unix timestamp in seconds at which the DID will be evicted from the Retained DID Set
+	Expiry int64 `json:"expiry,omitempty"`
+}
+
+// PutDID godoc
+//
+//	@Summary		PutDID registers or updates a DID in the DHT
+//	@Description	PutDID registers or updates a DID in the DHT, optionally adding it to the Retained DID Set
+//	@Tags			DID
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		string			true	"DID to register or update, a did:dht or an Ed25519 did:key or did:jwk"
+//	@Param			request	body		PutDIDRequest	true	"Registration request"
+//	@Success		202		{object}	PutDIDResponse
+//	@Failure		400		{string}	string	"Invalid request"
+//	@Failure		401		{string}	string	"Invalid signature"
+//	@Failure		409		{string}	string	"Conflicting record"
+//	@Failure		410		{string}	string	"DID was deleted"
+//	@Failure		429		{string}	string	"Too many requests"
+//	@Failure		500		{string}	string	"Internal server error"
+//	@Failure		501		{string}	string	"Retention not supported by this gateway"
+//	@Failure		503		{string}	string	"Retention temporarily disabled"
+//	@Router			/did/{id} [put]
+func (r *DIDRouter) PutDID(c *gin.Context) {
+	ctx, span := telemetry.GetTracer().Start(c, "DIDHTTP.PutDID")
+	defer span.End()
+
+	id := GetParam(c, IDParam)
+	if id == nil || *id == "" {
+		LoggingRespondErrMsg(c, "missing id param", http.StatusBadRequest)
+		return
+	}
+
+	didID := didFromParam(*id)
+	if !didID.IsValid() {
+		LoggingRespondErrMsg(c, fmt.Sprintf("invalid did: %s", *id), http.StatusBadRequest)
+		return
+	}
+
+	var request PutDIDRequest
+	if err := json.NewDecoder(c.Request.Body).Decode(&request); err != nil {
+		LoggingRespondErrWithMsg(c, err, "failed to decode request", http.StatusBadRequest)
+		return
+	}
+	if err := ssiutil.IsValidStruct(request); err != nil {
+		LoggingRespondErrWithMsg(c, err, "invalid request", http.StatusBadRequest)
+		return
+	}
+	if request.DID != didID.String() {
+		LoggingRespondErrMsg(c, fmt.Sprintf("request did %s does not match path did %s", request.DID, didID), http.StatusBadRequest)
+		return
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(request.Sig)
+	if err != nil {
+		LoggingRespondErrWithMsg(c, err, "failed to decode sig", http.StatusBadRequest)
+		return
+	}
+	v, err := base64.RawURLEncoding.DecodeString(request.V)
+	if err != nil {
+		LoggingRespondErrWithMsg(c, err, "failed to decode v", http.StatusBadRequest)
+		return
+	}
+	key, err := didID.IdentityKey()
+	if err != nil {
+		LoggingRespondErrWithMsg(c, err, fmt.Sprintf("invalid did: %s", didID), http.StatusBadRequest)
+		return
+	}
+	record, err := dht.NewBEP44Record(key, v, sig, request.Seq)
+	if err != nil {
+		if errors.Is(err, dht.ErrInvalidSignature) {
+			LoggingRespondErrWithMsg(c, err, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+		LoggingRespondErrWithMsg(c, err, "invalid record", http.StatusBadRequest)
+		return
+	}
+
+	expiry, err := r.service.PublishDID(ctx, didID, *record, request.RetentionSolution)
+	if err != nil {
+		var rateLimitErr *service.RecordRateLimitError
+		switch {
+		case errors.Is(err, service.ErrInvalidRetentionSolution):
+			LoggingRespondErrWithMsg(c, err, "invalid retention solution", http.StatusBadRequest)
+		case errors.Is(err, service.ErrSequenceNumberTooLow), errors.Is(err, service.ErrSequenceNumberConflict):
+			LoggingRespondErrWithMsg(c, err, fmt.Sprintf("failed to publish did: %s", didID), http.StatusConflict)
+		case errors.Is(err, service.ErrSequenceNumberInFuture):
+			LoggingRespondErrWithMsg(c, err, fmt.Sprintf("failed to publish did: %s", didID), http.StatusBadRequest)
+		case errors.Is(err, service.ErrRecordDeleted):
+			LoggingRespondErrWithMsg(c, err, fmt.Sprintf("failed to publish did: %s", didID), http.StatusGone)
+		case errors.As(err, &rateLimitErr):
+			respondTooManyRequests(c, rateLimitErr.RetryAfter, fmt.Sprintf("too many updates of %s", didID))
+		case errors.Is(err, service.ErrRetentionDisabled):
+			LoggingRespondErrWithMsg(c, err, "retention not supported by this gateway", http.StatusNotImplemented)
+		case errors.Is(err, service.ErrRetentionUnavailable):
+			LoggingRespondErrWithMsg(c, err, "retention temporarily disabled", http.StatusServiceUnavailable)
+		default:
+			LoggingRespondErrWithMsg(c, err, fmt.Sprintf("failed to publish did: %s", didID), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	Respond(c, PutDIDResponse{Expiry: expiry}, http.StatusAccepted)
+}
+
+const (
+	// defaultTypeLimit is the number of DIDs returned for a type when no limit is given, which is also the maximum
+	defaultTypeLimit = 100
+)
+
+// TypeDescription describes an indexed type https://did-dht.com/registry/#indexed-types
+type TypeDescription struct {
+	// Type is the type index
+	Type int `json:"type"`
+	// Description is the name of the type in the registry
+	Description string `json:"description"`
+}
+
+// ListTypes godoc
+//
+//	@Summary		ListTypes returns the types indexed by the gateway
+//	@Description	ListTypes returns the type indexes and descriptions of the types indexed by the gateway
+//	@Tags			DID
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{array}		TypeDescription
+//	@Failure		429	{string}	string	"Too many requests"
+//	@Router			/did/types [get]
+func (r *DIDRouter) ListTypes(c *gin.Context) {
+	_, span := telemetry.GetTracer().Start(c, "DIDHTTP.ListTypes")
+	defer span.End()
+
+	indexedTypes := did.IndexedTypes()
+	types := make([]TypeDescription, 0, len(indexedTypes))
+	for _, t := range indexedTypes {
+		types = append(types, TypeDescription{Type: int(t), Description: t.Description()})
+	}
+	Respond(c, types, http.StatusOK)
+}
+
+// ListDIDsForType godoc
+//
+//	@Summary		ListDIDsForType returns the DIDs indexed under a type
+//	@Description	ListDIDsForType returns the DIDs indexed under a type, paginated by offset and limit
+//	@Tags			DID
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		integer	true	"Type index"
+//	@Param			offset	query		integer	false	"Number of DIDs to skip"		default(0)
+//	@Param			limit	query		integer	false	"Maximum number of DIDs to return"	default(100)
+//	@Success		200		{array}		string
+//	@Failure		400		{string}	string	"Invalid request"
+//	@Failure		404		{string}	string	"Type not found"
+//	@Failure		429		{string}	string	"Too many requests"
+//	@Failure		500		{string}	string	"Internal server error"
+//	@Router			/did/types/{id} [get]
+func (r *DIDRouter) ListDIDsForType(c *gin.Context) {
+	ctx, span := telemetry.GetTracer().Start(c, "DIDHTTP.ListDIDsForType")
+	defer span.End()
+
+	id := GetParam(c, IDParam)
+	if id == nil || *id == "" {
+		LoggingRespondErrMsg(c, "missing id param", http.StatusBadRequest)
+		return
+	}
+	typeIndex, err := strconv.Atoi(*id)
+	if err != nil {
+		LoggingRespondErrWithMsg(c, err, fmt.Sprintf("invalid type: %s", *id), http.StatusBadRequest)
+		return
+	}
+	if !did.TypeIndex(typeIndex).IsRegistered() {
+		LoggingRespondErrMsg(c, fmt.Sprintf("type not found: %d", typeIndex), http.StatusNotFound)
+		return
+	}
+
+	offset, err := GetIntQueryParam(c, "offset", 0)
+	if err != nil || offset < 0 {
+		LoggingRespondErrMsg(c, "offset must be a non-negative integer", http.StatusBadRequest)
+		return
+	}
+	limit, err := GetIntQueryParam(c, "limit", defaultTypeLimit)
+	if err != nil || limit <= 0 || limit > defaultTypeLimit {
+		LoggingRespondErrMsg(c, fmt.Sprintf("limit must be an integer between 1 and %d", defaultTypeLimit), http.StatusBadRequest)
+		return
+	}
+
+	dids, err := r.service.ListDIDsForType(ctx, did.TypeIndex(typeIndex), offset, limit)
+	if err != nil {
+		LoggingRespondErrWithMsg(c, err, fmt.Sprintf("failed to list dids for type: %d", typeIndex), http.StatusInternalServerError)
+		return
+	}
+	Respond(c, dids, http.StatusOK)
+}
+
+// acceptsResolutionResult returns true if the client asked for a DID Resolution result rather than the gateway's
+// response format https://w3c-ccg.github.io/did-resolution/#bindings-https
+func acceptsResolutionResult(c *gin.Context) bool {
+	accept := c.GetHeader("Accept")
+	return strings.Contains(accept, "application/ld+json") && strings.Contains(accept, "https://w3id.org/did-resolution")
+}
+
+// respondResolutionResult sends a DID Resolution result with the status code for its resolution metadata
+func respondResolutionResult(c *gin.Context, result did.ResolutionResult) {
+	statusCode := http.StatusOK
+	switch result.ResolutionMetadata.Error {
+	case did.ResolutionErrorInvalidDID:
+		statusCode = http.StatusBadRequest
+	case did.ResolutionErrorNotFound:
+		statusCode = http.StatusNotFound
+	case did.ResolutionErrorMethodNotSupported:
+		statusCode = http.StatusNotImplemented
+	case did.ResolutionErrorInternal:
+		statusCode = http.StatusInternalServerError
+	default:
+		if result.DocumentMetadata.Deactivated {
+			statusCode = http.StatusGone
+		}
+	}
+	if statusCode >= http.StatusBadRequest && statusCode != http.StatusGone {
+		logrus.WithContext(c).WithField("error", result.ResolutionMetadata.Error).Error(result.ResolutionMetadata.ErrorMessage)
+	}
+
+	writeResolutionResult(c, result, statusCode)
+}
+
+// writeResolutionResult writes the resolution result with the given status code
+func writeResolutionResult(c *gin.Context, result did.ResolutionResult, statusCode int) {
+	body, err := json.Marshal(result)
+	if err != nil {
+		LoggingRespondErrWithMsg(c, err, "failed to marshal resolution result", http.StatusInternalServerError)
+		return
+	}
+	c.Data(statusCode, did.ResolutionContentType, body)
+}
+
+// badKeySource resolves a DID from the gateway, treating an interoperable DID recently not found in the DHT as not
+// found so that it resolves to its expansion, and recording whether any other DID was refused for the same reason
+type badKeySource struct {
+	did.ResolutionSource
+	// id is the DID being resolved, which for an interoperable DID is not the did:dht identifier the source is given
+	id          did.DHT
+	rateLimited bool
+}
+
+func (s *badKeySource) GetResolutionRecord(ctx context.Context, id did.DHT) (*did.ResolutionRecord, error) {
+	record, err := s.ResolutionSource.GetResolutionRecord(ctx, id)
+	if errors.Is(err, service.ErrBadKeyRateLimited) {
+		if did.IsInterop(s.id.String()) {
+			return nil, nil
+		}
+		s.rateLimited = true
+	}
+	return record, err
+}
+
+// respondExpandedDID responds with the DID Document expanded from the key of an interoperable did:key or did:jwk
+// which has nothing published in the DHT https://did-dht.com/registry/#interoperable-did-methods
+func respondExpandedDID(c *gin.Context, id did.DHT) {
+	doc, err := id.Expand()
+	if err != nil {
+		LoggingRespondErrWithMsg(c, err, fmt.Sprintf("invalid did: %s", id), http.StatusBadRequest)
+		return
+	}
+	Respond(c, GetDIDResponse{DID: *doc}, http.StatusOK)
+}
+
+// didFromParam returns the DID for the given path parameter, which may be a DID or the z-base-32 encoded suffix
+func didFromParam(param string) did.DHT {
+	if strings.HasPrefix(param, "did:") {
+		return did.DHT(param)
+	}
+	return did.DHT(did.Prefix + ":" + param)
+}
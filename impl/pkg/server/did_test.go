@@ -0,0 +1,491 @@
+package server
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/TBD54566975/did-dht/internal/did"
+	"github.com/TBD54566975/did-dht/pkg/dht"
+)
+
+func TestDIDRouter(t *testing.T) {
+	dhtSvc := testDHTService(t)
+	dhtRouter, err := NewDHTRouter(&dhtSvc)
+	require.NoError(t, err)
+	require.NotEmpty(t, dhtRouter)
+
+	didRouter, err := NewDIDRouter(&dhtSvc)
+	require.NoError(t, err)
+	require.NotEmpty(t, didRouter)
+
+	defer dhtSvc.Close()
+
+	t.Run("test get did", func(t *testing.T) {
+		didID, reqData := generateDIDPutRequest(t)
+
+		w := httptest.NewRecorder()
+		suffix, err := did.DHT(didID).Suffix()
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("%s/%s", testServerURL, suffix), bytes.NewReader(reqData))
+		c := newRequestContextWithParams(w, req, map[string]string{IDParam: suffix})
+
+		dhtRouter.PutRecord(c)
+		require.True(t, is2xxResponse(w.Code), "unexpected %s", w.Result().Status)
+
+		// resolve by the full DID and by its suffix
+		for _, id := range []string{didID, suffix} {
+			w = httptest.NewRecorder()
+			req = httptest.NewRequest(http.MethodGet, fmt.Sprintf("%s/did/%s", testServerURL, id), nil)
+			c = newRequestContextWithParams(w, req, map[string]string{IDParam: id})
+
+			didRouter.GetDID(c)
+			assert.Equal(t, http.StatusOK, w.Result().StatusCode, "unexpected %s", w.Result().Status)
+
+			var resp GetDIDResponse
+			err = json.NewDecoder(w.Body).Decode(&resp)
+			require.NoError(t, err)
+			assert.Equal(t, didID, resp.DID.ID)
+			assert.Empty(t, resp.Types)
+
+			dhtBytes, err := base64.RawURLEncoding.DecodeString(resp.DHT)
+			require.NoError(t, err)
+			assert.Equal(t, reqData, dhtBytes)
+		}
+	})
+
+	t.Run("test put and get interoperable dids", func(t *testing.T) {
+		getDID := func(t *testing.T, id string) GetDIDResponse {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("%s/did/%s", testServerURL, id), nil)
+			c := newRequestContextWithParams(w, req, map[string]string{IDParam: id})
+
+			didRouter.GetDID(c)
+			require.Equal(t, http.StatusOK, w.Result().StatusCode, "unexpected %s", w.Result().Status)
+
+			var resp GetDIDResponse
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+			return resp
+		}
+
+		for _, fromDHT := range []func(did.DHT) (string, error){did.KeyFromDHT, did.JWKFromDHT} {
+			didID, reqData := generateDIDPutRequest(t)
+			interopID, err := fromDHT(did.DHT(didID))
+			require.NoError(t, err)
+
+			// before publishing, the interoperable DID resolves to its expansion
+			resp := getDID(t, interopID)
+			expanded, err := did.DHT(interopID).Expand()
+			require.NoError(t, err)
+			expandedJSON, err := json.Marshal(expanded)
+			require.NoError(t, err)
+			respJSON, err := json.Marshal(resp.DID)
+			require.NoError(t, err)
+			assert.JSONEq(t, string(expandedJSON), string(respJSON))
+			assert.Empty(t, resp.DHT)
+
+			// the request must name the interoperable DID in the path
+			w := putDID(t, didRouter, interopID, putDIDRequestFromBytes(didID, reqData))
+			assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode, "unexpected %s", w.Result().Status)
+
+			w = putDID(t, didRouter, interopID, putDIDRequestFromBytes(interopID, reqData))
+			require.Equal(t, http.StatusAccepted, w.Result().StatusCode, "unexpected %s", w.Result().Status)
+
+			// once published, the interoperable DID resolves to the published document, as does its did:dht identifier
+			resp = getDID(t, interopID)
+			assert.Equal(t, interopID, resp.DID.ID)
+			assert.Equal(t, interopID+"#0", resp.DID.VerificationMethod[0].ID)
+			dhtBytes, err := base64.RawURLEncoding.DecodeString(resp.DHT)
+			require.NoError(t, err)
+			assert.Equal(t, reqData, dhtBytes)
+
+			assert.Equal(t, didID, getDID(t, didID).DID.ID)
+
+			// resolution results are supported too
+			w = httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("%s/did/%s", testServerURL, interopID), nil)
+			req.Header.Set("Accept", did.ResolutionContentType)
+			c := newRequestContextWithParams(w, req, map[string]string{IDParam: interopID})
+			didRouter.GetDID(c)
+			assert.Equal(t, http.StatusOK, w.Result().StatusCode, "unexpected %s", w.Result().Status)
+
+			var result did.ResolutionResult
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&result))
+			require.NotNil(t, result.Document)
+			assert.Equal(t, interopID, result.Document.ID)
+			assert.Equal(t, []string{didID}, result.DocumentMetadata.EquivalentID)
+		}
+	})
+
+	t.Run("test get unpublished did:key twice", func(t *testing.T) {
+		didID, _ := generateDIDPutRequest(t)
+		keyID, err := did.KeyFromDHT(did.DHT(didID))
+		require.NoError(t, err)
+		expanded, err := did.DHT(keyID).Expand()
+		require.NoError(t, err)
+
+		// the first lookup marks the key as bad, which must not stop later lookups falling back to the expansion
+		for range 2 {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("%s/did/%s", testServerURL, keyID), nil)
+			didRouter.GetDID(newRequestContextWithParams(w, req, map[string]string{IDParam: keyID}))
+			require.Equal(t, http.StatusOK, w.Result().StatusCode, "unexpected %s", w.Result().Status)
+
+			var resp GetDIDResponse
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+			assert.Equal(t, expanded.ID, resp.DID.ID)
+			assert.Empty(t, resp.DHT)
+		}
+		for range 2 {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("%s/did/%s", testServerURL, keyID), nil)
+			req.Header.Set("Accept", did.ResolutionContentType)
+			didRouter.GetDID(newRequestContextWithParams(w, req, map[string]string{IDParam: keyID}))
+			require.Equal(t, http.StatusOK, w.Result().StatusCode, "unexpected %s", w.Result().Status)
+
+			var result did.ResolutionResult
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&result))
+			require.NotNil(t, result.Document)
+			assert.Equal(t, expanded.ID, result.Document.ID)
+			assert.Empty(t, result.DocumentMetadata.VersionID)
+		}
+
+		// a did:dht recently not found is still rate limited, whether or not a resolution result is accepted
+		for _, accept := range []string{"", did.ResolutionContentType} {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("%s/did/%s", testServerURL, didID), nil)
+			req.Header.Set("Accept", accept)
+			didRouter.GetDID(newRequestContextWithParams(w, req, map[string]string{IDParam: didID}))
+			assert.Equal(t, http.StatusTooManyRequests, w.Result().StatusCode, "unexpected %s", w.Result().Status)
+			assert.Equal(t, "60", w.Header().Get("Retry-After"))
+		}
+	})
+
+	t.Run("test get did no ID", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("%s/did/", testServerURL), nil)
+		c := newRequestContextWithParams(w, req, map[string]string{})
+
+		didRouter.GetDID(c)
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode, "unexpected %s", w.Result().Status)
+	})
+
+	t.Run("test get invalid did", func(t *testing.T) {
+		for _, id := range []string{"did:dht:aaaa", "did:example:1234", "----"} {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("%s/did/%s", testServerURL, id), nil)
+			c := newRequestContextWithParams(w, req, map[string]string{IDParam: id})
+
+			didRouter.GetDID(c)
+			assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode, "unexpected %s", w.Result().Status)
+		}
+	})
+
+	t.Run("test get did not found", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		id := "did:dht:uqaj3fcr9db6jg6o9pjs53iuftyj45r46aubogfaceqjbo6pp9sy"
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("%s/did/%s", testServerURL, id), nil)
+		c := newRequestContextWithParams(w, req, map[string]string{IDParam: id})
+
+		didRouter.GetDID(c)
+		assert.Equal(t, http.StatusNotFound, w.Result().StatusCode, "unexpected %s", w.Result().Status)
+	})
+
+	t.Run("test put did", func(t *testing.T) {
+		didID, reqData := generateDIDPutRequest(t)
+		request := putDIDRequestFromBytes(didID, reqData)
+
+		w := putDID(t, didRouter, didID, request)
+		assert.Equal(t, http.StatusAccepted, w.Result().StatusCode, "unexpected %s", w.Result().Status)
+
+		var resp PutDIDResponse
+		err = json.NewDecoder(w.Body).Decode(&resp)
+		require.NoError(t, err)
+		assert.Zero(t, resp.Expiry)
+
+		// the did resolves after registration
+		w = httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("%s/did/%s", testServerURL, didID), nil)
+		c := newRequestContextWithParams(w, req, map[string]string{IDParam: didID})
+
+		didRouter.GetDID(c)
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode, "unexpected %s", w.Result().Status)
+	})
+
+	t.Run("test put did mismatched did", func(t *testing.T) {
+		didID, reqData := generateDIDPutRequest(t)
+		otherDIDID, _ := generateDIDPutRequest(t)
+		request := putDIDRequestFromBytes(otherDIDID, reqData)
+
+		w := putDID(t, didRouter, didID, request)
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode, "unexpected %s", w.Result().Status)
+	})
+
+	t.Run("test put did bad signature", func(t *testing.T) {
+		didID, reqData := generateDIDPutRequest(t)
+		reqData[0] ^= 0xff
+		request := putDIDRequestFromBytes(didID, reqData)
+
+		w := putDID(t, didRouter, didID, request)
+		assert.Equal(t, http.StatusUnauthorized, w.Result().StatusCode, "unexpected %s", w.Result().Status)
+	})
+
+	t.Run("test put did invalid retention solution", func(t *testing.T) {
+		didID, reqData := generateDIDPutRequest(t)
+		request := putDIDRequestFromBytes(didID, reqData)
+		request.RetentionSolution = "bad:solution"
+
+		w := putDID(t, didRouter, didID, request)
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode, "unexpected %s", w.Result().Status)
+	})
+
+	t.Run("test put did lower sequence number", func(t *testing.T) {
+		sk, doc, err := did.GenerateDIDDHT(did.CreateDIDDHTOpts{})
+		require.NoError(t, err)
+		packet, err := did.DHT(doc.ID).ToDNSPacket(*doc, nil, nil, nil)
+		require.NoError(t, err)
+		putMsg, err := dht.CreateDNSPublishRequest(sk, *packet)
+		require.NoError(t, err)
+
+		record := dht.RecordFromBEP44(putMsg)
+		w := putDID(t, didRouter, doc.ID, putDIDRequestFromBytes(doc.ID, record.Response().Bytes()))
+		assert.Equal(t, http.StatusAccepted, w.Result().StatusCode, "unexpected %s", w.Result().Status)
+
+		// re-sign the record with a lower sequence number
+		putMsg.Seq--
+		putMsg.Sign(sk)
+		record = dht.RecordFromBEP44(putMsg)
+		w = putDID(t, didRouter, doc.ID, putDIDRequestFromBytes(doc.ID, record.Response().Bytes()))
+		assert.Equal(t, http.StatusConflict, w.Result().StatusCode, "unexpected %s", w.Result().Status)
+	})
+
+	t.Run("test put did sequence number in the future", func(t *testing.T) {
+		sk, doc, err := did.GenerateDIDDHT(did.CreateDIDDHTOpts{})
+		require.NoError(t, err)
+		packet, err := did.DHT(doc.ID).ToDNSPacket(*doc, nil, nil, nil)
+		require.NoError(t, err)
+		putMsg, err := dht.CreateDNSPublishRequest(sk, *packet)
+		require.NoError(t, err)
+
+		putMsg.Seq = time.Now().Add(3 * time.Hour).Unix()
+		putMsg.Sign(sk)
+		record := dht.RecordFromBEP44(putMsg)
+		w := putDID(t, didRouter, doc.ID, putDIDRequestFromBytes(doc.ID, record.Response().Bytes()))
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode, "unexpected %s", w.Result().Status)
+	})
+
+	t.Run("test get deactivated did", func(t *testing.T) {
+		sk, doc, err := did.GenerateDIDDHT(did.CreateDIDDHTOpts{})
+		require.NoError(t, err)
+		packet, err := did.DHT(doc.ID).ToDeactivatedDNSPacket()
+		require.NoError(t, err)
+		putMsg, err := dht.CreateDNSPublishRequest(sk, *packet)
+		require.NoError(t, err)
+
+		record := dht.RecordFromBEP44(putMsg)
+		w := putDID(t, didRouter, doc.ID, putDIDRequestFromBytes(doc.ID, record.Response().Bytes()))
+		require.Equal(t, http.StatusAccepted, w.Result().StatusCode, "unexpected %s", w.Result().Status)
+
+		w = httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("%s/did/%s", testServerURL, doc.ID), nil)
+		c := newRequestContextWithParams(w, req, map[string]string{IDParam: doc.ID})
+
+		didRouter.GetDID(c)
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode, "unexpected %s", w.Result().Status)
+
+		var resp GetDIDResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		assert.True(t, resp.Deactivated)
+		assert.Equal(t, doc.ID, resp.DID.ID)
+		assert.Empty(t, resp.DID.VerificationMethod)
+	})
+
+	t.Run("test get did at sequence number", func(t *testing.T) {
+		sk, doc, err := did.GenerateDIDDHT(did.CreateDIDDHTOpts{})
+		require.NoError(t, err)
+		packet, err := did.DHT(doc.ID).ToDNSPacket(*doc, nil, nil, nil)
+		require.NoError(t, err)
+		putMsg, err := dht.CreateDNSPublishRequest(sk, *packet)
+		require.NoError(t, err)
+
+		first := dht.RecordFromBEP44(putMsg)
+		w := putDID(t, didRouter, doc.ID, putDIDRequestFromBytes(doc.ID, first.Response().Bytes()))
+		require.Equal(t, http.StatusAccepted, w.Result().StatusCode, "unexpected %s", w.Result().Status)
+
+		putMsg.Seq++
+		putMsg.Sign(sk)
+		second := dht.RecordFromBEP44(putMsg)
+		w = putDID(t, didRouter, doc.ID, putDIDRequestFromBytes(doc.ID, second.Response().Bytes()))
+		require.Equal(t, http.StatusAccepted, w.Result().StatusCode, "unexpected %s", w.Result().Status)
+
+		getDID := func(t *testing.T, query string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("%s/did/%s%s", testServerURL, doc.ID, query), nil)
+			c := newRequestContextWithParams(w, req, map[string]string{IDParam: doc.ID})
+
+			didRouter.GetDID(c)
+			return w
+		}
+
+		for _, record := range []dht.BEP44Record{first, second} {
+			w = getDID(t, fmt.Sprintf("?seq=%d", record.SequenceNumber))
+			assert.Equal(t, http.StatusOK, w.Result().StatusCode, "unexpected %s", w.Result().Status)
+
+			var resp GetDIDResponse
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+			assert.Equal(t, base64.RawURLEncoding.EncodeToString(record.Response().Bytes()), resp.DHT)
+			assert.Equal(t, []int64{first.SequenceNumber, second.SequenceNumber}, resp.SequenceNumbers)
+		}
+
+		w = getDID(t, fmt.Sprintf("?seq=%d", second.SequenceNumber+1))
+		assert.Equal(t, http.StatusNotFound, w.Result().StatusCode, "unexpected %s", w.Result().Status)
+
+		w = getDID(t, "?seq=latest")
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode, "unexpected %s", w.Result().Status)
+	})
+
+	t.Run("test get did resolution result", func(t *testing.T) {
+		didID, reqData := generateDIDPutRequest(t)
+		w := putDID(t, didRouter, didID, putDIDRequestFromBytes(didID, reqData))
+		require.Equal(t, http.StatusAccepted, w.Result().StatusCode, "unexpected %s", w.Result().Status)
+
+		sk, doc, err := did.GenerateDIDDHT(did.CreateDIDDHTOpts{})
+		require.NoError(t, err)
+		packet, err := did.DHT(doc.ID).ToDeactivatedDNSPacket()
+		require.NoError(t, err)
+		putMsg, err := dht.CreateDNSPublishRequest(sk, *packet)
+		require.NoError(t, err)
+		deactivated := dht.RecordFromBEP44(putMsg)
+		w = putDID(t, didRouter, doc.ID, putDIDRequestFromBytes(doc.ID, deactivated.Response().Bytes()))
+		require.Equal(t, http.StatusAccepted, w.Result().StatusCode, "unexpected %s", w.Result().Status)
+
+		_, missingDoc, err := did.GenerateDIDDHT(did.CreateDIDDHTOpts{})
+		require.NoError(t, err)
+
+		resolve := func(t *testing.T, id string) (*httptest.ResponseRecorder, did.ResolutionResult) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("%s/did/%s", testServerURL, id), nil)
+			req.Header.Set("Accept", did.ResolutionContentType)
+			c := newRequestContextWithParams(w, req, map[string]string{IDParam: id})
+
+			didRouter.GetDID(c)
+			assert.Equal(t, did.ResolutionContentType, w.Header().Get("Content-Type"))
+			var result did.ResolutionResult
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&result))
+			return w, result
+		}
+
+		w, result := resolve(t, didID)
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode, "unexpected %s", w.Result().Status)
+		require.NotNil(t, result.Document)
+		assert.Equal(t, didID, result.Document.ID)
+		assert.NotEmpty(t, result.DocumentMetadata.Gateway)
+		assert.NotEmpty(t, result.DocumentMetadata.VersionID)
+
+		w, result = resolve(t, doc.ID)
+		assert.Equal(t, http.StatusGone, w.Result().StatusCode, "unexpected %s", w.Result().Status)
+		assert.True(t, result.DocumentMetadata.Deactivated)
+
+		w, result = resolve(t, missingDoc.ID)
+		assert.Equal(t, http.StatusNotFound, w.Result().StatusCode, "unexpected %s", w.Result().Status)
+		assert.Equal(t, did.ResolutionErrorNotFound, result.ResolutionMetadata.Error)
+
+		w, result = resolve(t, "did:example:1234")
+		assert.Equal(t, http.StatusNotImplemented, w.Result().StatusCode, "unexpected %s", w.Result().Status)
+		assert.Equal(t, did.ResolutionErrorMethodNotSupported, result.ResolutionMetadata.Error)
+
+		w, result = resolve(t, "did:dht:aaaa")
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode, "unexpected %s", w.Result().Status)
+		assert.Equal(t, did.ResolutionErrorInvalidDID, result.ResolutionMetadata.Error)
+	})
+
+	t.Run("test list types", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("%s/did/types", testServerURL), nil)
+		c := newRequestContext(w, req)
+
+		didRouter.ListTypes(c)
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode, "unexpected %s", w.Result().Status)
+
+		var resp []TypeDescription
+		err = json.NewDecoder(w.Body).Decode(&resp)
+		require.NoError(t, err)
+		assert.Len(t, resp, 8)
+		assert.Equal(t, TypeDescription{Type: 1, Description: "Organization"}, resp[1])
+	})
+
+	t.Run("test list dids for type", func(t *testing.T) {
+		sk, doc, err := did.GenerateDIDDHT(did.CreateDIDDHTOpts{})
+		require.NoError(t, err)
+		packet, err := did.DHT(doc.ID).ToDNSPacket(*doc, []did.TypeIndex{did.SoftwarePackage}, nil, nil)
+		require.NoError(t, err)
+		putMsg, err := dht.CreateDNSPublishRequest(sk, *packet)
+		require.NoError(t, err)
+
+		record := dht.RecordFromBEP44(putMsg)
+		w := putDID(t, didRouter, doc.ID, putDIDRequestFromBytes(doc.ID, record.Response().Bytes()))
+		require.Equal(t, http.StatusAccepted, w.Result().StatusCode, "unexpected %s", w.Result().Status)
+
+		listDIDs := func(t *testing.T, typeIndex, query string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("%s/did/types/%s%s", testServerURL, typeIndex, query), nil)
+			c := newRequestContextWithParams(w, req, map[string]string{IDParam: typeIndex})
+
+			didRouter.ListDIDsForType(c)
+			return w
+		}
+
+		w = listDIDs(t, "5", "")
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode, "unexpected %s", w.Result().Status)
+		var dids []string
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&dids))
+		assert.Contains(t, dids, doc.ID)
+
+		w = listDIDs(t, "5", fmt.Sprintf("?offset=%d&limit=10", len(dids)))
+		assert.Equal(t, http.StatusOK, w.Result().StatusCode, "unexpected %s", w.Result().Status)
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&dids))
+		assert.Empty(t, dids)
+
+		for _, query := range []string{"?offset=-1", "?offset=a", "?limit=0", "?limit=101"} {
+			w = listDIDs(t, "5", query)
+			assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode, "unexpected %s for %s", w.Result().Status, query)
+		}
+
+		w = listDIDs(t, "five", "")
+		assert.Equal(t, http.StatusBadRequest, w.Result().StatusCode, "unexpected %s", w.Result().Status)
+
+		w = listDIDs(t, "8", "")
+		assert.Equal(t, http.StatusNotFound, w.Result().StatusCode, "unexpected %s", w.Result().Status)
+	})
+}
+
+// putDIDRequestFromBytes builds a PutDIDRequest from a sig:seq:v encoded BEP44 payload
+func putDIDRequestFromBytes(didID string, reqData []byte) PutDIDRequest {
+	return PutDIDRequest{
+		DID: didID,
+		Sig: base64.RawURLEncoding.EncodeToString(reqData[:64]),
+		Seq: int64(binary.BigEndian.Uint64(reqData[64:72])),
+		V:   base64.RawURLEncoding.EncodeToString(reqData[72:]),
+	}
+}
+
+func putDID(t *testing.T, didRouter *DIDRouter, didID string, request PutDIDRequest) *httptest.ResponseRecorder {
+	requestBytes, err := json.Marshal(request)
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPut, fmt.Sprintf("%s/did/%s", testServerURL, didID), bytes.NewReader(requestBytes))
+	c := newRequestContextWithParams(w, req, map[string]string{IDParam: didID})
+
+	didRouter.PutDID(c)
+	return w
+}
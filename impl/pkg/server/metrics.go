@@ -0,0 +1,24 @@
+package server
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/TBD54566975/did-dht/pkg/telemetry"
+)
+
+// metrics records the method, route, status, and latency of each request
+func metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		// requests which match no route are grouped together, so that unknown paths do not create a series each
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		telemetry.RecordHTTPRequest(c.Request.Context(), c.Request.Method, route, c.Writer.Status(), time.Since(start))
+	}
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+
+	"github.com/TBD54566975/did-dht/config"
+	"github.com/TBD54566975/did-dht/internal/util"
+)
+
+// routeClass is a class of routes whose requests share each client's rate limit
+type routeClass string
+
+const (
+	routeClassRead  routeClass = "read"
+	routeClassWrite routeClass = "write"
+)
+
+// rateLimiter limits the requests of each client IP per route class, reads for GET and HEAD requests and writes
+// otherwise. The updates of each record are limited by the service, which only counts records it stores.
+type rateLimiter struct {
+	reads  *util.KeyedLimiter
+	writes *util.KeyedLimiter
+}
+
+func newRateLimiter(cfg config.RateLimitConfig) *rateLimiter {
+	return &rateLimiter{
+		reads:  util.NewKeyedLimiter(rate.Limit(cfg.ReadsPerSecond), cfg.ReadBurst),
+		writes: util.NewKeyedLimiter(rate.Limit(cfg.WritesPerSecond), cfg.WriteBurst),
+	}
+}
+
+// middleware rejects requests over the client's limit for their route class with 429 and a Retry-After header
+func (l *rateLimiter) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		now := time.Now()
+		class, clientLimiters := routeClassRead, l.reads
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			class, clientLimiters = routeClassWrite, l.writes
+		}
+
+		clientIP := c.ClientIP()
+		if retryAfter := clientLimiters.Reserve(clientIP, now); retryAfter > 0 {
+			respondTooManyRequests(c, retryAfter, fmt.Sprintf("too many %s requests from %s", class, clientIP))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// respondTooManyRequests responds with 429 and a Retry-After header of the given delay, rounded up to whole seconds
+func respondTooManyRequests(c *gin.Context, retryAfter time.Duration, errMsg string) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	LoggingRespondErrMsg(c, errMsg, http.StatusTooManyRequests)
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/goccy/go-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/TBD54566975/did-dht/config"
+	"github.com/TBD54566975/did-dht/internal/did"
+	"github.com/TBD54566975/did-dht/pkg/dht"
+)
+
+func TestRateLimit(t *testing.T) {
+	serviceConfig, err := config.LoadConfig("")
+	require.NoError(t, err)
+	serviceConfig.ServerConfig.StorageURI = "bolt://ratelimit.db"
+	serviceConfig.ServerConfig.TrustedProxies = []string{"10.0.0.1"}
+	serviceConfig.ServerConfig.RateLimit = config.RateLimitConfig{
+		Enabled:                    true,
+		ReadsPerSecond:             0.001,
+		ReadBurst:                  3,
+		WritesPerSecond:            0.001,
+		WriteBurst:                 10,
+		RecordWriteIntervalMinutes: 60,
+		RecordWriteBurst:           1,
+	}
+
+	server, err := NewServer(serviceConfig, make(chan os.Signal, 1), dht.NewTestDHT(t))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = server.Shutdown(context.Background())
+		_ = os.Remove("ratelimit.db")
+	})
+
+	serve := func(req *http.Request, remoteAddr string) *httptest.ResponseRecorder {
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		server.Handler.ServeHTTP(w, req)
+		return w
+	}
+	get := func(remoteAddr, forwardedFor string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, testServerURL+"/challenge", nil)
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		return serve(req, remoteAddr)
+	}
+
+	t.Run("test reads limited per client", func(t *testing.T) {
+		for range 3 {
+			assert.NotEqual(t, http.StatusTooManyRequests, get("192.0.2.1:1234", "").Code)
+		}
+		w := get("192.0.2.1:1234", "")
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After"))
+		require.NoError(t, err)
+		assert.Positive(t, retryAfter)
+
+		// other clients have their own limit, and health checks are not limited
+		assert.NotEqual(t, http.StatusTooManyRequests, get("192.0.2.2:1234", "").Code)
+		w = serve(httptest.NewRequest(http.MethodGet, testServerURL+"/health", nil), "192.0.2.1:1234")
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("test forwarded client IP only from trusted proxies", func(t *testing.T) {
+		// an untrusted client cannot evade its limit by claiming to forward another
+		assert.Equal(t, http.StatusTooManyRequests, get("192.0.2.1:1234", "192.0.2.3").Code)
+
+		for range 3 {
+			assert.NotEqual(t, http.StatusTooManyRequests, get("10.0.0.1:1234", "192.0.2.4").Code)
+		}
+		assert.Equal(t, http.StatusTooManyRequests, get("10.0.0.1:1234", "192.0.2.4").Code)
+		assert.NotEqual(t, http.StatusTooManyRequests, get("10.0.0.1:1234", "192.0.2.5").Code)
+	})
+
+	t.Run("test writes limited per record", func(t *testing.T) {
+		sk, doc, err := did.GenerateDIDDHT(did.CreateDIDDHTOpts{})
+		require.NoError(t, err)
+		packet, err := did.DHT(doc.ID).ToDNSPacket(*doc, nil, nil, nil)
+		require.NoError(t, err)
+		putMsg, err := dht.CreateDNSPublishRequest(sk, *packet)
+		require.NoError(t, err)
+		suffix, err := did.DHT(doc.ID).Suffix()
+		require.NoError(t, err)
+
+		put := func(record dht.BEP44Record, remoteAddr string) *httptest.ResponseRecorder {
+			body := bytes.NewReader(record.Response().Bytes())
+			return serve(httptest.NewRequest(http.MethodPut, testServerURL+"/"+suffix, body), remoteAddr)
+		}
+		putDID := func(record dht.BEP44Record, remoteAddr string) *httptest.ResponseRecorder {
+			body, err := json.Marshal(putDIDRequestFromBytes(doc.ID, record.Response().Bytes()))
+			require.NoError(t, err)
+			return serve(httptest.NewRequest(http.MethodPut, testServerURL+"/did/"+doc.ID, bytes.NewReader(body)), remoteAddr)
+		}
+
+		// writes with invalid signatures do not use up the record's limit
+		first := dht.RecordFromBEP44(putMsg)
+		forged := first
+		forged.Signature[0] ^= 0xff
+		for range 3 {
+			assert.Equal(t, http.StatusBadRequest, put(forged, "192.0.2.6:1234").Code)
+			assert.Equal(t, http.StatusUnauthorized, putDID(forged, "192.0.2.6:1234").Code)
+		}
+		assert.Equal(t, http.StatusOK, put(first, "192.0.2.7:1234").Code)
+
+		// nor do repeated or conflicting writes, which do not store a new record
+		assert.Equal(t, http.StatusOK, put(first, "192.0.2.8:1234").Code)
+		assert.Equal(t, http.StatusAccepted, putDID(first, "192.0.2.8:1234").Code)
+		putMsg.Seq--
+		putMsg.Sign(sk)
+		assert.Equal(t, http.StatusConflict, put(dht.RecordFromBEP44(putMsg), "192.0.2.8:1234").Code)
+
+		// a new version of the record is limited from any client, by record ID or DID
+		putMsg.Seq += 2
+		putMsg.Sign(sk)
+		second := dht.RecordFromBEP44(putMsg)
+		w := put(second, "192.0.2.9:1234")
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After"))
+		require.NoError(t, err)
+		assert.Positive(t, retryAfter)
+		assert.Equal(t, http.StatusTooManyRequests, putDID(second, "192.0.2.10:1234").Code)
+
+		// reads of the record are not affected
+		w = serve(httptest.NewRequest(http.MethodGet, fmt.Sprintf("%s/%s", testServerURL, suffix), nil), "192.0.2.11:1234")
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("test invalid trusted proxies", func(t *testing.T) {
+		serviceConfig.ServerConfig.TrustedProxies = []string{"not-an-ip"}
+		_, err := NewServer(serviceConfig, make(chan os.Signal, 1), dht.NewTestDHT(t))
+		assert.Error(t, err)
+	})
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/TBD54566975/ssi-sdk/util"
 	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	swaggerfiles "github.com/swaggo/files"
 	ginswagger "github.com/swaggo/gin-swagger"
@@ -18,6 +19,7 @@ import (
 	"github.com/TBD54566975/did-dht/pkg/dht"
 	"github.com/TBD54566975/did-dht/pkg/service"
 	"github.com/TBD54566975/did-dht/pkg/storage"
+	"github.com/TBD54566975/did-dht/pkg/telemetry"
 )
 
 const (
@@ -37,12 +39,18 @@ type Server struct {
 // NewServer returns a new instance of Server with the given db and host.
 func NewServer(cfg *config.Config, shutdown chan os.Signal, d *dht.DHT) (*Server, error) {
 	// set up server prerequisites
-	handler := setupHandler(cfg.ServerConfig.Environment)
+	handler, err := setupHandler(cfg.ServerConfig)
+	if err != nil {
+		return nil, util.LoggingErrorMsg(err, "failed to set up handler")
+	}
 
 	db, err := storage.NewStorage(cfg.ServerConfig.StorageURI)
 	if err != nil {
 		return nil, util.LoggingErrorMsg(err, "failed to instantiate storage")
 	}
+	if cfg.ServerConfig.Metrics {
+		db = storage.WithMetrics(db)
+	}
 
 	recordCnt, err := db.RecordCount(context.Background())
 	if err != nil {
@@ -57,15 +65,45 @@ func NewServer(cfg *config.Config, shutdown chan os.Signal, d *dht.DHT) (*Server
 	}
 
 	handler.GET("/health", Health)
+	if cfg.ServerConfig.Metrics {
+		handler.GET("/metrics", gin.WrapH(telemetry.MetricsHandler()))
+	}
 
 	// set up swagger
 	handler.StaticFile("swagger.yaml", "./docs/swagger.yaml")
 	handler.GET("/swagger/*any", ginswagger.WrapHandler(swaggerfiles.Handler, ginswagger.URL("/swagger.yaml")))
 
+	// the gateway API is rate limited per client, if configured
+	api := &handler.RouterGroup
+	if cfg.ServerConfig.RateLimit.Enabled {
+		api = handler.Group("", newRateLimiter(cfg.ServerConfig.RateLimit).middleware())
+	} else {
+		logrus.Info("rate limiting disabled")
+	}
+
 	// root relay API
-	if err = DHTAPI(&handler.RouterGroup, dhtService); err != nil {
+	if err = DHTAPI(api, dhtService); err != nil {
 		return nil, util.LoggingErrorMsg(err, "could not setup the dht API")
 	}
+
+	// did API
+	if err = DIDAPI(api.Group("/did"), dhtService); err != nil {
+		return nil, util.LoggingErrorMsg(err, "could not setup the did API")
+	}
+
+	// retention challenge API
+	if err = ChallengeAPI(api, dhtService); err != nil {
+		return nil, util.LoggingErrorMsg(err, "could not setup the challenge API")
+	}
+
+	// operator admin API, only if a token is configured
+	if cfg.ServerConfig.AdminToken != "" {
+		if err = AdminAPI(handler.Group("/admin", adminAuth(cfg.ServerConfig.AdminToken)), dhtService); err != nil {
+			return nil, util.LoggingErrorMsg(err, "could not setup the admin API")
+		}
+	} else {
+		logrus.Info("no admin token configured, admin API disabled")
+	}
 	return &Server{
 		Server: &http.Server{
 			Addr:              fmt.Sprintf("%s:%d", cfg.ServerConfig.APIHost, cfg.ServerConfig.APIPort),
@@ -82,7 +120,15 @@ func NewServer(cfg *config.Config, shutdown chan os.Signal, d *dht.DHT) (*Server
 	}, nil
 }
 
-func setupHandler(env config.Environment) *gin.Engine {
+// Shutdown stops accepting requests and waits for in-flight requests to finish, and then closes the dht service, which
+// waits for records queued in its outbox to be put into the DHT
+func (s *Server) Shutdown(ctx context.Context) error {
+	err := s.Server.Shutdown(ctx)
+	s.svc.Close()
+	return err
+}
+
+func setupHandler(cfg config.ServerConfig) (*gin.Engine, error) {
 	gin.ForceConsoleColor()
 	middlewares := gin.HandlersChain{
 		otelgin.Middleware(config.ServiceName),
@@ -90,9 +136,10 @@ func setupHandler(env config.Environment) *gin.Engine {
 		gin.ErrorLogger(),
 		CORS(),
 		logger(logrus.StandardLogger()),
+		metrics(),
 	}
-	logrus.WithField("environment", env).Info("configuring server for environment")
-	switch env {
+	logrus.WithField("environment", cfg.Environment).Info("configuring server for environment")
+	switch cfg.Environment {
 	case config.EnvironmentDev:
 		gin.SetMode(gin.DebugMode)
 	case config.EnvironmentTest:
@@ -102,7 +149,12 @@ func setupHandler(env config.Environment) *gin.Engine {
 	}
 	handler := gin.New()
 	handler.Use(middlewares...)
-	return handler
+
+	// the client IP is only taken from proxy headers set by trusted proxies, so clients cannot spoof it
+	if err := handler.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		return nil, errors.Wrap(err, "invalid trusted proxies")
+	}
+	return handler, nil
 }
 
 // DHTAPI sets up the relay API routes according to the spec https://did-dht.com/#gateway-api
@@ -114,5 +166,51 @@ func DHTAPI(rg *gin.RouterGroup, service *service.DHTService) error {
 
 	rg.PUT("/:id", dhtRouter.PutRecord)
 	rg.GET("/:id", dhtRouter.GetRecord)
+	rg.DELETE("/:id", dhtRouter.DeleteRecord)
+	rg.GET("/:id/status", dhtRouter.GetRecordStatus)
+	return nil
+}
+
+// DIDAPI sets up the DID API routes according to the spec https://did-dht.com/#gateway-api
+func DIDAPI(rg *gin.RouterGroup, service *service.DHTService) error {
+	didRouter, err := NewDIDRouter(service)
+	if err != nil {
+		return util.LoggingErrorMsg(err, "could not instantiate did router")
+	}
+
+	rg.GET("/:id", didRouter.GetDID)
+	rg.PUT("/:id", didRouter.PutDID)
+	rg.GET("/types", didRouter.ListTypes)
+	rg.GET("/types/:id", didRouter.ListDIDsForType)
+	return nil
+}
+
+// ChallengeAPI sets up the retention challenge API routes according to the spec https://did-dht.com/#gateway-api
+func ChallengeAPI(rg *gin.RouterGroup, service *service.DHTService) error {
+	challengeRouter, err := NewChallengeRouter(service)
+	if err != nil {
+		return util.LoggingErrorMsg(err, "could not instantiate challenge router")
+	}
+
+	rg.GET("/challenge", challengeRouter.GetChallenge)
+	return nil
+}
+
+// AdminAPI sets up the operator admin API routes, which must be behind authentication
+func AdminAPI(rg *gin.RouterGroup, service *service.DHTService) error {
+	adminRouter, err := NewAdminRouter(service)
+	if err != nil {
+		return util.LoggingErrorMsg(err, "could not instantiate admin router")
+	}
+
+	rg.GET("/records", adminRouter.ListRecords)
+	rg.DELETE("/records/:id", adminRouter.DeleteRecord)
+	rg.GET("/tombstones", adminRouter.ListTombstones)
+	rg.DELETE("/tombstones/:id", adminRouter.LiftTombstone)
+	rg.GET("/failed", adminRouter.ListFailedRecords)
+	rg.POST("/republish", adminRouter.RepublishAll)
+	rg.POST("/republish/:id", adminRouter.RepublishRecord)
+	rg.GET("/republish/runs", adminRouter.ListRepublishRuns)
+	rg.DELETE("/cache/:id", adminRouter.EvictCache)
 	return nil
 }
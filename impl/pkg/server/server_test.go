@@ -1,18 +1,25 @@
 package server
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"testing"
 
+	didsdk "github.com/TBD54566975/ssi-sdk/did"
+	"github.com/anacrolix/dht/v2/bep44"
 	"github.com/goccy/go-json"
+	"github.com/pkg/errors"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/TBD54566975/did-dht/config"
+	"github.com/TBD54566975/did-dht/internal/did"
 	"github.com/TBD54566975/did-dht/pkg/dht"
+	"github.com/TBD54566975/did-dht/pkg/telemetry"
 )
 
 const (
@@ -47,6 +54,153 @@ func TestHealthCheckAPI(t *testing.T) {
 	shutdown <- os.Interrupt
 }
 
+func TestMetricsAPI(t *testing.T) {
+	ctx := context.Background()
+	serviceConfig, err := config.LoadConfig("")
+	require.NoError(t, err)
+	require.NoError(t, telemetry.SetupTelemetry(ctx, serviceConfig))
+	t.Cleanup(func() { telemetry.Shutdown(ctx) })
+
+	serviceConfig.ServerConfig.StorageURI = "bolt://metrics.db"
+	serviceConfig.ServerConfig.BaseURL = testServerURL
+
+	server, err := NewServer(serviceConfig, make(chan os.Signal, 1), dht.NewTestDHT(t))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = server.Shutdown(ctx) })
+
+	w := httptest.NewRecorder()
+	server.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, testServerURL+"/health", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	server.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, testServerURL+"/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	body := w.Body.String()
+	assert.Contains(t, body, `http_server_requests_total{method="GET",route="/health",status="200"} 1`)
+	assert.Contains(t, body, "http_server_request_duration_seconds_bucket")
+	assert.Contains(t, body, `storage_operation_duration_seconds_count{backend="bolt",operation="RecordCount",outcome="success"}`)
+	assert.Contains(t, body, "dht_routing_table_nodes")
+
+	t.Run("test metrics disabled", func(t *testing.T) {
+		serviceConfig.ServerConfig.Metrics = false
+		serviceConfig.ServerConfig.StorageURI = "bolt://metrics-disabled.db"
+		server, err := NewServer(serviceConfig, make(chan os.Signal, 1), dht.NewTestDHT(t))
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = server.Shutdown(ctx) })
+
+		w := httptest.NewRecorder()
+		server.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, testServerURL+"/metrics", nil))
+		// the path is handled as a record ID instead
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.NotContains(t, w.Body.String(), "http_server_requests_total")
+	})
+}
+
+func TestGatewayClient(t *testing.T) {
+	ctx := context.Background()
+
+	serviceConfig, err := config.LoadConfig("")
+	require.NoError(t, err)
+	serviceConfig.ServerConfig.StorageURI = "bolt://gateway-client.db"
+	serviceConfig.RetentionConfig.Enabled = false
+
+	server, err := NewServer(serviceConfig, make(chan os.Signal, 1), dht.NewTestDHT(t))
+	require.NoError(t, err)
+	defer server.Close()
+
+	gateway := httptest.NewServer(server.Handler)
+	defer gateway.Close()
+
+	client, err := did.NewGatewayClient(gateway.URL, did.WithRetries(0))
+	require.NoError(t, err)
+
+	newPut := func(t *testing.T) (string, *didsdk.Document, *bep44.Put) {
+		sk, doc, err := did.GenerateDIDDHT(did.CreateDIDDHTOpts{})
+		require.NoError(t, err)
+		packet, err := did.DHT(doc.ID).ToDNSPacket(*doc, []did.TypeIndex{did.Organization}, nil, nil)
+		require.NoError(t, err)
+		put, err := dht.CreateDNSPublishRequest(sk, *packet)
+		require.NoError(t, err)
+		return doc.ID, doc, put
+	}
+
+	t.Run("test dht api", func(t *testing.T) {
+		id, doc, put := newPut(t)
+		require.NoError(t, client.PutDocument(ctx, id, *put))
+
+		gotDoc, err := client.GetDIDDocument(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, *doc, gotDoc.Doc)
+		assert.Equal(t, []did.TypeIndex{did.Organization}, gotDoc.Types)
+
+		suffix, err := did.DHT(id).Suffix()
+		require.NoError(t, err)
+		record, err := client.GetDHTRecord(ctx, suffix)
+		require.NoError(t, err)
+		assert.Equal(t, put.Seq, record.Seq)
+		assert.Equal(t, put.V, record.V)
+		assert.Equal(t, put.Sig, record.Sig)
+
+		_, missingDoc, _ := newPut(t)
+		_, err = client.GetDIDDocument(ctx, missingDoc.ID)
+		assert.True(t, did.IsNotFound(err))
+	})
+
+	t.Run("test did api", func(t *testing.T) {
+		id, doc, put := newPut(t)
+		resp, err := client.PutDID(ctx, id, *put, "")
+		require.NoError(t, err)
+		assert.Zero(t, resp.Expiry)
+
+		// an update whose seq does not match its signature is rejected
+		tampered := *put
+		tampered.Seq--
+		_, err = client.PutDID(ctx, id, tampered, "")
+		var statusErr *did.StatusError
+		require.True(t, errors.As(err, &statusErr))
+		assert.Equal(t, http.StatusUnauthorized, statusErr.StatusCode)
+
+		gotDID, err := client.GetDID(ctx, id)
+		require.NoError(t, err)
+		require.NotNil(t, gotDID.Record)
+		assert.Equal(t, put.Seq, gotDID.Record.Seq)
+		assert.Equal(t, *doc, gotDID.Record.Document.Doc)
+		assert.Equal(t, []did.TypeIndex{did.Organization}, gotDID.Types)
+		assert.Contains(t, gotDID.SequenceNumbers, put.Seq)
+
+		gotDID, err = client.GetDIDAtSequence(ctx, id, put.Seq)
+		require.NoError(t, err)
+		assert.Equal(t, put.Seq, gotDID.Record.Seq)
+
+		_, err = client.GetDIDAtSequence(ctx, id, put.Seq-1)
+		assert.True(t, did.IsNotFound(err))
+	})
+
+	t.Run("test types api", func(t *testing.T) {
+		types, err := client.ListTypes(ctx)
+		require.NoError(t, err)
+		assert.Len(t, types, len(did.IndexedTypes()))
+
+		id, _, put := newPut(t)
+		require.NoError(t, client.PutDocument(ctx, id, *put))
+		dids, err := client.ListDIDsForType(ctx, did.Organization, 0, 100)
+		require.NoError(t, err)
+		assert.NotEmpty(t, dids)
+
+		_, err = client.ListDIDsForType(ctx, did.TypeIndex(100000), 0, 100)
+		assert.True(t, did.IsNotFound(err))
+	})
+
+	t.Run("test challenge api", func(t *testing.T) {
+		_, err := client.GetChallenge(ctx)
+		var statusErr *did.StatusError
+		require.True(t, errors.As(err, &statusErr))
+		assert.Equal(t, http.StatusNotImplemented, statusErr.StatusCode)
+		assert.Contains(t, statusErr.Message, "retention not supported")
+	})
+}
+
 // Is2xxResponse returns true if the given status code is a 2xx response
 func is2xxResponse(statusCode int) bool {
 	return statusCode/100 == 2
@@ -2,6 +2,7 @@ package server
 
 import (
 	"net/http"
+	"strconv"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -73,6 +74,19 @@ func GetParam(c *gin.Context, param string) *string {
 	return &got
 }
 
+// GetIntQueryParam is a utility to get an integer query parameter from context, defaultValue if not found
+func GetIntQueryParam(c *gin.Context, param string, defaultValue int) (int, error) {
+	got, ok := c.GetQuery(param)
+	if !ok || got == "" {
+		return defaultValue, nil
+	}
+	value, err := strconv.Atoi(got)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid %s query param", param)
+	}
+	return value, nil
+}
+
 func CORS() gin.HandlerFunc {
 	return cors.New(cors.Config{
 		AllowOrigins: []string{"*"},
@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	ssiutil "github.com/TBD54566975/ssi-sdk/util"
+	"github.com/allegro/bigcache/v3"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/TBD54566975/did-dht/internal/util"
+	"github.com/TBD54566975/did-dht/pkg/dht"
+	"github.com/TBD54566975/did-dht/pkg/telemetry"
+)
+
+// republishHistoryLimit is the number of republish runs kept for operators to inspect
+const republishHistoryLimit = 50
+
+// ErrRepublishRunning is returned when republishing all records is requested while a previous request is still running
+var ErrRepublishRunning = errors.New("republish of all records already running")
+
+// RepublishTrigger is what started a republish run
+type RepublishTrigger string
+
+const (
+	// RepublishTriggerStartup is the run scheduling stored records on startup when nothing has been scheduled
+	RepublishTriggerStartup RepublishTrigger = "startup"
+	// RepublishTriggerSchedule is a run on the republish cron schedule
+	RepublishTriggerSchedule RepublishTrigger = "schedule"
+	// RepublishTriggerAdmin is a run forced by an operator, which republishes every record immediately
+	RepublishTriggerAdmin RepublishTrigger = "admin"
+)
+
+// RepublishRun is a pass over the stored records which reconciles them with the republish schedule
+type RepublishRun struct {
+	Trigger  RepublishTrigger
+	Started  time.Time
+	Finished time.Time
+	// RecordCount is the number of stored records
+	RecordCount int
+	// Scheduled is the number of records scheduled, which for forced runs are due immediately
+	Scheduled int
+	// Unscheduled is the number of records which are no longer republished
+	Unscheduled int
+	// Error is set if the run did not complete
+	Error string
+}
+
+// republishRuns runs passes over the stored records and keeps a history of the most recent, which is lost on restart
+type republishRuns struct {
+	mu      sync.Mutex
+	history []RepublishRun
+	forcing bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newRepublishRuns() *republishRuns {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &republishRuns{ctx: ctx, cancel: cancel}
+}
+
+// run runs the pass and adds it to the history
+func (r *republishRuns) run(trigger RepublishTrigger, pass func(ctx context.Context, run *RepublishRun) error) RepublishRun {
+	run := RepublishRun{Trigger: trigger, Started: time.Now()}
+	if err := pass(r.ctx, &run); err != nil {
+		run.Error = err.Error()
+	}
+	run.Finished = time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.history = append(r.history, run)
+	if len(r.history) > republishHistoryLimit {
+		r.history = r.history[len(r.history)-republishHistoryLimit:]
+	}
+	return run
+}
+
+// force runs the pass in the background, unless a previous forced pass is still running
+func (r *republishRuns) force(pass func(ctx context.Context, run *RepublishRun) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.forcing {
+		return ErrRepublishRunning
+	}
+	if r.ctx.Err() != nil {
+		return r.ctx.Err()
+	}
+	r.forcing = true
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		r.run(RepublishTriggerAdmin, pass)
+		r.mu.Lock()
+		r.forcing = false
+		r.mu.Unlock()
+	}()
+	return nil
+}
+
+// stop cancels running passes and waits for them to return
+func (r *republishRuns) stop() {
+	if r == nil {
+		return
+	}
+	r.cancel()
+	r.wg.Wait()
+}
+
+// list returns the history of runs, most recent first
+func (r *republishRuns) list() []RepublishRun {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	runs := make([]RepublishRun, 0, len(r.history))
+	for i := len(r.history) - 1; i >= 0; i-- {
+		runs = append(runs, r.history[i])
+	}
+	return runs
+}
+
+// ListRecords returns a page of stored records, and the token of the next page or nil if it is the last
+func (s *DHTService) ListRecords(ctx context.Context, pageToken []byte, pageSize int) ([]dht.BEP44Record, []byte, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "DHTService.ListRecords")
+	defer span.End()
+
+	return s.db.ListRecords(ctx, pageToken, pageSize)
+}
+
+// ListFailedRecords returns the records which failed to be put into the DHT and are retrying or dead-lettered
+func (s *DHTService) ListFailedRecords(ctx context.Context) ([]dht.FailedRecord, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "DHTService.ListFailedRecords")
+	defer span.End()
+
+	return s.db.ListFailedRecords(ctx)
+}
+
+// RepublishRecord queues the stored record with the given z-base-32 encoded ID to be put into the DHT immediately,
+// including a dead-lettered record. It returns the queued record, or nil if the record is not stored.
+func (s *DHTService) RepublishRecord(ctx context.Context, id string) (*dht.OutboxRecord, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "DHTService.RepublishRecord")
+	defer span.End()
+
+	if _, err := util.Z32Decode(id); err != nil {
+		return nil, ssiutil.LoggingCtxErrorMsgf(ctx, err, "failed to decode z-base-32 encoded ID: %s", id)
+	}
+
+	record, err := s.db.ReadRecord(ctx, id)
+	if err != nil {
+		return nil, ssiutil.LoggingCtxErrorMsgf(ctx, err, "failed to read record: %s", id)
+	}
+	if record == nil {
+		return nil, nil
+	}
+
+	queued := dht.OutboxRecord{ID: id, Seq: record.SequenceNumber, Queued: time.Now().Unix()}
+	if err = s.db.WriteOutboxRecord(ctx, queued); err != nil {
+		return nil, ssiutil.LoggingCtxErrorMsgf(ctx, err, "failed to queue record: %s", id)
+	}
+	s.outbox.notify()
+	logrus.WithContext(ctx).WithField("record_id", id).Info("queued record for republishing")
+	return &queued, nil
+}
+
+// RepublishAll schedules every stored record which should be republished to be republished immediately, in the
+// background. It returns ErrRepublishRunning if a previous request is still running.
+func (s *DHTService) RepublishAll() error {
+	return s.runs.force(func(ctx context.Context, run *RepublishRun) error {
+		return s.reconcile(ctx, run, true)
+	})
+}
+
+// ListRepublishRuns returns the most recent passes over the stored records since the gateway started, most recent first
+func (s *DHTService) ListRepublishRuns() []RepublishRun {
+	return s.runs.list()
+}
+
+// EvictCache removes the given z-base-32 encoded ID from the record cache and the bad get cache, reporting whether it
+// was in each
+func (s *DHTService) EvictCache(id string) (record bool, badGet bool, err error) {
+	if _, err = util.Z32Decode(id); err != nil {
+		return false, false, errors.Wrapf(err, "failed to decode z-base-32 encoded ID: %s", id)
+	}
+	record, err = evict(s.cache, id)
+	if err != nil {
+		return false, false, errors.Wrap(err, "failed to evict from cache")
+	}
+	badGet, err = evict(s.badGetCache, id)
+	if err != nil {
+		return record, false, errors.Wrap(err, "failed to evict from bad get cache")
+	}
+	logrus.WithFields(logrus.Fields{"record_id": id, "record": record, "bad_get": badGet}).Info("evicted key from caches")
+	return record, badGet, nil
+}
+
+func evict(cache *bigcache.BigCache, key string) (bool, error) {
+	err := cache.Delete(key)
+	if errors.Is(err, bigcache.ErrEntryNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/TBD54566975/did-dht/pkg/dht"
+)
+
+func TestRepublishRuns(t *testing.T) {
+	runs := newRepublishRuns()
+
+	for i := range republishHistoryLimit + 5 {
+		run := runs.run(RepublishTriggerSchedule, func(_ context.Context, run *RepublishRun) error {
+			run.RecordCount = i
+			return nil
+		})
+		assert.Equal(t, i, run.RecordCount)
+	}
+	failed := runs.run(RepublishTriggerStartup, func(context.Context, *RepublishRun) error {
+		return errors.New("failed to list records")
+	})
+	assert.Equal(t, "failed to list records", failed.Error)
+	assert.False(t, failed.Finished.Before(failed.Started))
+
+	history := runs.list()
+	require.Len(t, history, republishHistoryLimit)
+	assert.Equal(t, RepublishTriggerStartup, history[0].Trigger)
+	assert.Equal(t, republishHistoryLimit+4, history[1].RecordCount)
+
+	t.Run("test forced runs do not overlap", func(t *testing.T) {
+		release := make(chan struct{})
+		require.NoError(t, runs.force(func(context.Context, *RepublishRun) error {
+			<-release
+			return nil
+		}))
+		assert.ErrorIs(t, runs.force(func(context.Context, *RepublishRun) error { return nil }), ErrRepublishRunning)
+		close(release)
+
+		assert.Eventually(t, func() bool {
+			return runs.list()[0].Trigger == RepublishTriggerAdmin
+		}, time.Second, 10*time.Millisecond)
+		assert.Eventually(t, func() bool {
+			return runs.force(func(context.Context, *RepublishRun) error { return nil }) == nil
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("test stop cancels running passes", func(t *testing.T) {
+		runs := newRepublishRuns()
+		require.NoError(t, runs.force(func(ctx context.Context, _ *RepublishRun) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}))
+		runs.stop()
+		require.Len(t, runs.list(), 1)
+		assert.Equal(t, context.Canceled.Error(), runs.list()[0].Error)
+		assert.Error(t, runs.force(func(context.Context, *RepublishRun) error { return nil }))
+	})
+}
+
+func TestAdmin(t *testing.T) {
+	svc := newDHTService(t, "admin")
+	ctx := context.Background()
+
+	t.Run("test republish record", func(t *testing.T) {
+		_, err := svc.RepublishRecord(ctx, "---")
+		assert.Error(t, err)
+
+		record := newTestRecord(t)
+		queued, err := svc.RepublishRecord(ctx, record.ID())
+		assert.NoError(t, err)
+		assert.Nil(t, queued)
+
+		// a dead-lettered record is put again, which clears it once it succeeds
+		require.NoError(t, svc.db.WriteRecord(ctx, record))
+		require.NoError(t, svc.db.WriteFailedRecord(ctx, dht.FailedRecord{ID: record.ID(), Count: 10, DeadLettered: true}))
+		queued, err = svc.RepublishRecord(ctx, record.ID())
+		require.NoError(t, err)
+		require.NotNil(t, queued)
+		assert.Equal(t, record.SequenceNumber, queued.Seq)
+
+		assert.Eventually(t, func() bool {
+			got, err := svc.db.ReadOutboxRecord(ctx, record.ID())
+			require.NoError(t, err)
+			return got == nil
+		}, 15*time.Second, 100*time.Millisecond)
+
+		// the put was attempted, clearing the failure or counting another
+		failed, err := svc.db.ReadFailedRecord(ctx, record.ID())
+		require.NoError(t, err)
+		if failed != nil {
+			assert.Equal(t, 11, failed.Count)
+		}
+	})
+
+	t.Run("test republish all", func(t *testing.T) {
+		record := newTestRecord(t)
+		require.NoError(t, svc.db.WriteRecord(ctx, record))
+		require.NoError(t, svc.republisher.schedule(ctx, record.ID(), time.Now().Add(time.Hour)))
+
+		require.NoError(t, svc.RepublishAll())
+		assert.Eventually(t, func() bool {
+			runs := svc.ListRepublishRuns()
+			return len(runs) > 0 && runs[0].Trigger == RepublishTriggerAdmin
+		}, 15*time.Second, 100*time.Millisecond)
+
+		run := svc.ListRepublishRuns()[0]
+		assert.Empty(t, run.Error)
+		assert.GreaterOrEqual(t, run.RecordCount, 2)
+		assert.Equal(t, run.RecordCount, run.Scheduled)
+
+		// the record was due in an hour, and is republished and rescheduled immediately instead
+		assert.Eventually(t, func() bool {
+			scheduled, err := svc.db.ListRepublishSchedule(ctx)
+			require.NoError(t, err)
+			for _, s := range scheduled {
+				if s.ID == record.ID() {
+					return s.Due > time.Now().Add(time.Hour).Unix()
+				}
+			}
+			return false
+		}, 15*time.Second, 100*time.Millisecond)
+	})
+
+	t.Run("test list records", func(t *testing.T) {
+		records, nextPage, err := svc.ListRecords(ctx, nil, 1)
+		require.NoError(t, err)
+		assert.Len(t, records, 1)
+		assert.NotNil(t, nextPage)
+	})
+
+	t.Run("test evict cache", func(t *testing.T) {
+		_, _, err := svc.EvictCache("---")
+		assert.Error(t, err)
+
+		id := newTestRecord(t).ID()
+		require.NoError(t, svc.cache.Set(id, []byte{0}))
+		require.NoError(t, svc.badGetCache.Set(id, []byte{0}))
+
+		record, badGet, err := svc.EvictCache(id)
+		require.NoError(t, err)
+		assert.True(t, record)
+		assert.True(t, badGet)
+
+		record, badGet, err = svc.EvictCache(id)
+		require.NoError(t, err)
+		assert.False(t, record)
+		assert.False(t, badGet)
+	})
+
+	t.Cleanup(func() { svc.Close() })
+}
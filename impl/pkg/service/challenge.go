@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/TBD54566975/did-dht/config"
+	"github.com/TBD54566975/did-dht/internal/did"
+	"github.com/TBD54566975/did-dht/pkg/telemetry"
+)
+
+const (
+	RandomHashSourceName  = "random"
+	BitcoinHashSourceName = "bitcoin"
+)
+
+// HashSource provides the hashes retention challenges are issued against https://did-dht.com/#hash-generation
+type HashSource interface {
+	// Name returns the name of the source, reported as the challenge's hash source
+	Name() string
+	// Hash returns the hash to issue the next challenge against
+	Hash(ctx context.Context) (string, error)
+}
+
+// NewHashSource returns the hash source configured by the given retention config
+func NewHashSource(cfg config.RetentionConfig) (HashSource, error) {
+	switch cfg.HashSource {
+	case RandomHashSourceName, "":
+		return RandomHashSource{}, nil
+	case BitcoinHashSourceName:
+		return NewBitcoinHashSource(cfg.BitcoinBlockHashURL, http.DefaultClient)
+	default:
+		return nil, fmt.Errorf("unsupported hash source: %s", cfg.HashSource)
+	}
+}
+
+// RandomHashSource issues random 256-bit hashes, which gateways can use without relying on an external source
+type RandomHashSource struct{}
+
+func (RandomHashSource) Name() string {
+	return RandomHashSourceName
+}
+
+// Hash returns a random hex-encoded 256-bit hash
+func (RandomHashSource) Hash(context.Context) (string, error) {
+	hashBytes := make([]byte, 32)
+	if _, err := rand.Read(hashBytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hashBytes), nil
+}
+
+// BitcoinHashSource issues the most recent Bitcoin block hash, as reported by a block hash provider which responds
+// to GET requests with the hex-encoded hash as plain text (e.g. https://blockstream.info/api/blocks/tip/hash)
+type BitcoinHashSource struct {
+	url    string
+	client *http.Client
+}
+
+// NewBitcoinHashSource returns a new Bitcoin hash source backed by the provider at the given URL
+func NewBitcoinHashSource(url string, client *http.Client) (*BitcoinHashSource, error) {
+	if url == "" {
+		return nil, errors.New("bitcoin block hash url is required")
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &BitcoinHashSource{url: url, client: client}, nil
+}
+
+func (BitcoinHashSource) Name() string {
+	return BitcoinHashSourceName
+}
+
+// Hash returns the most recent Bitcoin block hash from the provider
+func (b BitcoinHashSource) Hash(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get bitcoin block hash")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get bitcoin block hash: %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read bitcoin block hash")
+	}
+
+	hash := strings.TrimSpace(string(body))
+	if decoded, err := hex.DecodeString(hash); err != nil || len(decoded) != 32 {
+		return "", fmt.Errorf("invalid bitcoin block hash: %q", hash)
+	}
+	return hash, nil
+}
+
+// challengeHash is a hash issued by a hash source and the time at which challenges against it expire
+type challengeHash struct {
+	hash   string
+	expiry time.Time
+}
+
+// ChallengeService issues rotating hashes for retention challenges and validates solutions against them. Solutions
+// are accepted against the current hash and, for one rotation period after it expires, the previous hash so that
+// clients solving a challenge at rotation time are not rejected.
+type ChallengeService struct {
+	source   HashSource
+	rotation time.Duration
+	now      func() time.Time
+
+	mu       sync.Mutex
+	current  *challengeHash
+	previous *challengeHash
+	// rotating is closed once the next hash has been fetched, nil if it is not being fetched
+	rotating chan struct{}
+}
+
+// NewChallengeService returns a new challenge service issuing hashes from the given source, rotated at the given
+// interval
+func NewChallengeService(source HashSource, rotation time.Duration) (*ChallengeService, error) {
+	if source == nil {
+		return nil, errors.New("hash source is required")
+	}
+	if rotation <= 0 {
+		return nil, errors.New("rotation interval must be positive")
+	}
+	return &ChallengeService{source: source, rotation: rotation, now: time.Now}, nil
+}
+
+// Current returns the current hash and the time at which it expires, rotating it if it has expired. The next hash is
+// fetched without holding the lock, so solutions are validated while it is fetched, and concurrent callers wait for
+// the one fetch rather than each fetching it.
+func (c *ChallengeService) Current(ctx context.Context) (string, time.Time, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "ChallengeService.Current")
+	defer span.End()
+
+	for {
+		c.mu.Lock()
+		if c.current != nil && c.now().Before(c.current.expiry) {
+			hash, expiry := c.current.hash, c.current.expiry
+			c.mu.Unlock()
+			return hash, expiry, nil
+		}
+		rotating := c.rotating
+		if rotating == nil {
+			break
+		}
+		c.mu.Unlock()
+
+		// another caller is fetching the next hash, check again once it is done, or fetch it if that failed
+		select {
+		case <-rotating:
+		case <-ctx.Done():
+			return "", time.Time{}, ctx.Err()
+		}
+	}
+	rotating := make(chan struct{})
+	c.rotating = rotating
+	c.mu.Unlock()
+
+	hash, err := c.source.Hash(ctx)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rotating = nil
+	close(rotating)
+	if err != nil {
+		return "", time.Time{}, errors.Wrapf(err, "failed to get hash from %s hash source", c.source.Name())
+	}
+
+	now := c.now()
+	if c.current != nil && c.current.hash == hash {
+		// some sources (e.g. bitcoin) may not have moved on yet, so keep issuing the same hash for another period
+		c.current.expiry = now.Add(c.rotation)
+		return c.current.hash, c.current.expiry, nil
+	}
+
+	c.previous = c.current
+	c.current = &challengeHash{hash: hash, expiry: now.Add(c.rotation)}
+	logrus.WithContext(ctx).WithField("hash_source", c.source.Name()).Debug("rotated retention challenge hash")
+	return c.current.hash, c.current.expiry, nil
+}
+
+// Source returns the name of the hash source challenges are issued from
+func (c *ChallengeService) Source() string {
+	return c.source.Name()
+}
+
+// ValidateSolution returns true if the retention solution for the given DID is valid at the given difficulty
+// against either the current hash or the previous hash
+func (c *ChallengeService) ValidateSolution(didID, retentionSolution string, difficulty int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if c.current != nil && now.Before(c.current.expiry.Add(c.rotation)) &&
+		did.ValidateRetentionSolution(didID, c.current.hash, retentionSolution, difficulty) {
+		return true
+	}
+	return c.previous != nil && now.Before(c.previous.expiry.Add(c.rotation)) &&
+		did.ValidateRetentionSolution(didID, c.previous.hash, retentionSolution, difficulty)
+}
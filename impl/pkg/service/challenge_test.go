@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/TBD54566975/did-dht/config"
+	"github.com/TBD54566975/did-dht/internal/did"
+)
+
+// stubHashSource issues hashes from a fixed list, failing once the list is exhausted
+type stubHashSource struct {
+	hashes []string
+}
+
+func (*stubHashSource) Name() string {
+	return "stub"
+}
+
+func (s *stubHashSource) Hash(context.Context) (string, error) {
+	if len(s.hashes) == 0 {
+		return "", errors.New("no more hashes")
+	}
+	hash := s.hashes[0]
+	s.hashes = s.hashes[1:]
+	return hash, nil
+}
+
+// blockingHashSource issues numbered hashes, signalling each fetch on fetching and then waiting on release
+type blockingHashSource struct {
+	fetching chan struct{}
+	release  chan struct{}
+	fetched  atomic.Int32
+}
+
+func (*blockingHashSource) Name() string {
+	return "blocking"
+}
+
+func (s *blockingHashSource) Hash(ctx context.Context) (string, error) {
+	s.fetching <- struct{}{}
+	select {
+	case <-s.release:
+		return fmt.Sprintf("hash-%d", s.fetched.Add(1)), nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func TestChallengeService(t *testing.T) {
+	const (
+		didID      = "did:dht:uqaj3fcr9db6jg6o9pjs53iuftyj45r46aubogfaceqjbo6pp9sy"
+		difficulty = 4
+	)
+
+	newChallengeService := func(t *testing.T, hashes ...string) (*ChallengeService, *time.Time) {
+		c, err := NewChallengeService(&stubHashSource{hashes: hashes}, 10*time.Minute)
+		require.NoError(t, err)
+		now := time.Now()
+		c.now = func() time.Time { return now }
+		return c, &now
+	}
+
+	t.Run("test hash is reused until it expires", func(t *testing.T) {
+		c, now := newChallengeService(t, "first", "second")
+
+		hash, expiry, err := c.Current(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "first", hash)
+		assert.Equal(t, now.Add(10*time.Minute), expiry)
+
+		*now = now.Add(5 * time.Minute)
+		hash, _, err = c.Current(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "first", hash)
+
+		*now = now.Add(5 * time.Minute)
+		hash, _, err = c.Current(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "second", hash)
+	})
+
+	t.Run("test solutions against the current and previous hash are accepted", func(t *testing.T) {
+		c, now := newChallengeService(t, "first", "second", "third")
+
+		_, _, err := c.Current(context.Background())
+		require.NoError(t, err)
+		firstSolution := did.GenerateRetentionSolution(didID, "first", difficulty)
+		assert.True(t, c.ValidateSolution(didID, firstSolution, difficulty))
+
+		// rotate, the first hash is still accepted
+		*now = now.Add(10 * time.Minute)
+		_, _, err = c.Current(context.Background())
+		require.NoError(t, err)
+		assert.True(t, c.ValidateSolution(didID, firstSolution, difficulty))
+		assert.True(t, c.ValidateSolution(didID, did.GenerateRetentionSolution(didID, "second", difficulty), difficulty))
+
+		// rotate again, the first hash is no longer accepted
+		*now = now.Add(10 * time.Minute)
+		_, _, err = c.Current(context.Background())
+		require.NoError(t, err)
+		assert.False(t, c.ValidateSolution(didID, firstSolution, difficulty))
+
+		// an unknown hash is never accepted
+		assert.False(t, c.ValidateSolution(didID, did.GenerateRetentionSolution(didID, "unknown", difficulty), difficulty))
+	})
+
+	t.Run("test stale hashes are not accepted", func(t *testing.T) {
+		c, now := newChallengeService(t, "first")
+
+		_, _, err := c.Current(context.Background())
+		require.NoError(t, err)
+		solution := did.GenerateRetentionSolution(didID, "first", difficulty)
+
+		// no rotation has happened, but the hash is older than two periods
+		*now = now.Add(20 * time.Minute)
+		assert.False(t, c.ValidateSolution(didID, solution, difficulty))
+	})
+
+	t.Run("test hash is fetched without blocking validation", func(t *testing.T) {
+		source := &blockingHashSource{fetching: make(chan struct{}, 2), release: make(chan struct{}, 1)}
+		c, err := NewChallengeService(source, 10*time.Minute)
+		require.NoError(t, err)
+		source.release <- struct{}{}
+		_, _, err = c.Current(context.Background())
+		require.NoError(t, err)
+		<-source.fetching
+		solution := did.GenerateRetentionSolution(didID, "hash-1", difficulty)
+
+		// expire the hash, and rotate it while the source hangs
+		now := time.Now().Add(10 * time.Minute)
+		c.mu.Lock()
+		c.now = func() time.Time { return now }
+		c.mu.Unlock()
+		hashes := make(chan string, 2)
+		for range 2 {
+			go func() {
+				hash, _, _ := c.Current(context.Background())
+				hashes <- hash
+			}()
+		}
+		<-source.fetching
+
+		// solutions are validated, and callers give up, while the next hash is fetched
+		assert.True(t, c.ValidateSolution(didID, solution, difficulty))
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, _, err = c.Current(ctx)
+		assert.ErrorIs(t, err, context.Canceled)
+
+		// both callers get the hash from the one fetch
+		close(source.release)
+		assert.Equal(t, "hash-2", <-hashes)
+		assert.Equal(t, "hash-2", <-hashes)
+		assert.Len(t, source.fetching, 0)
+	})
+
+	t.Run("test hash source failure", func(t *testing.T) {
+		c, _ := newChallengeService(t)
+
+		_, _, err := c.Current(context.Background())
+		assert.ErrorContains(t, err, "no more hashes")
+	})
+
+	t.Run("test unsupported hash source", func(t *testing.T) {
+		_, err := NewHashSource(config.RetentionConfig{HashSource: "ethereum"})
+		assert.ErrorContains(t, err, "unsupported hash source: ethereum")
+	})
+}
+
+func TestBitcoinHashSource(t *testing.T) {
+	const blockHash = "00000000000000000002a7c4c1e48d76c5a37902165a270156b7a8d72728a054"
+
+	t.Run("test get block hash", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = fmt.Fprintln(w, blockHash)
+		}))
+		defer server.Close()
+
+		source, err := NewHashSource(config.RetentionConfig{HashSource: BitcoinHashSourceName, BitcoinBlockHashURL: server.URL})
+		require.NoError(t, err)
+		assert.Equal(t, BitcoinHashSourceName, source.Name())
+
+		hash, err := source.Hash(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, blockHash, hash)
+	})
+
+	t.Run("test invalid block hash", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = fmt.Fprint(w, "not a block hash")
+		}))
+		defer server.Close()
+
+		source, err := NewBitcoinHashSource(server.URL, server.Client())
+		require.NoError(t, err)
+
+		_, err = source.Hash(context.Background())
+		assert.ErrorContains(t, err, "invalid bitcoin block hash")
+	})
+
+	t.Run("test provider error", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+
+		source, err := NewBitcoinHashSource(server.URL, server.Client())
+		require.NoError(t, err)
+
+		_, err = source.Hash(context.Background())
+		assert.ErrorContains(t, err, "503 Service Unavailable")
+	})
+
+	t.Run("test missing url", func(t *testing.T) {
+		_, err := NewBitcoinHashSource("", nil)
+		assert.ErrorContains(t, err, "bitcoin block hash url is required")
+	})
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/TBD54566975/did-dht/pkg/dht"
+)
+
+// maxSequenceNumberSkew is how far in the future a sequence number, a unix timestamp in seconds, may be
+const maxSequenceNumberSkew = 2 * time.Hour
+
+var (
+	// ErrSequenceNumberTooLow is returned when a record with a higher sequence number has already been stored
+	ErrSequenceNumberTooLow = errors.New("a record with a higher sequence number already exists")
+	// ErrSequenceNumberConflict is returned when a record with the same sequence number and a lexicographically
+	// greater value has already been stored
+	ErrSequenceNumberConflict = errors.New("a record with the same sequence number and a greater value already exists")
+	// ErrSequenceNumberInFuture is returned when a record's sequence number is too far in the future
+	ErrSequenceNumberInFuture = errors.New("sequence number is too far in the future")
+)
+
+// resolveConflict applies the conflict resolution rules for a record written over an existing record, which may be
+// nil, returning whether the record is a refresh of the existing record https://did-dht.com/#conflict-resolution
+//
+// Records with a lower sequence number than the existing record are rejected. Records with an equal sequence number
+// and value are refreshes, and records with an equal sequence number and a different value are accepted only if
+// their value is lexicographically greater. Records with a sequence number more than two hours in the future are
+// rejected regardless of the existing record.
+func resolveConflict(existing *dht.BEP44Record, record dht.BEP44Record, now time.Time) (bool, error) {
+	if record.SequenceNumber > now.Add(maxSequenceNumberSkew).Unix() {
+		return false, ErrSequenceNumberInFuture
+	}
+	if existing == nil {
+		return false, nil
+	}
+
+	switch {
+	case record.SequenceNumber < existing.SequenceNumber:
+		return false, ErrSequenceNumberTooLow
+	case record.SequenceNumber > existing.SequenceNumber:
+		return false, nil
+	}
+	switch bytes.Compare(record.Value, existing.Value) {
+	case 0:
+		return true, nil
+	case 1:
+		return false, nil
+	default:
+		return false, ErrSequenceNumberConflict
+	}
+}
+
+// recordLocks serializes the writes of each record, so that concurrent writes are resolved against each other rather
+// than against the same stored record
+type recordLocks struct {
+	mu    sync.Mutex
+	locks map[string]*recordLock
+}
+
+type recordLock struct {
+	sync.Mutex
+	// waiters is the number of writers holding or waiting for the lock, which is dropped when there are none
+	waiters int
+}
+
+func newRecordLocks() *recordLocks {
+	return &recordLocks{locks: make(map[string]*recordLock)}
+}
+
+// lock locks the record with the given ID, returning the function which unlocks it
+func (l *recordLocks) lock(id string) func() {
+	l.mu.Lock()
+	lock, ok := l.locks[id]
+	if !ok {
+		lock = new(recordLock)
+		l.locks[id] = lock
+	}
+	lock.waiters++
+	l.mu.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		l.mu.Lock()
+		if lock.waiters--; lock.waiters == 0 {
+			delete(l.locks, id)
+		}
+		l.mu.Unlock()
+	}
+}
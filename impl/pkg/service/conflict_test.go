@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anacrolix/dht/v2/bep44"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/TBD54566975/did-dht/pkg/dht"
+)
+
+func TestResolveConflict(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	existing := &dht.BEP44Record{Value: []byte("b"), SequenceNumber: 100}
+
+	tests := []struct {
+		name        string
+		existing    *dht.BEP44Record
+		record      dht.BEP44Record
+		wantRefresh bool
+		wantErr     error
+	}{
+		{name: "no existing record", record: dht.BEP44Record{Value: []byte("a"), SequenceNumber: 1}},
+		{name: "higher sequence number", existing: existing, record: dht.BEP44Record{Value: []byte("a"), SequenceNumber: 101}},
+		{name: "lower sequence number", existing: existing, record: dht.BEP44Record{Value: []byte("c"), SequenceNumber: 99}, wantErr: ErrSequenceNumberTooLow},
+		{name: "refresh", existing: existing, record: dht.BEP44Record{Value: []byte("b"), SequenceNumber: 100}, wantRefresh: true},
+		{name: "equal sequence number greater value", existing: existing, record: dht.BEP44Record{Value: []byte("c"), SequenceNumber: 100}},
+		{name: "equal sequence number lesser value", existing: existing, record: dht.BEP44Record{Value: []byte("a"), SequenceNumber: 100}, wantErr: ErrSequenceNumberConflict},
+		{name: "sequence number within skew", record: dht.BEP44Record{Value: []byte("a"), SequenceNumber: now.Add(2 * time.Hour).Unix()}},
+		{name: "sequence number in the future", record: dht.BEP44Record{Value: []byte("a"), SequenceNumber: now.Add(2*time.Hour + time.Second).Unix()}, wantErr: ErrSequenceNumberInFuture},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			refresh, err := resolveConflict(test.existing, test.record, now)
+			assert.ErrorIs(t, err, test.wantErr)
+			assert.Equal(t, test.wantRefresh, refresh)
+		})
+	}
+}
+
+func TestPublishDHTConflicts(t *testing.T) {
+	svc := newDHTService(t, "conflicts")
+	// every write here is resolved against the others, rather than some being refused for updating the record too often
+	svc.recordWrites = nil
+	ctx := context.Background()
+
+	pk, sk, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	newRecord := func(v string, seq int64) dht.BEP44Record {
+		putMsg := &bep44.Put{V: []byte(v), K: (*[32]byte)(pk), Seq: seq}
+		putMsg.Sign(sk)
+		return dht.RecordFromBEP44(putMsg)
+	}
+
+	record := newRecord("b", 10)
+	id := record.ID()
+	require.NoError(t, svc.PublishDHT(ctx, id, record))
+
+	assert.ErrorIs(t, svc.PublishDHT(ctx, id, newRecord("c", 9)), ErrSequenceNumberTooLow)
+	assert.ErrorIs(t, svc.PublishDHT(ctx, id, newRecord("a", 10)), ErrSequenceNumberConflict)
+	assert.ErrorIs(t, svc.PublishDHT(ctx, id, newRecord("c", time.Now().Add(3*time.Hour).Unix())), ErrSequenceNumberInFuture)
+
+	// a greater value wins the tie
+	tieBreak := newRecord("c", 10)
+	require.NoError(t, svc.PublishDHT(ctx, id, tieBreak))
+	stored, err := svc.db.ReadRecord(ctx, id)
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+	assert.Equal(t, tieBreak, *stored)
+
+	// republishing the stored record is a refresh
+	assert.NoError(t, svc.PublishDHT(ctx, id, tieBreak))
+
+	t.Run("test concurrent writes", func(t *testing.T) {
+		// whichever order concurrent writes of the same sequence number land in, the greatest value is stored
+		seq := time.Now().Unix()
+		var wg sync.WaitGroup
+		for i := range 64 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := svc.PublishDHT(ctx, id, newRecord(fmt.Sprintf("%02d", i), seq))
+				if err != nil {
+					assert.ErrorIs(t, err, ErrSequenceNumberConflict)
+				}
+			}()
+		}
+		wg.Wait()
+
+		stored, err := svc.db.ReadRecord(ctx, id)
+		require.NoError(t, err)
+		require.NotNil(t, stored)
+		assert.Equal(t, newRecord("63", seq), *stored)
+	})
+
+	t.Cleanup(func() { svc.Close() })
+}
+
+func TestRecordLocks(t *testing.T) {
+	locks := newRecordLocks()
+	unlock := locks.lock("a")
+
+	// other records are not blocked
+	locks.lock("b")()
+
+	locked := make(chan struct{})
+	go func() {
+		defer locks.lock("a")()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+		t.Fatal("record locked twice")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	unlock()
+	<-locked
+	assert.Eventually(t, func() bool {
+		locks.mu.Lock()
+		defer locks.mu.Unlock()
+		return len(locks.locks) == 0
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"math"
+	"time"
+
+	ssiutil "github.com/TBD54566975/ssi-sdk/util"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/TBD54566975/did-dht/internal/util"
+	"github.com/TBD54566975/did-dht/pkg/dht"
+	"github.com/TBD54566975/did-dht/pkg/telemetry"
+)
+
+// deletionRequestWindow is how far from the current time a deletion request may have been signed, which limits how
+// long a captured request can be replayed
+const deletionRequestWindow = 5 * time.Minute
+
+var (
+	// ErrRecordDeleted is returned when publishing a record whose key was deleted and is still tombstoned
+	ErrRecordDeleted = errors.New("record was deleted")
+	// ErrDeletionRequestExpired is returned for deletion requests signed too far from the current time
+	ErrDeletionRequestExpired = errors.New("deletion request timestamp is outside the accepted window")
+)
+
+// DeleteDHT deletes the record for the given z-base-32 encoded ID at the request of its controller, and tombstones its
+// key so it is not stored, cached, or republished again until the tombstone expires. Keys are tombstoned even if no
+// record is stored, so that it is not stored later. https://did-dht.com/#data-retention
+func (s *DHTService) DeleteDHT(ctx context.Context, id string, request dht.DeletionRequest) (*dht.Tombstone, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "DHTService.DeleteDHT")
+	defer span.End()
+
+	if request.ID() != id {
+		return nil, errors.Wrapf(dht.ErrInvalidSignature, "deletion request is not signed by the key of record: %s", id)
+	}
+	if err := request.IsValid(); err != nil {
+		return nil, err
+	}
+	signed := time.Unix(request.Timestamp, 0)
+	if age := time.Since(signed); age > deletionRequestWindow || age < -deletionRequestWindow {
+		return nil, ErrDeletionRequestExpired
+	}
+
+	return s.deleteRecord(ctx, id)
+}
+
+// ForceDeleteRecord deletes and tombstones the record for the given z-base-32 encoded ID like DeleteDHT, without a
+// request signed by its controller
+func (s *DHTService) ForceDeleteRecord(ctx context.Context, id string) (*dht.Tombstone, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "DHTService.ForceDeleteRecord")
+	defer span.End()
+
+	if _, err := util.Z32Decode(id); err != nil {
+		return nil, ssiutil.LoggingCtxErrorMsgf(ctx, err, "failed to decode z-base-32 encoded ID: %s", id)
+	}
+	return s.deleteRecord(ctx, id)
+}
+
+// ListTombstones returns the tombstones of deleted records, including expired tombstones that have not been pruned
+func (s *DHTService) ListTombstones(ctx context.Context) ([]dht.Tombstone, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "DHTService.ListTombstones")
+	defer span.End()
+
+	return s.db.ListTombstones(ctx)
+}
+
+// LiftTombstone removes the tombstone for the given z-base-32 encoded ID so its record can be stored again, reporting
+// whether there was one
+func (s *DHTService) LiftTombstone(ctx context.Context, id string) (bool, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "DHTService.LiftTombstone")
+	defer span.End()
+
+	tombstone, err := s.db.ReadTombstone(ctx, id)
+	if err != nil {
+		return false, ssiutil.LoggingCtxErrorMsgf(ctx, err, "failed to read tombstone: %s", id)
+	}
+	if tombstone == nil {
+		return false, nil
+	}
+	if err = s.db.DeleteTombstone(ctx, id); err != nil {
+		return false, ssiutil.LoggingCtxErrorMsgf(ctx, err, "failed to delete tombstone: %s", id)
+	}
+	logrus.WithContext(ctx).WithField("record_id", id).Info("lifted tombstone")
+	return true, nil
+}
+
+// deleteRecord tombstones the key and removes its record from storage, its history, the caches, the Retained DID Set,
+// the type index, and the outbox, republish, and retry queues
+func (s *DHTService) deleteRecord(ctx context.Context, id string) (*dht.Tombstone, error) {
+	now := time.Now()
+	tombstone := dht.Tombstone{ID: id, Deleted: now.Unix()}
+	if days := s.cfg.DHTConfig.TombstoneDays; days > 0 {
+		tombstone.Expiry = now.Add(time.Duration(days) * 24 * time.Hour).Unix()
+	}
+
+	// tombstone the key first, so the record is not published again while it is being deleted, and wait for any
+	// write of it in progress
+	defer s.recordLocks.lock(id)()
+	if err := s.db.WriteTombstone(ctx, tombstone); err != nil {
+		return nil, ssiutil.LoggingCtxErrorMsgf(ctx, err, "failed to write tombstone: %s", id)
+	}
+	if err := s.purgeRecord(ctx, id); err != nil {
+		return nil, ssiutil.LoggingCtxErrorMsgf(ctx, err, "failed to delete record: %s", id)
+	}
+	if err := s.db.DeleteOutboxRecord(ctx, id, math.MaxInt64); err != nil {
+		return nil, ssiutil.LoggingCtxErrorMsgf(ctx, err, "failed to remove record from outbox: %s", id)
+	}
+	if err := s.db.WriteDIDTypes(ctx, id, nil); err != nil {
+		return nil, ssiutil.LoggingCtxErrorMsgf(ctx, err, "failed to remove record from type index: %s", id)
+	}
+	logrus.WithContext(ctx).WithField("record_id", id).WithField("expiry", tombstone.Expiry).Info("deleted record")
+	return &tombstone, nil
+}
+
+// isTombstoned returns true if the given z-base-32 encoded ID has a tombstone which has not expired
+func (s *DHTService) isTombstoned(ctx context.Context, id string) (bool, error) {
+	tombstone, err := s.db.ReadTombstone(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	return tombstone != nil && tombstone.IsActive(time.Now().Unix()), nil
+}
+
+// pruneTombstones deletes expired tombstones, after which their keys can be stored again
+func (s *DHTService) pruneTombstones(ctx context.Context) error {
+	tombstones, err := s.db.ListTombstones(ctx)
+	if err != nil {
+		return err
+	}
+	now := time.Now().Unix()
+	var pruned int
+	for _, tombstone := range tombstones {
+		if tombstone.IsActive(now) {
+			continue
+		}
+		if err = s.db.DeleteTombstone(ctx, tombstone.ID); err != nil {
+			return err
+		}
+		pruned++
+	}
+	if pruned > 0 {
+		logrus.WithContext(ctx).WithField("pruned_count", pruned).Info("pruned expired tombstones")
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"crypto/ed25519"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/TBD54566975/did-dht/internal/did"
+	"github.com/TBD54566975/did-dht/pkg/dht"
+)
+
+func TestDeleteDHT(t *testing.T) {
+	svc := newDHTService(t, "deletion")
+	ctx := context.Background()
+
+	publish := func(t *testing.T) (ed25519.PrivateKey, dht.BEP44Record) {
+		sk, doc, err := did.GenerateDIDDHT(did.CreateDIDDHTOpts{})
+		require.NoError(t, err)
+		packet, err := did.DHT(doc.ID).ToDNSPacket(*doc, nil, nil, nil)
+		require.NoError(t, err)
+		putMsg, err := dht.CreateDNSPublishRequest(sk, *packet)
+		require.NoError(t, err)
+
+		record := dht.RecordFromBEP44(putMsg)
+		require.NoError(t, svc.PublishDHT(ctx, record.ID(), record))
+		return sk, record
+	}
+
+	t.Run("test delete with a signed request", func(t *testing.T) {
+		sk, record := publish(t)
+		id := record.ID()
+
+		tombstone, err := svc.DeleteDHT(ctx, id, dht.SignDeletionRequest(sk, time.Now().Unix()))
+		require.NoError(t, err)
+		require.NotNil(t, tombstone)
+		assert.Greater(t, tombstone.Expiry, time.Now().Add(29*24*time.Hour).Unix())
+
+		stored, err := svc.db.ReadRecord(ctx, id)
+		assert.NoError(t, err)
+		assert.Nil(t, stored)
+		seqs, err := svc.db.ListSequenceNumbers(ctx, id)
+		assert.NoError(t, err)
+		assert.Empty(t, seqs)
+		assert.False(t, svc.republisher.isScheduled(id))
+		_, err = svc.cache.Get(id)
+		assert.Error(t, err)
+
+		// the key is neither resolved nor accepted again
+		got, err := svc.GetDHT(ctx, id)
+		assert.NoError(t, err)
+		assert.Nil(t, got)
+		assert.ErrorIs(t, svc.PublishDHT(ctx, id, record), ErrRecordDeleted)
+	})
+
+	t.Run("test invalid deletion requests", func(t *testing.T) {
+		sk, record := publish(t)
+		id := record.ID()
+
+		_, otherSK, err := ed25519.GenerateKey(nil)
+		require.NoError(t, err)
+		_, err = svc.DeleteDHT(ctx, id, dht.SignDeletionRequest(otherSK, time.Now().Unix()))
+		assert.ErrorIs(t, err, dht.ErrInvalidSignature)
+
+		forged := dht.SignDeletionRequest(sk, time.Now().Unix())
+		forged.Timestamp++
+		_, err = svc.DeleteDHT(ctx, id, forged)
+		assert.ErrorIs(t, err, dht.ErrInvalidSignature)
+
+		_, err = svc.DeleteDHT(ctx, id, dht.SignDeletionRequest(sk, time.Now().Add(-time.Hour).Unix()))
+		assert.ErrorIs(t, err, ErrDeletionRequestExpired)
+
+		stored, err := svc.db.ReadRecord(ctx, id)
+		assert.NoError(t, err)
+		assert.NotNil(t, stored)
+	})
+
+	t.Run("test force delete and lift tombstone", func(t *testing.T) {
+		_, record := publish(t)
+		id := record.ID()
+
+		_, err := svc.ForceDeleteRecord(ctx, "---")
+		assert.Error(t, err)
+
+		_, err = svc.ForceDeleteRecord(ctx, id)
+		require.NoError(t, err)
+		tombstones, err := svc.ListTombstones(ctx)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, tombstones)
+
+		lifted, err := svc.LiftTombstone(ctx, id)
+		assert.NoError(t, err)
+		assert.True(t, lifted)
+		lifted, err = svc.LiftTombstone(ctx, id)
+		assert.NoError(t, err)
+		assert.False(t, lifted)
+
+		assert.NoError(t, svc.PublishDHT(ctx, id, record))
+	})
+
+	t.Run("test expired tombstones are pruned", func(t *testing.T) {
+		_, record := publish(t)
+		id := record.ID()
+		require.NoError(t, svc.db.WriteTombstone(ctx, dht.Tombstone{ID: id, Deleted: 1, Expiry: 2}))
+
+		// an expired tombstone no longer refuses the key
+		assert.NoError(t, svc.PublishDHT(ctx, id, record))
+
+		require.NoError(t, svc.pruneTombstones(ctx))
+		tombstone, err := svc.db.ReadTombstone(ctx, id)
+		assert.NoError(t, err)
+		assert.Nil(t, tombstone)
+	})
+
+	t.Cleanup(func() { svc.Close() })
+}
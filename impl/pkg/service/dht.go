@@ -2,7 +2,7 @@ package service
 
 import (
 	"context"
-	"sync"
+	"fmt"
 	"time"
 
 	ssiutil "github.com/TBD54566975/ssi-sdk/util"
@@ -11,6 +11,7 @@ import (
 	"github.com/goccy/go-json"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
 
 	"github.com/TBD54566975/did-dht/internal/util"
 
@@ -21,7 +22,27 @@ import (
 	"github.com/TBD54566975/did-dht/pkg/telemetry"
 )
 
-const recordSizeLimitBytes = 1000
+const (
+	recordSizeLimitBytes = 1000
+
+	// BadGetCacheTTL is how long a key which failed to resolve is refused before it is looked up again
+	BadGetCacheTTL = 60 * time.Second
+)
+
+// ErrBadKeyRateLimited is returned when resolving a key which recently failed to resolve, to prevent spamming the DHT
+var ErrBadKeyRateLimited = errors.New("bad key rate limited to prevent spam")
+
+// RecordRateLimitError is returned when publishing a new version of a record which has been updated too often
+type RecordRateLimitError struct {
+	// ID is the z-base-32 encoded ID of the record
+	ID string
+	// RetryAfter is how long until the record may be updated again
+	RetryAfter time.Duration
+}
+
+func (e *RecordRateLimitError) Error() string {
+	return fmt.Sprintf("too many updates of record %s, retry after %s", e.ID, e.RetryAfter)
+}
 
 // DHTService is the service responsible for managing BEP44 DNS records in the DHT and reading/writing records
 type DHTService struct {
@@ -31,6 +52,15 @@ type DHTService struct {
 	cache       *bigcache.BigCache
 	badGetCache *bigcache.BigCache
 	scheduler   *dhtint.Scheduler
+	republisher *republisher
+	retrier     *retrier
+	outbox      *outbox
+	runs        *republishRuns
+	challenges  *ChallengeService
+	difficulty  *DifficultyController
+	recordLocks *recordLocks
+	// recordWrites limits how often each record is updated, nil if it is not limited
+	recordWrites *util.KeyedLimiter
 }
 
 // NewDHTService returns a new instance of the DHT service
@@ -51,30 +81,75 @@ func NewDHTService(cfg *config.Config, db storage.Storage, d *dht.DHT) (*DHTServ
 	}
 
 	// create a new cache for bad gets to prevent spamming the DHT
-	cacheConfig.LifeWindow = 60 * time.Second
-	cacheConfig.CleanWindow = 30 * time.Second
+	cacheConfig.LifeWindow = BadGetCacheTTL
+	cacheConfig.CleanWindow = BadGetCacheTTL / 2
 	badGetCache, err := bigcache.New(context.Background(), cacheConfig)
 	if err != nil {
 		return nil, ssiutil.LoggingErrorMsg(err, "failed to instantiate badGetCache")
 	}
 
-	// start scheduler for republishing
+	// issue retention challenges only if the Retained DID Set is enabled
+	var challenges *ChallengeService
+	var difficulty *DifficultyController
+	if cfg.RetentionConfig.Enabled {
+		hashSource, err := NewHashSource(cfg.RetentionConfig)
+		if err != nil {
+			return nil, ssiutil.LoggingErrorMsg(err, "failed to instantiate hash source")
+		}
+		challenges, err = NewChallengeService(hashSource, time.Duration(cfg.RetentionConfig.ChallengeRotationMinutes)*time.Minute)
+		if err != nil {
+			return nil, ssiutil.LoggingErrorMsg(err, "failed to instantiate challenge service")
+		}
+		difficulty = NewDifficultyController(&cfg.RetentionConfig, db)
+	}
+
+	// limit the updates of each record, counting only the new records which are stored
+	var recordWrites *util.KeyedLimiter
+	if rateLimit := cfg.ServerConfig.RateLimit; rateLimit.Enabled && rateLimit.RecordWriteIntervalMinutes > 0 {
+		interval := time.Duration(rateLimit.RecordWriteIntervalMinutes) * time.Minute
+		recordWrites = util.NewKeyedLimiter(rate.Every(interval), rateLimit.RecordWriteBurst)
+	}
+
+	// start scheduler for reconciling stored records with the republish schedule
 	scheduler := dhtint.NewScheduler()
 	svc := DHTService{
-		cfg:         cfg,
-		db:          db,
-		dht:         d,
-		cache:       cache,
-		badGetCache: badGetCache,
-		scheduler:   &scheduler,
+		cfg:          cfg,
+		db:           db,
+		dht:          d,
+		cache:        cache,
+		badGetCache:  badGetCache,
+		scheduler:    &scheduler,
+		runs:         newRepublishRuns(),
+		challenges:   challenges,
+		difficulty:   difficulty,
+		recordLocks:  newRecordLocks(),
+		recordWrites: recordWrites,
 	}
 	if err = scheduler.Schedule(cfg.DHTConfig.RepublishCRON, svc.republish); err != nil {
 		return nil, ssiutil.LoggingErrorMsg(err, "failed to start republisher")
 	}
+
+	// retry failed puts with backoff, and republish each record on its own schedule, scheduling any records which have
+	// never been scheduled first
+	svc.retrier = newRetrier(cfg.DHTConfig, db, d)
+	svc.republisher = newRepublisher(cfg.DHTConfig, db, d, svc.retrier, func() {
+		svc.runs.run(RepublishTriggerStartup, func(ctx context.Context, run *RepublishRun) error {
+			return svc.reconcile(ctx, run, false)
+		})
+	})
+	if err = svc.republisher.start(); err != nil {
+		scheduler.Stop()
+		return nil, ssiutil.LoggingErrorMsg(err, "failed to start republisher")
+	}
+	svc.retrier.start()
+
+	// put records into the DHT once they are written, starting with any left queued by a previous run
+	svc.outbox = newOutbox(cfg.DHTConfig, db, d, svc.retrier)
+	svc.outbox.start()
 	return &svc, nil
 }
 
-// PublishDHT stores the record in the db, publishes the given DNS record to the DHT, and returns the z-base-32 encoded ID
+// PublishDHT stores the record in the db and queues it in the outbox to be put into the DHT
 func (s *DHTService) PublishDHT(ctx context.Context, id string, record dht.BEP44Record) error {
 	ctx, span := telemetry.GetTracer().Start(ctx, "DHTService.PublishDHT")
 	defer span.End()
@@ -88,19 +163,68 @@ func (s *DHTService) PublishDHT(ctx context.Context, id string, record dht.BEP44
 		return err
 	}
 
+	// the record is resolved against the stored record and written atomically with respect to other writes of it
+	defer s.recordLocks.lock(id)()
+
 	// check if the message is already in the cache
 	if got, err := s.cache.Get(id); err == nil {
 		var resp dht.BEP44Response
 		if err = json.Unmarshal(got, &resp); err == nil && record.Response().Equals(resp) {
 			logrus.WithContext(ctx).WithField("record_id", id).Debug("resolved dht record from cache with matching response")
+			telemetry.RecordPublish(ctx, telemetry.PublishUnchanged)
 			return nil
 		}
 	}
 
-	// write to db and cache
-	if err := s.db.WriteRecord(ctx, record); err != nil {
+	// deleted keys are refused until their tombstone expires
+	deleted, err := s.isTombstoned(ctx, id)
+	if err != nil {
+		return ssiutil.LoggingCtxErrorMsgf(ctx, err, "failed to read tombstone: %s", id)
+	}
+	if deleted {
+		logrus.WithContext(ctx).WithField("record_id", id).Info("rejected deleted dht record")
+		telemetry.RecordPublish(ctx, telemetry.PublishRejected)
+		return ErrRecordDeleted
+	}
+
+	// apply the conflict resolution rules against the stored record
+	existing, err := s.db.ReadRecord(ctx, id)
+	if err != nil {
+		return ssiutil.LoggingCtxErrorMsgf(ctx, err, "failed to read existing record: %s", id)
+	}
+	refresh, err := resolveConflict(existing, record, time.Now())
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).WithField("record_id", id).Info("rejected conflicting dht record")
+		telemetry.RecordPublish(ctx, telemetry.PublishRejected)
 		return err
 	}
+
+	// write to db and cache, refreshes are already stored and only need to be put back into the DHT
+	if refresh {
+		queued := dht.OutboxRecord{ID: id, Seq: record.SequenceNumber, Queued: time.Now().Unix()}
+		if err = s.db.WriteOutboxRecord(ctx, queued); err != nil {
+			return err
+		}
+	} else {
+		// only new records count towards the record's limit, so invalid, conflicting and repeated writes cannot use it up
+		if retryAfter := s.recordWrites.Reserve(id, time.Now()); retryAfter > 0 {
+			logrus.WithContext(ctx).WithField("record_id", id).Info("rejected rate limited dht record")
+			telemetry.RecordPublish(ctx, telemetry.PublishRateLimited)
+			return &RecordRateLimitError{ID: id, RetryAfter: retryAfter}
+		}
+		if err = s.db.WriteRecordWithOutbox(ctx, record); err != nil {
+			return err
+		}
+		if s.difficulty != nil {
+			s.difficulty.RecordWrite()
+		}
+		if err = s.pruneHistory(ctx, id); err != nil {
+			logrus.WithContext(ctx).WithError(err).WithField("record_id", id).Warn("failed to prune record history")
+		}
+		if err = s.indexDIDTypes(ctx, id, record); err != nil {
+			logrus.WithContext(ctx).WithError(err).WithField("record_id", id).Warn("failed to index did types")
+		}
+	}
 	recordBytes, err := json.Marshal(record.Response())
 	if err != nil {
 		return err
@@ -108,21 +232,23 @@ func (s *DHTService) PublishDHT(ctx context.Context, id string, record dht.BEP44
 	if err = s.cache.Set(id, recordBytes); err != nil {
 		return err
 	}
+	// the key is no longer bad now that it has a record, e.g. a did:key resolved before it was published
+	_ = s.badGetCache.Delete(id)
 	logrus.WithContext(ctx).WithField("record_id", id).Debug("added dht record to cache and db")
 
-	// return here and put it in the DHT asynchronously
-	go func() {
-		// Create a new context with a timeout so that the parent context does not cancel the put
-		putCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
+	// the record is put now, so it is next due a full interval from now
+	if err = s.republisher.schedule(ctx, id, time.Now().Add(s.republisher.interval)); err != nil {
+		logrus.WithContext(ctx).WithError(err).WithField("record_id", id).Warn("failed to schedule record for republishing")
+	}
 
-		if _, err = s.dht.Put(putCtx, record.Put()); err != nil {
-			logrus.WithContext(ctx).WithField("record_id", id).WithError(err).Warnf("error from dht.Put for record: %s", id)
-		} else {
-			logrus.WithContext(ctx).WithField("record_id", id).Debug("put record to DHT")
-		}
-	}()
+	if refresh {
+		telemetry.RecordPublish(ctx, telemetry.PublishRefreshed)
+	} else {
+		telemetry.RecordPublish(ctx, telemetry.PublishStored)
+	}
 
+	// return here and put it in the DHT asynchronously
+	s.outbox.notify()
 	return nil
 }
 
@@ -137,20 +263,36 @@ func (s *DHTService) GetDHT(ctx context.Context, id string) (*dht.BEP44Response,
 	}
 
 	// if the key is in the badGetCache, return an error
-	if _, err := s.badGetCache.Get(id); err == nil {
-		return nil, ssiutil.LoggingCtxErrorMsgf(ctx, err, "bad key [%s] rate limited to prevent spam", id)
+	_, err := s.badGetCache.Get(id)
+	telemetry.RecordCacheLookup(ctx, telemetry.CacheBadGet, err == nil)
+	if err == nil {
+		return nil, ssiutil.LoggingCtxErrorMsgf(ctx, ErrBadKeyRateLimited, "bad key [%s]", id)
 	}
 
 	// first do a cache lookup
-	if got, err := s.cache.Get(id); err == nil {
+	cached, err := s.cache.Get(id)
+	telemetry.RecordCacheLookup(ctx, telemetry.CacheRecords, err == nil)
+	if err == nil {
 		var resp dht.BEP44Response
-		if err = json.Unmarshal(got, &resp); err == nil {
+		if err = json.Unmarshal(cached, &resp); err == nil {
 			logrus.WithContext(ctx).WithField("record_id", id).Info("resolved record from cache")
+			telemetry.RecordResolution(ctx, telemetry.ResolvedFromCache)
 			return &resp, nil
 		}
 		logrus.WithContext(ctx).WithError(err).WithField("record_id", id).Warn("failed to get record from cache, falling back to dht")
 	}
 
+	// deleted keys are neither resolved nor cached until their tombstone expires
+	deleted, err := s.isTombstoned(ctx, id)
+	if err != nil {
+		return nil, ssiutil.LoggingCtxErrorMsgf(ctx, err, "failed to read tombstone: %s", id)
+	}
+	if deleted {
+		logrus.WithContext(ctx).WithField("record_id", id).Info("record was deleted")
+		telemetry.RecordResolution(ctx, telemetry.ResolvedNotFound)
+		return nil, nil
+	}
+
 	// next do a dht lookup with a timeout of 10 seconds
 	getCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
@@ -167,6 +309,8 @@ func (s *DHTService) GetDHT(ctx context.Context, id string) (*dht.BEP44Response,
 		if err != nil || record == nil {
 			logrus.WithContext(ctx).WithError(err).WithField("record_id", id).Error("failed to resolve record from storage; adding to bad get cache")
 
+			telemetry.RecordResolution(ctx, telemetry.ResolvedNotFound)
+
 			// add the key to the badGetCache to prevent spamming the DHT
 			if err = s.badGetCache.Set(id, []byte{0}); err != nil {
 				logrus.WithContext(ctx).WithError(err).WithField("record_id", id).Error("failed to set key in bad get cache")
@@ -176,6 +320,7 @@ func (s *DHTService) GetDHT(ctx context.Context, id string) (*dht.BEP44Response,
 		}
 
 		logrus.WithContext(ctx).WithField("record_id", id).Info("resolved record from storage")
+		telemetry.RecordResolution(ctx, telemetry.ResolvedFromStorage)
 		resp := record.Response()
 		// add the record back to the cache for future lookups
 		if err = s.addRecordToCache(id, record.Response()); err != nil {
@@ -199,6 +344,7 @@ func (s *DHTService) GetDHT(ctx context.Context, id string) (*dht.BEP44Response,
 		Seq: got.Seq,
 		Sig: got.Sig,
 	}
+	telemetry.RecordResolution(ctx, telemetry.ResolvedFromDHT)
 
 	// add the record to cache, do it here to avoid duplicate calculations
 	if err = s.addRecordToCache(id, resp); err != nil {
@@ -221,154 +367,95 @@ func (s *DHTService) addRecordToCache(id string, resp dht.BEP44Response) error {
 	return nil
 }
 
-// failedRecord is a struct to keep track of records that failed to be republished
-type failedRecord struct {
-	record     dht.BEP44Record
-	failureCnt int
+// republish reconciles the stored records with the republish schedule on the republish cron schedule
+func (s *DHTService) republish() {
+	s.runs.run(RepublishTriggerSchedule, func(ctx context.Context, run *RepublishRun) error {
+		return s.reconcile(ctx, run, false)
+	})
 }
 
-// TODO(gabe) make this more efficient. create a publish schedule based on each individual record, not all records
-// republish republishes all records in the db
-func (s *DHTService) republish() {
-	ctx, span := telemetry.GetTracer().Start(context.Background(), "DHTService.republish")
+// reconcile reconciles the stored records with the republish schedule: records which have never been scheduled are
+// spread across the republish interval, or scheduled immediately if forced, and records which should no longer be
+// republished according to the Retained DID Set, deactivated DIDs, and deleted records are unscheduled. Expired
+// tombstones are pruned.
+func (s *DHTService) reconcile(ctx context.Context, run *RepublishRun, force bool) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "DHTService.reconcileRepublishSchedule")
 	defer span.End()
 
-	recordCnt, err := s.db.RecordCount(ctx)
+	shouldRepublish, err := s.retentionFilter(ctx)
 	if err != nil {
-		logrus.WithContext(ctx).WithError(err).Error("failed to get record count before republishing")
-		return
+		logrus.WithContext(ctx).WithError(err).Error("failed to evaluate retained did set before republishing")
+		return err
+	}
+	if err = s.pruneTombstones(ctx); err != nil {
+		logrus.WithContext(ctx).WithError(err).Warn("failed to prune expired tombstones")
 	}
-	logrus.WithContext(ctx).WithField("record_count", recordCnt).Info("republishing records")
-
-	// republish all records in the db and handle failed records up to 3 times
-	failedRecords := s.republishRecords(ctx)
 
-	// handle failed records
-	logrus.WithContext(ctx).WithField("failed_record_count", len(failedRecords)).Info("handling failed records")
-	s.handleFailedRecords(ctx, failedRecords)
-}
+	// only records scheduled before the scan are unscheduled if it does not see them, since records published during
+	// the scan are scheduled but may be missed by it
+	scheduled := s.republisher.scheduledIDs()
 
-// republishRecords republishes all records in the db and returns a list of failed records to be retried
-func (s *DHTService) republishRecords(ctx context.Context) []failedRecord {
+	now := time.Now()
+	seen := make(map[string]bool)
 	var nextPageToken []byte
-	var seenRecords, batchCnt int32
-	var failedRecords []failedRecord
-	var recordsBatch []dht.BEP44Record
-	var err error
-
-	var wg sync.WaitGroup
-
 	for {
+		var recordsBatch []dht.BEP44Record
 		recordsBatch, nextPageToken, err = s.db.ListRecords(ctx, nextPageToken, 1000)
 		if err != nil {
 			logrus.WithContext(ctx).WithError(err).Error("failed to list record(s) for republishing")
-			continue
+			return err
 		}
 
-		batchSize := len(recordsBatch)
-		seenRecords += int32(batchSize)
-		if batchSize == 0 {
-			logrus.WithContext(ctx).Info("no records to republish")
-			break
-		}
-
-		logrus.WithContext(ctx).WithFields(logrus.Fields{
-			"record_count": batchSize,
-			"batch_number": batchCnt,
-			"total_seen":   seenRecords,
-		}).Debugf("republishing batch [%d] of [%d] records", batchCnt, batchSize)
-		batchCnt++
-
-		batchFailedRecords := s.republishBatch(ctx, &wg, recordsBatch)
-		failedRecords = append(failedRecords, batchFailedRecords...)
-
-		if nextPageToken == nil {
-			break
-		}
-	}
-
-	wg.Wait()
-
-	successRate := float64(seenRecords-int32(len(failedRecords))) / float64(seenRecords) * 100
-	logrus.WithContext(ctx).WithFields(logrus.Fields{
-		"success": seenRecords - int32(len(failedRecords)),
-		"errors":  len(failedRecords),
-		"total":   seenRecords,
-	}).Infof("republishing complete with [%d] batches of [%d] total records with a [%.2f] percent success rate", batchCnt, seenRecords, successRate)
-
-	return failedRecords
-}
-
-// republishBatch republishes a batch of records and returns a list of failed records to be retried
-func (s *DHTService) republishBatch(ctx context.Context, wg *sync.WaitGroup, recordsBatch []dht.BEP44Record) []failedRecord {
-	failedRecordsChan := make(chan failedRecord, len(recordsBatch))
-	var failedRecords []failedRecord
-
-	for _, record := range recordsBatch {
-		wg.Add(1)
-		go func(record dht.BEP44Record) {
-			defer wg.Done()
-
+		for _, record := range recordsBatch {
 			id := record.ID()
-			putCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
-			defer cancel()
-
-			if _, putErr := s.dht.Put(putCtx, record.Put()); putErr != nil {
-				if errors.Is(putErr, context.DeadlineExceeded) {
-					logrus.WithContext(putCtx).WithField("record_id", id).Debug("republish timeout exceeded")
-				} else {
-					logrus.WithContext(putCtx).WithField("record_id", id).WithError(putErr).Debug("failed to republish record")
+			seen[id] = true
+
+			// deactivated DIDs are left to expire from the DHT
+			if !shouldRepublish(id) || isDeactivated(id, record) {
+				if err = s.republisher.unschedule(ctx, id); err != nil {
+					logrus.WithContext(ctx).WithError(err).WithField("record_id", id).Warn("failed to unschedule record")
 				}
-				failedRecordsChan <- failedRecord{
-					record:     record,
-					failureCnt: 1,
+				run.Unscheduled++
+				continue
+			}
+			due := now
+			if !force {
+				if s.republisher.isScheduled(id) {
+					continue
 				}
+				due = s.republisher.spread(id, now)
 			}
-		}(record)
-	}
-
-	wg.Wait()
-	close(failedRecordsChan)
-
-	for fr := range failedRecordsChan {
-		failedRecords = append(failedRecords, fr)
-	}
-	return failedRecords
-}
-
-// handleFailedRecords attempts to republish failed records up to 3 times
-func (s *DHTService) handleFailedRecords(ctx context.Context, failedRecords []failedRecord) {
-	for _, fr := range failedRecords {
-		retryCount := 0
-		for retryCount < 3 {
-			id := fr.record.ID()
-			putCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
-			defer cancel()
-
-			if _, putErr := s.dht.Put(putCtx, fr.record.Put()); putErr != nil {
-				logrus.WithContext(putCtx).WithField("record_id", id).WithError(putErr).Debugf("failed to re-republish [%s], attempt: %d", id, retryCount+1)
-				retryCount++
-			} else {
-				break
+			if err = s.republisher.schedule(ctx, id, due); err != nil {
+				logrus.WithContext(ctx).WithError(err).WithField("record_id", id).Warn("failed to schedule record for republishing")
+				continue
 			}
+			run.Scheduled++
 		}
 
-		if retryCount == 3 {
-			id := fr.record.ID()
-			logrus.WithContext(ctx).WithField("record_id", id).Error("record failed to republish after 3 attempts")
-			if err := s.db.WriteFailedRecord(ctx, id); err != nil {
-				logrus.WithContext(ctx).WithField("record_id", id).WithError(err).Warn("failed to write failed record to db")
-			}
+		if nextPageToken == nil {
+			break
 		}
 	}
+	run.RecordCount = len(seen)
 
-	failedRecordCnt, err := s.db.FailedRecordCount(ctx)
-	if err != nil {
-		logrus.WithContext(ctx).WithError(err).Error("failed to get failed record count")
-		return
+	// records deleted from storage are no longer republished
+	for _, id := range scheduled {
+		if seen[id] {
+			continue
+		}
+		if err = s.republisher.unschedule(ctx, id); err != nil {
+			logrus.WithContext(ctx).WithError(err).WithField("record_id", id).Warn("failed to unschedule record")
+		}
+		run.Unscheduled++
 	}
 
-	logrus.WithContext(ctx).WithField("failed_record_count", failedRecordCnt).Warn("total count of record that failed to republish")
+	logrus.WithContext(ctx).WithFields(logrus.Fields{
+		"trigger":           run.Trigger,
+		"record_count":      run.RecordCount,
+		"scheduled_count":   run.Scheduled,
+		"unscheduled_count": run.Unscheduled,
+	}).Info("reconciled republish schedule")
+	return nil
 }
 
 // Close closes the Mainline service gracefully
@@ -379,6 +466,10 @@ func (s *DHTService) Close() {
 	if s.scheduler != nil {
 		s.scheduler.Stop()
 	}
+	s.runs.stop()
+	s.outbox.stop()
+	s.republisher.stop()
+	s.retrier.stop()
 	if s.cache != nil {
 		if err := s.cache.Close(); err != nil {
 			logrus.WithError(err).Error("failed to close cache")
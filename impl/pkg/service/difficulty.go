@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/TBD54566975/did-dht/config"
+	"github.com/TBD54566975/did-dht/pkg/storage"
+	"github.com/TBD54566975/did-dht/pkg/telemetry"
+)
+
+const (
+	// writeRateWindow is the window over which the write rate is measured
+	writeRateWindow = time.Minute
+	// recordCountTTL is how long a record count is reused before it is read from storage again
+	recordCountTTL = time.Minute
+)
+
+// DifficultyController computes the retention challenge difficulty from the gateway's load, raising it as the gateway
+// nears capacity https://did-dht.com/#retained-did-set
+//
+// Load is the highest of the stored record count and the write rate relative to their configured capacities. Below
+// half of capacity the base difficulty is used. Above that, one bit is added each time the remaining capacity halves,
+// doubling the work required to retain a DID, up to the configured maximum. At or over capacity retention is
+// temporarily disabled.
+type DifficultyController struct {
+	cfg *config.RetentionConfig
+	db  storage.Storage
+	now func() time.Time
+
+	mu sync.Mutex
+	// current and previous are the current and last differing difficulties, and changed is when the difficulty last
+	// changed. Solutions at the previous difficulty are accepted for one challenge rotation after the change.
+	current, previous int
+	changed           time.Time
+	// recordCount is the last record count read from storage, at recordCountTime
+	recordCount     int
+	recordCountTime time.Time
+	// windowStart is the start of the current write rate window, and windowWrites and lastWindowWrites are the number
+	// of writes in the current and last windows
+	windowStart                    time.Time
+	windowWrites, lastWindowWrites int
+}
+
+// NewDifficultyController returns a new difficulty controller for the given retention config
+func NewDifficultyController(cfg *config.RetentionConfig, db storage.Storage) *DifficultyController {
+	return &DifficultyController{
+		cfg:      cfg,
+		db:       db,
+		now:      time.Now,
+		current:  cfg.Difficulty,
+		previous: cfg.Difficulty,
+	}
+}
+
+// RecordWrite records a write to storage, counted towards the write rate
+func (d *DifficultyController) RecordWrite() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.advanceWindow()
+	d.windowWrites++
+}
+
+// Difficulty returns the current difficulty, or ErrRetentionUnavailable if the gateway is at capacity
+func (d *DifficultyController) Difficulty(ctx context.Context) (int, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "DifficultyController.Difficulty")
+	defer span.End()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	if d.db != nil && d.cfg.RecordCapacity > 0 && now.Sub(d.recordCountTime) >= recordCountTTL {
+		recordCount, err := d.db.RecordCount(ctx)
+		if err != nil {
+			return 0, errors.Wrap(err, "failed to get record count")
+		}
+		d.recordCount = recordCount
+		d.recordCountTime = now
+	}
+	d.advanceWindow()
+
+	load := d.load()
+	if load >= 1 {
+		logrus.WithContext(ctx).WithField("load", load).Warn("gateway at capacity, retention temporarily disabled")
+		return 0, ErrRetentionUnavailable
+	}
+
+	difficulty := d.cfg.Difficulty
+	if load > 0.5 {
+		difficulty += int(math.Ceil(math.Log2(1 / (1 - load))))
+	}
+	if d.cfg.MaxDifficulty > 0 && difficulty > d.cfg.MaxDifficulty {
+		difficulty = d.cfg.MaxDifficulty
+	}
+
+	if difficulty != d.current {
+		logrus.WithContext(ctx).WithFields(logrus.Fields{
+			"load":       load,
+			"difficulty": difficulty,
+		}).Info("adjusted retention difficulty")
+		d.previous, d.current = d.current, difficulty
+		d.changed = now
+	}
+	return difficulty, nil
+}
+
+// AcceptedDifficulty returns the lowest difficulty a solution is accepted at. For one challenge rotation after a change
+// in difficulty this is the lower of the current and previous difficulties, so that solutions computed just before the
+// change are not rejected, and after that it is the current difficulty.
+func (d *DifficultyController) AcceptedDifficulty(ctx context.Context) (int, error) {
+	difficulty, err := d.Difficulty(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	gracePeriod := time.Duration(d.cfg.ChallengeRotationMinutes) * time.Minute
+	if d.now().Sub(d.changed) < gracePeriod {
+		return min(difficulty, d.previous), nil
+	}
+	return difficulty, nil
+}
+
+// load returns the highest of the record count and write rate relative to their capacities, ignoring any capacity
+// which is not configured
+func (d *DifficultyController) load() float64 {
+	var load float64
+	if d.cfg.RecordCapacity > 0 {
+		load = max(load, float64(d.recordCount)/float64(d.cfg.RecordCapacity))
+	}
+	if d.cfg.WriteRateCapacity > 0 {
+		writeRate := max(d.windowWrites, d.lastWindowWrites)
+		load = max(load, float64(writeRate)/float64(d.cfg.WriteRateCapacity))
+	}
+	return load
+}
+
+// advanceWindow moves the write rate window forward to the current time, must be called with the lock held
+func (d *DifficultyController) advanceWindow() {
+	now := d.now()
+	elapsed := now.Sub(d.windowStart)
+	if elapsed < writeRateWindow {
+		return
+	}
+	if elapsed < 2*writeRateWindow {
+		d.lastWindowWrites = d.windowWrites
+	} else {
+		d.lastWindowWrites = 0
+	}
+	d.windowWrites = 0
+	d.windowStart = now
+}
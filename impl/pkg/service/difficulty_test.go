@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/TBD54566975/did-dht/config"
+	"github.com/TBD54566975/did-dht/pkg/storage"
+)
+
+// recordCountStorage is a storage.Storage reporting a fixed record count
+type recordCountStorage struct {
+	storage.Storage
+	count int
+}
+
+func (s *recordCountStorage) RecordCount(context.Context) (int, error) {
+	return s.count, nil
+}
+
+func TestDifficultyController(t *testing.T) {
+	newController := func(cfg *config.RetentionConfig, db storage.Storage) (*DifficultyController, *time.Time) {
+		d := NewDifficultyController(cfg, db)
+		now := time.Now()
+		d.now = func() time.Time { return now }
+		return d, &now
+	}
+
+	t.Run("test base difficulty without capacities", func(t *testing.T) {
+		d, _ := newController(&config.RetentionConfig{Difficulty: 26, MaxDifficulty: 32}, nil)
+		for range 100 {
+			d.RecordWrite()
+		}
+
+		difficulty, err := d.Difficulty(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 26, difficulty)
+	})
+
+	t.Run("test difficulty rises with record count", func(t *testing.T) {
+		db := &recordCountStorage{}
+		d, now := newController(&config.RetentionConfig{Difficulty: 26, MaxDifficulty: 28, RecordCapacity: 1000}, db)
+
+		for _, tc := range []struct {
+			count      int
+			difficulty int
+		}{
+			{count: 0, difficulty: 26},
+			{count: 500, difficulty: 26},
+			{count: 600, difficulty: 28},
+			{count: 750, difficulty: 28},
+			{count: 999, difficulty: 28},
+		} {
+			db.count = tc.count
+			*now = now.Add(recordCountTTL)
+
+			difficulty, err := d.Difficulty(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, tc.difficulty, difficulty, "record count %d", tc.count)
+		}
+
+		// at capacity retention is temporarily disabled
+		db.count = 1000
+		*now = now.Add(recordCountTTL)
+		_, err := d.Difficulty(context.Background())
+		assert.ErrorIs(t, err, ErrRetentionUnavailable)
+	})
+
+	t.Run("test record count is cached", func(t *testing.T) {
+		db := &recordCountStorage{}
+		d, now := newController(&config.RetentionConfig{Difficulty: 26, MaxDifficulty: 32, RecordCapacity: 1000}, db)
+
+		difficulty, err := d.Difficulty(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 26, difficulty)
+
+		db.count = 1000
+		difficulty, err = d.Difficulty(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 26, difficulty)
+
+		*now = now.Add(recordCountTTL)
+		_, err = d.Difficulty(context.Background())
+		assert.ErrorIs(t, err, ErrRetentionUnavailable)
+	})
+
+	t.Run("test difficulty rises with write rate", func(t *testing.T) {
+		d, now := newController(&config.RetentionConfig{Difficulty: 26, MaxDifficulty: 32, WriteRateCapacity: 100}, nil)
+
+		for range 75 {
+			d.RecordWrite()
+		}
+		difficulty, err := d.Difficulty(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 28, difficulty)
+
+		// the last window's writes still count
+		*now = now.Add(writeRateWindow)
+		difficulty, err = d.Difficulty(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 28, difficulty)
+
+		// once writes stop the difficulty falls back
+		*now = now.Add(writeRateWindow)
+		difficulty, err = d.Difficulty(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 26, difficulty)
+
+		for range 100 {
+			d.RecordWrite()
+		}
+		_, err = d.Difficulty(context.Background())
+		assert.ErrorIs(t, err, ErrRetentionUnavailable)
+	})
+
+	t.Run("test accepted difficulty", func(t *testing.T) {
+		d, now := newController(&config.RetentionConfig{Difficulty: 26, MaxDifficulty: 32, WriteRateCapacity: 100, ChallengeRotationMinutes: 10}, nil)
+
+		accepted, err := d.AcceptedDifficulty(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 26, accepted)
+
+		// solutions at the previous difficulty are accepted for one challenge rotation after it rises
+		for range 75 {
+			d.RecordWrite()
+		}
+		difficulty, err := d.Difficulty(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 28, difficulty)
+
+		accepted, err = d.AcceptedDifficulty(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 26, accepted)
+
+		// keep the write rate up for the rest of the rotation, after which the raise is enforced
+		for range 9 {
+			*now = now.Add(writeRateWindow)
+			for range 75 {
+				d.RecordWrite()
+			}
+			accepted, err = d.AcceptedDifficulty(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, 26, accepted)
+		}
+		*now = now.Add(writeRateWindow)
+		for range 75 {
+			d.RecordWrite()
+		}
+		accepted, err = d.AcceptedDifficulty(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 28, accepted)
+	})
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+
+	ssiutil "github.com/TBD54566975/ssi-sdk/util"
+
+	"github.com/TBD54566975/did-dht/pkg/dht"
+	"github.com/TBD54566975/did-dht/pkg/telemetry"
+)
+
+// GetDHTAtSequence returns the record for the given z-base-32 encoded ID as it was at the given sequence number, or
+// nil if the sequence number has not been seen by the gateway https://did-dht.com/#historical-resolution
+func (s *DHTService) GetDHTAtSequence(ctx context.Context, id string, seq int64) (*dht.BEP44Response, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "DHTService.GetDHTAtSequence")
+	defer span.End()
+
+	record, err := s.db.ReadRecordAtSequence(ctx, id, seq)
+	if err != nil {
+		return nil, ssiutil.LoggingCtxErrorMsgf(ctx, err, "failed to read record at sequence number %d: %s", seq, id)
+	}
+	if record == nil {
+		// records stored before history was kept only have their latest version
+		if record, err = s.db.ReadRecord(ctx, id); err != nil {
+			return nil, ssiutil.LoggingCtxErrorMsgf(ctx, err, "failed to read record: %s", id)
+		}
+		if record == nil || record.SequenceNumber != seq {
+			return nil, nil
+		}
+	}
+
+	resp := record.Response()
+	return &resp, nil
+}
+
+// ListSequenceNumbers returns the sequence numbers seen for the given z-base-32 encoded ID in ascending order
+func (s *DHTService) ListSequenceNumbers(ctx context.Context, id string) ([]int64, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "DHTService.ListSequenceNumbers")
+	defer span.End()
+
+	seqs, err := s.db.ListSequenceNumbers(ctx, id)
+	if err != nil {
+		return nil, ssiutil.LoggingCtxErrorMsgf(ctx, err, "failed to list sequence numbers: %s", id)
+	}
+	if len(seqs) > 0 {
+		return seqs, nil
+	}
+
+	// records stored before history was kept only have their latest version
+	record, err := s.db.ReadRecord(ctx, id)
+	if err != nil {
+		return nil, ssiutil.LoggingCtxErrorMsgf(ctx, err, "failed to read record: %s", id)
+	}
+	if record == nil {
+		return nil, nil
+	}
+	return []int64{record.SequenceNumber}, nil
+}
+
+// pruneHistory drops the oldest versions of the record for the given z-base-32 encoded ID beyond the configured
+// history limit
+func (s *DHTService) pruneHistory(ctx context.Context, id string) error {
+	limit := s.cfg.DHTConfig.HistoryLimit
+	if limit <= 0 {
+		return nil
+	}
+	return s.db.PruneHistory(ctx, id, limit)
+}
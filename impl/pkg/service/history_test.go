@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/TBD54566975/did-dht/internal/did"
+	"github.com/TBD54566975/did-dht/pkg/dht"
+)
+
+func TestRecordHistory(t *testing.T) {
+	svc := newDHTService(t, "history")
+	svc.cfg.DHTConfig.HistoryLimit = 2
+	ctx := context.Background()
+
+	sk, doc, err := did.GenerateDIDDHT(did.CreateDIDDHTOpts{})
+	require.NoError(t, err)
+	packet, err := did.DHT(doc.ID).ToDNSPacket(*doc, nil, nil, nil)
+	require.NoError(t, err)
+	putMsg, err := dht.CreateDNSPublishRequest(sk, *packet)
+	require.NoError(t, err)
+
+	var records []dht.BEP44Record
+	for seq := int64(1); seq <= 3; seq++ {
+		putMsg.Seq = seq
+		putMsg.Sign(sk)
+		record := dht.RecordFromBEP44(putMsg)
+		require.NoError(t, svc.PublishDHT(ctx, record.ID(), record))
+		records = append(records, record)
+	}
+	id := records[0].ID()
+
+	t.Run("test history is capped at the limit", func(t *testing.T) {
+		seqs, err := svc.ListSequenceNumbers(ctx, id)
+		assert.NoError(t, err)
+		assert.Equal(t, []int64{2, 3}, seqs)
+	})
+
+	t.Run("test get dht at sequence", func(t *testing.T) {
+		got, err := svc.GetDHTAtSequence(ctx, id, 2)
+		assert.NoError(t, err)
+		require.NotNil(t, got)
+		assert.Equal(t, records[1].Response(), *got)
+
+		got, err = svc.GetDHTAtSequence(ctx, id, 1)
+		assert.NoError(t, err)
+		assert.Nil(t, got)
+	})
+
+	t.Run("test records without history fall back to the latest record", func(t *testing.T) {
+		require.NoError(t, svc.db.PruneHistory(ctx, id, 0))
+
+		seqs, err := svc.ListSequenceNumbers(ctx, id)
+		assert.NoError(t, err)
+		assert.Equal(t, []int64{3}, seqs)
+
+		got, err := svc.GetDHTAtSequence(ctx, id, 3)
+		assert.NoError(t, err)
+		require.NotNil(t, got)
+		assert.Equal(t, records[2].Response(), *got)
+
+		got, err = svc.GetDHTAtSequence(ctx, id, 2)
+		assert.NoError(t, err)
+		assert.Nil(t, got)
+	})
+
+	t.Cleanup(func() { svc.Close() })
+}
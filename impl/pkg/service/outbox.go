@@ -0,0 +1,280 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	ssiutil "github.com/TBD54566975/ssi-sdk/util"
+	"github.com/sirupsen/logrus"
+
+	"github.com/TBD54566975/did-dht/config"
+	"github.com/TBD54566975/did-dht/internal/util"
+	"github.com/TBD54566975/did-dht/pkg/dht"
+	"github.com/TBD54566975/did-dht/pkg/storage"
+	"github.com/TBD54566975/did-dht/pkg/telemetry"
+)
+
+// outboxPollInterval is the interval at which the outbox is checked for records which were not dispatched when queued,
+// such as after a storage error
+const outboxPollInterval = time.Minute
+
+// PublishState is the state of a stored record's latest sequence number in the DHT
+type PublishState string
+
+const (
+	// PublishStatePending is a record waiting in the outbox to be put into the DHT
+	PublishStatePending PublishState = "pending"
+	// PublishStateRetrying is a record which failed to be put into the DHT and is waiting to be retried
+	PublishStateRetrying PublishState = "retrying"
+	// PublishStateDeadLettered is a record which repeatedly failed to be put into the DHT and is no longer retried
+	PublishStateDeadLettered PublishState = "dead-lettered"
+	// PublishStatePublished is a record whose latest sequence number has been put into the DHT
+	PublishStatePublished PublishState = "published"
+)
+
+// PublishStatus is the status of a stored record's latest sequence number in the DHT
+type PublishStatus struct {
+	ID    string
+	Seq   int64
+	State PublishState
+	// Queued is the unix timestamp in seconds at which a pending record was queued
+	Queued int64
+	// Attempts, LastError, and NextAttempt describe the failed puts of a record which is retrying or dead-lettered
+	Attempts    int
+	LastError   string
+	NextAttempt int64
+}
+
+// GetPublishStatus returns whether the latest sequence number of the record with the given z-base-32 encoded ID has
+// been put into the DHT, or nil if the record is not stored
+func (s *DHTService) GetPublishStatus(ctx context.Context, id string) (*PublishStatus, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "DHTService.GetPublishStatus")
+	defer span.End()
+
+	if _, err := util.Z32Decode(id); err != nil {
+		return nil, ssiutil.LoggingCtxErrorMsgf(ctx, err, "failed to decode z-base-32 encoded ID: %s", id)
+	}
+
+	record, err := s.db.ReadRecord(ctx, id)
+	if err != nil {
+		return nil, ssiutil.LoggingCtxErrorMsgf(ctx, err, "failed to read record: %s", id)
+	}
+	if record == nil {
+		return nil, nil
+	}
+	status := PublishStatus{ID: id, Seq: record.SequenceNumber, State: PublishStatePublished}
+
+	queued, err := s.db.ReadOutboxRecord(ctx, id)
+	if err != nil {
+		return nil, ssiutil.LoggingCtxErrorMsgf(ctx, err, "failed to read outbox record: %s", id)
+	}
+	if queued != nil {
+		status.State = PublishStatePending
+		status.Queued = queued.Queued
+		return &status, nil
+	}
+
+	failed, err := s.db.ReadFailedRecord(ctx, id)
+	if err != nil {
+		return nil, ssiutil.LoggingCtxErrorMsgf(ctx, err, "failed to read failed record: %s", id)
+	}
+	if failed != nil {
+		status.State = PublishStateRetrying
+		if failed.DeadLettered {
+			status.State = PublishStateDeadLettered
+		}
+		status.Attempts = failed.Count
+		status.LastError = failed.LastError
+		status.NextAttempt = failed.NextAttempt
+	}
+	return &status, nil
+}
+
+// outbox puts records into the DHT once they have been written to storage. Each record is queued in storage along with
+// the record itself, so that its put survives restarts, and the queue is drained by a bounded number of workers.
+// Records which fail to be put are handed to the retrier.
+type outbox struct {
+	db           storage.Storage
+	dht          *dht.DHT
+	retrier      *retrier
+	workers      int
+	drainTimeout time.Duration
+
+	mu       sync.Mutex
+	inFlight map[string]bool
+	wake     chan struct{}
+	drain    chan struct{}
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newOutbox(cfg config.DHTServiceConfig, db storage.Storage, d *dht.DHT, retrier *retrier) *outbox {
+	drainTimeout := time.Duration(cfg.OutboxDrainTimeoutSeconds) * time.Second
+	if drainTimeout <= 0 {
+		drainTimeout = time.Duration(config.GetDefaultConfig().DHTConfig.OutboxDrainTimeoutSeconds) * time.Second
+	}
+	return &outbox{
+		db:           db,
+		dht:          d,
+		retrier:      retrier,
+		workers:      republishWorkers(cfg, d),
+		drainTimeout: drainTimeout,
+		inFlight:     make(map[string]bool),
+		wake:         make(chan struct{}, 1),
+		drain:        make(chan struct{}),
+	}
+}
+
+// start puts the records left in the outbox by a previous run, and then records as they are queued
+func (o *outbox) start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	o.cancel = cancel
+
+	jobs := make(chan dht.OutboxRecord)
+	o.wg.Add(o.workers + 1)
+	go func() {
+		defer o.wg.Done()
+		defer close(jobs)
+		o.dispatch(ctx, jobs)
+	}()
+	for range o.workers {
+		go func() {
+			defer o.wg.Done()
+			for job := range jobs {
+				handled := o.put(ctx, job)
+				o.mu.Lock()
+				delete(o.inFlight, job.ID)
+				o.mu.Unlock()
+				if handled {
+					o.notify()
+				}
+			}
+		}()
+	}
+}
+
+// stop drains the outbox, giving up on the remaining records after the drain timeout, which are put on restart
+func (o *outbox) stop() {
+	if o == nil || o.cancel == nil {
+		return
+	}
+	close(o.drain)
+
+	drained := make(chan struct{})
+	go func() {
+		o.wg.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-time.After(o.drainTimeout):
+		logrus.WithField("drain_timeout", o.drainTimeout).Warn("outbox not drained before timeout, remaining records are put on restart")
+		o.cancel()
+		<-drained
+	}
+	o.cancel()
+}
+
+// notify wakes the dispatcher to pick up newly queued records
+func (o *outbox) notify() {
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+// dispatch hands queued records to the workers, blocking while all workers are busy, and returns once the outbox has
+// been drained after stop is called
+func (o *outbox) dispatch(ctx context.Context, jobs chan<- dht.OutboxRecord) {
+	drain, draining := o.drain, false
+	for {
+		queued, err := o.db.ListOutbox(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			logrus.WithContext(ctx).WithError(err).Error("failed to list outbox")
+		}
+
+		pending := err != nil
+		for _, record := range queued {
+			pending = true
+			o.mu.Lock()
+			inFlight := o.inFlight[record.ID]
+			o.inFlight[record.ID] = true
+			o.mu.Unlock()
+			if inFlight {
+				continue
+			}
+
+			select {
+			case jobs <- record:
+			case <-ctx.Done():
+				return
+			}
+		}
+		if draining && !pending {
+			return
+		}
+
+		timer := time.NewTimer(outboxPollInterval)
+		select {
+		case <-timer.C:
+		case <-o.wake:
+			timer.Stop()
+		case <-drain:
+			timer.Stop()
+			// the drain channel stays closed, so stop selecting on it
+			drain, draining = nil, true
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// put puts the stored record into the DHT and removes it from the outbox, handing failures to the retrier. It returns
+// false if the record is left in the outbox, to be put again once the outbox is next checked.
+func (o *outbox) put(ctx context.Context, queued dht.OutboxRecord) bool {
+	ctx, span := telemetry.GetTracer().Start(ctx, "DHTService.putQueuedRecord")
+	defer span.End()
+
+	id := queued.ID
+	record, err := o.db.ReadRecord(ctx, id)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).WithField("record_id", id).Warn("failed to read queued record")
+		return false
+	}
+
+	// deleted records are no longer put
+	seq := queued.Seq
+	if record != nil {
+		putCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		seq = record.SequenceNumber
+		if _, err = o.dht.Put(putCtx, record.Put()); err != nil {
+			if ctx.Err() != nil {
+				// shutting down, the record is put on restart
+				return false
+			}
+			logrus.WithContext(ctx).WithError(err).WithField("record_id", id).Warnf("error from dht.Put for record: %s", id)
+			if _, err = o.retrier.recordFailure(ctx, id, err); err != nil {
+				logrus.WithContext(ctx).WithError(err).WithField("record_id", id).Warn("failed to write failed record")
+				return false
+			}
+		} else {
+			logrus.WithContext(ctx).WithField("record_id", id).Debug("put record to DHT")
+			if err = o.retrier.clear(ctx, id); err != nil {
+				logrus.WithContext(ctx).WithError(err).WithField("record_id", id).Warn("failed to clear failed record")
+			}
+		}
+	}
+
+	if err = o.db.DeleteOutboxRecord(ctx, id, seq); err != nil {
+		logrus.WithContext(ctx).WithError(err).WithField("record_id", id).Warn("failed to remove record from outbox")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/TBD54566975/did-dht/config"
+	"github.com/TBD54566975/did-dht/pkg/dht"
+)
+
+func TestGetPublishStatus(t *testing.T) {
+	svc := newDHTService(t, "publish-status")
+	ctx := context.Background()
+
+	record := newTestRecord(t)
+	id := record.ID()
+
+	status, err := svc.GetPublishStatus(ctx, id)
+	assert.NoError(t, err)
+	assert.Nil(t, status)
+
+	_, err = svc.GetPublishStatus(ctx, "---")
+	assert.Error(t, err)
+
+	// records written without notifying the outbox stay queued until it is next checked
+	require.NoError(t, svc.db.WriteRecordWithOutbox(ctx, record))
+	status, err = svc.GetPublishStatus(ctx, id)
+	require.NoError(t, err)
+	require.NotNil(t, status)
+	assert.Equal(t, PublishStatePending, status.State)
+	assert.Equal(t, record.SequenceNumber, status.Seq)
+	assert.NotZero(t, status.Queued)
+
+	require.NoError(t, svc.db.DeleteOutboxRecord(ctx, id, record.SequenceNumber))
+	status, err = svc.GetPublishStatus(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, PublishStatePublished, status.State)
+
+	failed := dht.FailedRecord{ID: id, Count: 2, LastError: "failed to put", NextAttempt: time.Now().Add(time.Hour).Unix()}
+	require.NoError(t, svc.db.WriteFailedRecord(ctx, failed))
+	status, err = svc.GetPublishStatus(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, PublishStateRetrying, status.State)
+	assert.Equal(t, 2, status.Attempts)
+	assert.Equal(t, "failed to put", status.LastError)
+	assert.Equal(t, failed.NextAttempt, status.NextAttempt)
+
+	failed.DeadLettered = true
+	require.NoError(t, svc.db.WriteFailedRecord(ctx, failed))
+	status, err = svc.GetPublishStatus(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, PublishStateDeadLettered, status.State)
+
+	t.Cleanup(func() { svc.Close() })
+}
+
+func TestOutbox(t *testing.T) {
+	svc := newDHTService(t, "outbox")
+	ctx := context.Background()
+
+	t.Run("test published record is drained from the outbox", func(t *testing.T) {
+		record := newTestRecord(t)
+		require.NoError(t, svc.PublishDHT(ctx, record.ID(), record))
+
+		assert.Eventually(t, func() bool {
+			queued, err := svc.db.ReadOutboxRecord(ctx, record.ID())
+			require.NoError(t, err)
+			return queued == nil
+		}, 15*time.Second, 100*time.Millisecond)
+
+		status, err := svc.GetPublishStatus(ctx, record.ID())
+		require.NoError(t, err)
+		assert.NotEqual(t, PublishStatePending, status.State)
+	})
+
+	t.Run("test deleted records are removed from the outbox", func(t *testing.T) {
+		queued := dht.OutboxRecord{ID: newTestRecord(t).ID(), Seq: 1, Queued: time.Now().Unix()}
+		require.NoError(t, svc.db.WriteOutboxRecord(ctx, queued))
+		svc.outbox.notify()
+
+		assert.Eventually(t, func() bool {
+			got, err := svc.db.ReadOutboxRecord(ctx, queued.ID)
+			require.NoError(t, err)
+			return got == nil
+		}, 5*time.Second, 100*time.Millisecond)
+	})
+
+	t.Run("test stop drains the outbox", func(t *testing.T) {
+		o := newOutbox(config.DHTServiceConfig{OutboxDrainTimeoutSeconds: 15}, svc.db, svc.dht, svc.retrier)
+		var ids []string
+		for range 3 {
+			record := newTestRecord(t)
+			require.NoError(t, svc.db.WriteRecordWithOutbox(ctx, record))
+			ids = append(ids, record.ID())
+		}
+
+		o.start()
+		o.stop()
+		for _, id := range ids {
+			queued, err := svc.db.ReadOutboxRecord(ctx, id)
+			assert.NoError(t, err)
+			assert.Nil(t, queued)
+		}
+	})
+
+	t.Cleanup(func() { svc.Close() })
+}
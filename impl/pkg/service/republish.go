@@ -0,0 +1,346 @@
+package service
+
+import (
+	"container/heap"
+	"context"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/TBD54566975/did-dht/config"
+	"github.com/TBD54566975/did-dht/pkg/dht"
+	"github.com/TBD54566975/did-dht/pkg/storage"
+	"github.com/TBD54566975/did-dht/pkg/telemetry"
+)
+
+const (
+	// putSendEstimate is roughly the number of messages a put sends: a traversal to find the nodes closest to the
+	// record's target, and a put to each of them
+	putSendEstimate = 24
+	// republishRetryDelay is the delay before a record which could not be read from storage is republished again
+	republishRetryDelay = time.Minute
+)
+
+// scheduledRepublish is a record in the republish queue
+type scheduledRepublish struct {
+	id    string
+	due   time.Time
+	index int
+}
+
+// republishQueue is a priority queue of records ordered by the time they are due to be republished
+type republishQueue []*scheduledRepublish
+
+func (q republishQueue) Len() int { return len(q) }
+
+func (q republishQueue) Less(i, j int) bool { return q[i].due.Before(q[j].due) }
+
+func (q republishQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *republishQueue) Push(x any) {
+	item := x.(*scheduledRepublish)
+	item.index = len(*q)
+	*q = append(*q, item)
+}
+
+func (q *republishQueue) Pop() any {
+	old := *q
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*q = old[:n-1]
+	return item
+}
+
+// republisher republishes each record on its own schedule, which is persisted so it survives restarts. Records are
+// spread evenly across the republish interval and put by a bounded number of workers, so that republishing does not
+// exhaust the DHT's send limiter. Records which fail to be put are handed to the retrier.
+type republisher struct {
+	db       storage.Storage
+	dht      *dht.DHT
+	retrier  *retrier
+	interval time.Duration
+	workers  int
+	// reconcile schedules stored records which have never been scheduled, and is run on start if nothing is, such as
+	// after upgrading from republishing all records at once
+	reconcile func()
+
+	mu        sync.Mutex
+	queue     republishQueue
+	scheduled map[string]*scheduledRepublish
+	wake      chan struct{}
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newRepublisher(cfg config.DHTServiceConfig, db storage.Storage, d *dht.DHT, retrier *retrier, reconcile func()) *republisher {
+	interval := time.Duration(cfg.RepublishIntervalMinutes) * time.Minute
+	if interval <= 0 {
+		interval = time.Duration(config.GetDefaultConfig().DHTConfig.RepublishIntervalMinutes) * time.Minute
+	}
+	return &republisher{
+		db:        db,
+		dht:       d,
+		retrier:   retrier,
+		interval:  interval,
+		workers:   republishWorkers(cfg, d),
+		reconcile: reconcile,
+		scheduled: make(map[string]*scheduledRepublish),
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+// republishWorkers returns the number of concurrent puts, which unless configured leaves half of the DHT's send
+// limiter burst to other traffic
+func republishWorkers(cfg config.DHTServiceConfig, d *dht.DHT) int {
+	if cfg.RepublishConcurrency > 0 {
+		return cfg.RepublishConcurrency
+	}
+	if d == nil || d.SendLimiter() == nil {
+		return 1
+	}
+	return max(1, d.SendLimiter().Burst()/2/putSendEstimate)
+}
+
+// start loads the persisted schedule and starts republishing records as they become due
+func (r *republisher) start() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+
+	scheduledRecords, err := r.db.ListRepublishSchedule(ctx)
+	if err != nil {
+		cancel()
+		return errors.Wrap(err, "failed to load republish schedule")
+	}
+	r.mu.Lock()
+	for _, record := range scheduledRecords {
+		r.enqueue(record.ID, time.Unix(record.Due, 0))
+	}
+	r.mu.Unlock()
+	logrus.WithFields(logrus.Fields{
+		"record_count": len(scheduledRecords),
+		"interval":     r.interval,
+		"worker_count": r.workers,
+		"next_due":     r.nextDue(),
+	}).Info("loaded republish schedule")
+
+	reconcile := false
+	if len(scheduledRecords) == 0 && r.reconcile != nil {
+		recordCnt, err := r.db.RecordCount(ctx)
+		if err != nil {
+			cancel()
+			return errors.Wrap(err, "failed to count records")
+		}
+		reconcile = recordCnt > 0
+	}
+
+	jobs := make(chan *scheduledRepublish)
+	r.wg.Add(r.workers + 1)
+	go func() {
+		defer r.wg.Done()
+		defer close(jobs)
+		if reconcile {
+			r.reconcile()
+		}
+		r.dispatch(ctx, jobs)
+	}()
+	for range r.workers {
+		go func() {
+			defer r.wg.Done()
+			for job := range jobs {
+				r.republish(ctx, job)
+			}
+		}()
+	}
+	return nil
+}
+
+// stop stops republishing, waiting for in-flight puts to finish
+func (r *republisher) stop() {
+	if r == nil || r.cancel == nil {
+		return
+	}
+	r.cancel()
+	r.wg.Wait()
+}
+
+// schedule persists the time at which the record with the given z-base-32 encoded ID is next republished
+func (r *republisher) schedule(ctx context.Context, id string, due time.Time) error {
+	if err := r.db.WriteRepublishSchedule(ctx, dht.ScheduledRecord{ID: id, Due: due.Unix()}); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.enqueue(id, due)
+	r.mu.Unlock()
+
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// unschedule stops republishing the record with the given z-base-32 encoded ID
+func (r *republisher) unschedule(ctx context.Context, id string) error {
+	r.mu.Lock()
+	if item, ok := r.scheduled[id]; ok {
+		heap.Remove(&r.queue, item.index)
+		delete(r.scheduled, id)
+	}
+	r.mu.Unlock()
+	return r.db.DeleteRepublishSchedule(ctx, id)
+}
+
+// isScheduled reports whether the record with the given z-base-32 encoded ID is waiting to be republished
+func (r *republisher) isScheduled(id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.scheduled[id]
+	return ok
+}
+
+// scheduledIDs returns the z-base-32 encoded IDs of all records waiting to be republished
+func (r *republisher) scheduledIDs() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ids := make([]string, 0, len(r.scheduled))
+	for id := range r.scheduled {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// spread returns a time within the interval after now for the record with the given z-base-32 encoded ID, which is
+// stable for the ID so that records scheduled together are spread evenly across the interval
+func (r *republisher) spread(id string, now time.Time) time.Time {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(id))
+	return now.Add(time.Duration(h.Sum64() % uint64(r.interval)))
+}
+
+// enqueue adds the record to the queue or moves it to its new due time, and must be called with the lock held
+func (r *republisher) enqueue(id string, due time.Time) {
+	if item, ok := r.scheduled[id]; ok {
+		item.due = due
+		heap.Fix(&r.queue, item.index)
+		return
+	}
+	item := &scheduledRepublish{id: id, due: due}
+	heap.Push(&r.queue, item)
+	r.scheduled[id] = item
+}
+
+func (r *republisher) nextDue() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.queue) == 0 {
+		return time.Time{}
+	}
+	return r.queue[0].due
+}
+
+// dispatch hands records to the workers as they become due, blocking while all workers are busy. The records which
+// become due before it next waits are recorded as a batch.
+func (r *republisher) dispatch(ctx context.Context, jobs chan<- *scheduledRepublish) {
+	var batch int
+	for {
+		r.mu.Lock()
+		wait := r.interval
+		if len(r.queue) > 0 {
+			wait = time.Until(r.queue[0].due)
+		}
+		if len(r.queue) > 0 && wait <= 0 {
+			item := heap.Pop(&r.queue).(*scheduledRepublish)
+			delete(r.scheduled, item.id)
+			r.mu.Unlock()
+
+			select {
+			case jobs <- item:
+				batch++
+			case <-ctx.Done():
+				return
+			}
+			continue
+		}
+		r.mu.Unlock()
+		if batch > 0 {
+			telemetry.RecordRepublishBatch(ctx, batch)
+			batch = 0
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-r.wake:
+			timer.Stop()
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// republish puts the stored record back into the DHT and schedules its next republish, adding failures to the retry
+// queue
+func (r *republisher) republish(ctx context.Context, item *scheduledRepublish) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "DHTService.republish")
+	defer span.End()
+
+	id := item.id
+	record, err := r.db.ReadRecord(ctx, id)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).WithField("record_id", id).Warn("failed to read record for republishing")
+		telemetry.RecordRepublish(ctx, telemetry.RepublishFailure)
+		r.reschedule(ctx, id, time.Now().Add(republishRetryDelay))
+		return
+	}
+	// deleted records and deactivated DIDs are left to expire from the DHT
+	if record == nil || isDeactivated(id, *record) {
+		telemetry.RecordRepublish(ctx, telemetry.RepublishSkipped)
+		if err = r.unschedule(ctx, id); err != nil {
+			logrus.WithContext(ctx).WithError(err).WithField("record_id", id).Warn("failed to unschedule record")
+		}
+		return
+	}
+
+	putCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	now := time.Now()
+	if _, err = r.dht.Put(putCtx, record.Put()); err != nil {
+		if ctx.Err() != nil {
+			// shutting down, the persisted schedule is picked up on restart
+			return
+		}
+		telemetry.RecordRepublish(ctx, telemetry.RepublishFailure)
+		if _, err = r.retrier.recordFailure(ctx, id, err); err != nil {
+			logrus.WithContext(ctx).WithError(err).WithField("record_id", id).Warn("failed to write failed record")
+		}
+	} else {
+		logrus.WithContext(ctx).WithField("record_id", id).Debug("republished record")
+		telemetry.RecordRepublish(ctx, telemetry.RepublishSuccess)
+		if err = r.retrier.clear(ctx, id); err != nil {
+			logrus.WithContext(ctx).WithError(err).WithField("record_id", id).Warn("failed to clear failed record")
+		}
+	}
+
+	// keep the record's place in the interval, unless it has fallen behind, e.g. after downtime
+	next := item.due.Add(r.interval)
+	if !next.After(now) {
+		next = r.spread(id, now)
+	}
+	r.reschedule(ctx, id, next)
+}
+
+func (r *republisher) reschedule(ctx context.Context, id string, due time.Time) {
+	if err := r.schedule(ctx, id, due); err != nil {
+		logrus.WithContext(ctx).WithError(err).WithField("record_id", id).Error("failed to schedule record for republishing")
+	}
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"container/heap"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/TBD54566975/did-dht/config"
+	"github.com/TBD54566975/did-dht/pkg/dht"
+	"github.com/TBD54566975/did-dht/pkg/storage"
+)
+
+// afterListStorage is a storage.Storage which calls a function after records are first listed
+type afterListStorage struct {
+	storage.Storage
+	after func()
+}
+
+func (s *afterListStorage) ListRecords(ctx context.Context, nextPageToken []byte, pageSize int) ([]dht.BEP44Record, []byte, error) {
+	records, next, err := s.Storage.ListRecords(ctx, nextPageToken, pageSize)
+	if s.after != nil {
+		s.after()
+		s.after = nil
+	}
+	return records, next, err
+}
+
+func TestRepublishQueue(t *testing.T) {
+	now := time.Now()
+	var q republishQueue
+	heap.Push(&q, &scheduledRepublish{id: "third", due: now.Add(3 * time.Minute)})
+	heap.Push(&q, &scheduledRepublish{id: "first", due: now.Add(time.Minute)})
+	heap.Push(&q, &scheduledRepublish{id: "second", due: now.Add(2 * time.Minute)})
+
+	var order []string
+	for q.Len() > 0 {
+		order = append(order, heap.Pop(&q).(*scheduledRepublish).id)
+	}
+	assert.Equal(t, []string{"first", "second", "third"}, order)
+}
+
+func TestRepublisher(t *testing.T) {
+	t.Run("test spread within interval", func(t *testing.T) {
+		r := newRepublisher(config.DHTServiceConfig{RepublishIntervalMinutes: 60}, nil, nil, nil, nil)
+		assert.Equal(t, time.Hour, r.interval)
+		assert.Equal(t, 1, r.workers)
+
+		now := time.Now()
+		for range 100 {
+			id := newTestRecord(t).ID()
+			due := r.spread(id, now)
+			assert.False(t, due.Before(now))
+			assert.True(t, due.Before(now.Add(r.interval)))
+			assert.Equal(t, due, r.spread(id, now))
+		}
+	})
+
+	t.Run("test workers from config", func(t *testing.T) {
+		r := newRepublisher(config.DHTServiceConfig{RepublishConcurrency: 4}, nil, nil, nil, nil)
+		assert.Equal(t, 4, r.workers)
+		assert.Equal(t, time.Duration(config.GetDefaultConfig().DHTConfig.RepublishIntervalMinutes)*time.Minute, r.interval)
+	})
+}
+
+func TestRepublishSchedule(t *testing.T) {
+	svc := newDHTService(t, "republish-schedule")
+	ctx := context.Background()
+
+	t.Run("test publish schedules record", func(t *testing.T) {
+		record := newTestRecord(t)
+		id := record.ID()
+		before := time.Now()
+		require.NoError(t, svc.PublishDHT(ctx, id, record))
+		assert.True(t, svc.republisher.isScheduled(id))
+
+		scheduled, err := svc.db.ListRepublishSchedule(ctx)
+		require.NoError(t, err)
+		require.Len(t, scheduled, 1)
+		assert.Equal(t, id, scheduled[0].ID)
+		assert.GreaterOrEqual(t, scheduled[0].Due, before.Add(svc.republisher.interval).Unix())
+	})
+
+	t.Run("test due record is republished and rescheduled", func(t *testing.T) {
+		record := newTestRecord(t)
+		id := record.ID()
+		require.NoError(t, svc.db.WriteRecord(ctx, record))
+		due := time.Now().Add(-time.Second)
+		require.NoError(t, svc.republisher.schedule(ctx, id, due))
+
+		assert.Eventually(t, func() bool {
+			scheduled, err := svc.db.ListRepublishSchedule(ctx)
+			require.NoError(t, err)
+			for _, s := range scheduled {
+				if s.ID == id {
+					return s.Due > due.Unix()
+				}
+			}
+			return false
+		}, 15*time.Second, 100*time.Millisecond)
+		assert.True(t, svc.republisher.isScheduled(id))
+	})
+
+	t.Run("test reconcile schedules and unschedules records", func(t *testing.T) {
+		unscheduled := newTestRecord(t)
+		require.NoError(t, svc.db.WriteRecord(ctx, unscheduled))
+		assert.False(t, svc.republisher.isScheduled(unscheduled.ID()))
+
+		// a record which is no longer stored
+		require.NoError(t, svc.republisher.schedule(ctx, "deleted", time.Now().Add(time.Hour)))
+
+		svc.republish()
+		assert.True(t, svc.republisher.isScheduled(unscheduled.ID()))
+		assert.False(t, svc.republisher.isScheduled("deleted"))
+
+		scheduled, err := svc.db.ListRepublishSchedule(ctx)
+		require.NoError(t, err)
+		var ids []string
+		for _, s := range scheduled {
+			ids = append(ids, s.ID)
+		}
+		assert.Contains(t, ids, unscheduled.ID())
+		assert.NotContains(t, ids, "deleted")
+	})
+
+	t.Run("test reconcile keeps records published during the scan", func(t *testing.T) {
+		record := newTestRecord(t)
+		db := svc.db
+		svc.db = &afterListStorage{Storage: db, after: func() {
+			require.NoError(t, svc.PublishDHT(ctx, record.ID(), record))
+		}}
+		defer func() { svc.db = db }()
+
+		// the record is published after the scan has listed it, so the scan does not see it
+		svc.republish()
+		assert.True(t, svc.republisher.isScheduled(record.ID()))
+	})
+
+	t.Cleanup(func() { svc.Close() })
+}
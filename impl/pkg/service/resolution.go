@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+
+	"github.com/TBD54566975/did-dht/internal/did"
+	"github.com/TBD54566975/did-dht/pkg/dht"
+	"github.com/TBD54566975/did-dht/pkg/telemetry"
+)
+
+// ResolutionSource returns a source for resolving DIDs from the gateway, at the given sequence number if it is not
+// nil and at their latest sequence number otherwise
+func (s *DHTService) ResolutionSource(seq *int64) did.ResolutionSource {
+	return resolutionSource{service: s, seq: seq}
+}
+
+type resolutionSource struct {
+	service *DHTService
+	seq     *int64
+}
+
+// GetResolutionRecord returns the record for the given DID along with the gateway's metadata for it, or nil if the
+// DID is not found. ErrBadKeyRateLimited is returned if the DID was recently not found in the DHT.
+func (r resolutionSource) GetResolutionRecord(ctx context.Context, id did.DHT) (*did.ResolutionRecord, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "DHTService.GetResolutionRecord")
+	defer span.End()
+
+	suffix, err := id.Suffix()
+	if err != nil {
+		return nil, err
+	}
+
+	var resp *dht.BEP44Response
+	if r.seq != nil {
+		resp, err = r.service.GetDHTAtSequence(ctx, suffix, *r.seq)
+	} else {
+		resp, err = r.service.GetDHT(ctx, suffix)
+	}
+	if err != nil || resp == nil {
+		return nil, err
+	}
+
+	seqs, err := r.service.ListSequenceNumbers(ctx, suffix)
+	if err != nil {
+		return nil, err
+	}
+	expiry, err := r.service.GetRetentionExpiry(ctx, suffix)
+	if err != nil {
+		return nil, err
+	}
+
+	return &did.ResolutionRecord{
+		V:               resp.V,
+		Seq:             resp.Seq,
+		SequenceNumbers: seqs,
+		Expiry:          expiry,
+		Gateway:         r.service.cfg.ServerConfig.BaseURL,
+	}, nil
+}
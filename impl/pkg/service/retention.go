@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	ssiutil "github.com/TBD54566975/ssi-sdk/util"
+	"github.com/allegro/bigcache/v3"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/TBD54566975/did-dht/internal/did"
+	"github.com/TBD54566975/did-dht/pkg/dht"
+	"github.com/TBD54566975/did-dht/pkg/telemetry"
+)
+
+var (
+	// ErrInvalidRetentionSolution is returned when a retention solution does not satisfy the current challenge
+	ErrInvalidRetentionSolution = errors.New("invalid retention solution")
+	// ErrRetentionDisabled is returned for retention requests when the Retained DID Set is disabled
+	ErrRetentionDisabled = errors.New("retention not supported by this gateway")
+	// ErrRetentionUnavailable is returned for retention requests when the gateway is at capacity
+	ErrRetentionUnavailable = errors.New("retention temporarily disabled")
+	// ErrChallengeUnavailable is returned when no challenge hash can be issued, e.g. when the hash source is unreachable
+	ErrChallengeUnavailable = errors.New("retention challenge unavailable")
+)
+
+// Challenge is a retention challenge https://did-dht.com/#get-the-current-challenge
+type Challenge struct {
+	// Hash is the hash retention solutions must be computed against
+	Hash string
+	// HashSource is the source of the hash
+	HashSource string
+	// Difficulty is the number of leading zero bits a retention solution must have
+	Difficulty int
+	// Expiry is the unix timestamp in seconds at which the challenge expires
+	Expiry int64
+}
+
+// GetChallenge returns the current retention challenge
+func (s *DHTService) GetChallenge(ctx context.Context) (*Challenge, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "DHTService.GetChallenge")
+	defer span.End()
+
+	if !s.cfg.RetentionConfig.Enabled || s.challenges == nil {
+		return nil, ErrRetentionDisabled
+	}
+
+	difficulty, err := s.difficulty.Difficulty(ctx)
+	if err != nil {
+		return nil, err
+	}
+	hash, expiry, err := s.challenges.Current(ctx)
+	if err != nil {
+		return nil, errors.Wrap(ErrChallengeUnavailable, err.Error())
+	}
+	return &Challenge{
+		Hash:       hash,
+		HashSource: s.challenges.Source(),
+		Difficulty: difficulty,
+		Expiry:     expiry.Unix(),
+	}, nil
+}
+
+// PublishDID publishes the record for the given DID and, if a retention solution is provided and valid, adds the DID
+// to the Retained DID Set. Returns the unix timestamp in seconds at which the DID will be evicted from the set, or
+// zero if the DID is not retained. https://did-dht.com/#register-or-update-a-did
+func (s *DHTService) PublishDID(ctx context.Context, didID did.DHT, record dht.BEP44Record, retentionSolution string) (int64, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "DHTService.PublishDID")
+	defer span.End()
+
+	id, err := didID.Suffix()
+	if err != nil {
+		return 0, err
+	}
+
+	if retentionSolution != "" {
+		if !s.cfg.RetentionConfig.Enabled || s.challenges == nil {
+			return 0, ErrRetentionDisabled
+		}
+		difficulty, err := s.difficulty.AcceptedDifficulty(ctx)
+		if err != nil {
+			return 0, err
+		}
+		// solutions are computed for the did:dht identifier, including for interoperable DIDs which share its key
+		if !s.challenges.ValidateSolution(did.Prefix+":"+id, retentionSolution, difficulty) {
+			return 0, ErrInvalidRetentionSolution
+		}
+	}
+
+	if err = s.PublishDHT(ctx, id, record); err != nil {
+		return 0, err
+	}
+
+	if retentionSolution == "" {
+		return 0, nil
+	}
+	// fresh solutions extend the expiry, but never shorten it
+	expiry := time.Now().Add(time.Duration(s.cfg.RetentionConfig.ExpiryDays) * 24 * time.Hour).Unix()
+	retained, err := s.db.ReadRetainedRecord(ctx, id)
+	if err != nil {
+		return 0, ssiutil.LoggingCtxErrorMsgf(ctx, err, "failed to read retained record: %s", id)
+	}
+	if retained != nil && retained.Expiry > expiry {
+		expiry = retained.Expiry
+	}
+	if err = s.db.WriteRetainedRecord(ctx, dht.RetainedRecord{ID: id, Expiry: expiry}); err != nil {
+		return 0, ssiutil.LoggingCtxErrorMsgf(ctx, err, "failed to write retained record: %s", id)
+	}
+	logrus.WithContext(ctx).WithField("record_id", id).WithField("expiry", expiry).Debug("added record to the retained did set")
+	return expiry, nil
+}
+
+// GetRetentionExpiry returns the unix timestamp in seconds at which the given z-base-32 encoded ID will be evicted
+// from the Retained DID Set, or zero if it is not in the set or has already expired
+func (s *DHTService) GetRetentionExpiry(ctx context.Context, id string) (int64, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "DHTService.GetRetentionExpiry")
+	defer span.End()
+
+	retained, err := s.db.ReadRetainedRecord(ctx, id)
+	if err != nil {
+		return 0, ssiutil.LoggingCtxErrorMsgf(ctx, err, "failed to read retained record: %s", id)
+	}
+	if retained == nil || retained.Expiry <= time.Now().Unix() {
+		return 0, nil
+	}
+	return retained.Expiry, nil
+}
+
+// retentionFilter returns a function reporting whether the record with the given z-base-32 encoded ID should be
+// republished according to the Retained DID Set. Expired records are purged from storage if configured to do so, and
+// otherwise are treated as records which were never retained.
+func (s *DHTService) retentionFilter(ctx context.Context) (func(id string) bool, error) {
+	if !s.cfg.RetentionConfig.Enabled {
+		return func(string) bool { return true }, nil
+	}
+
+	retainedRecords, err := s.db.ListRetainedRecords(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	retained := make(map[string]bool, len(retainedRecords))
+	var expiredCnt int
+	for _, record := range retainedRecords {
+		if record.Expiry > now {
+			retained[record.ID] = true
+			continue
+		}
+
+		expiredCnt++
+		if !s.cfg.RetentionConfig.PurgeExpired {
+			continue
+		}
+		retained[record.ID] = false
+		if err = s.purgeRecord(ctx, record.ID); err != nil {
+			logrus.WithContext(ctx).WithError(err).WithField("record_id", record.ID).Warn("failed to purge expired record")
+		}
+	}
+	logrus.WithContext(ctx).WithFields(logrus.Fields{
+		"retained_count": len(retainedRecords) - expiredCnt,
+		"expired_count":  expiredCnt,
+	}).Info("evaluated retained did set for republishing")
+
+	return func(id string) bool {
+		if isRetained, ok := retained[id]; ok {
+			return isRetained
+		}
+		return s.cfg.RetentionConfig.RepublishUnretained
+	}, nil
+}
+
+// purgeRecord removes the record with the given z-base-32 encoded ID from storage, the cache, the Retained DID Set, and
+// the republish and retry queues
+func (s *DHTService) purgeRecord(ctx context.Context, id string) error {
+	if err := s.db.DeleteRecord(ctx, id); err != nil {
+		return err
+	}
+	if err := s.cache.Delete(id); err != nil && !errors.Is(err, bigcache.ErrEntryNotFound) {
+		return err
+	}
+	if err := s.db.DeleteRetainedRecord(ctx, id); err != nil {
+		return err
+	}
+	if err := s.republisher.unschedule(ctx, id); err != nil {
+		return err
+	}
+	if err := s.db.DeleteFailedRecord(ctx, id); err != nil {
+		return err
+	}
+	logrus.WithContext(ctx).WithField("record_id", id).Debug("purged record")
+	return nil
+}
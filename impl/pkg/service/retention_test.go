@@ -0,0 +1,227 @@
+package service
+
+import (
+	"context"
+	"crypto/ed25519"
+	"testing"
+	"time"
+
+	"github.com/anacrolix/dht/v2/bep44"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/TBD54566975/did-dht/internal/did"
+	"github.com/TBD54566975/did-dht/pkg/dht"
+)
+
+func TestPublishDID(t *testing.T) {
+	svc := newDHTService(t, "retention")
+
+	// lower the difficulty so solutions can be found quickly
+	svc.cfg.RetentionConfig.Difficulty = 4
+
+	newRecord := func(t *testing.T) (ed25519.PrivateKey, did.DHT, *bep44.Put) {
+		sk, doc, err := did.GenerateDIDDHT(did.CreateDIDDHTOpts{})
+		require.NoError(t, err)
+
+		d := did.DHT(doc.ID)
+		packet, err := d.ToDNSPacket(*doc, nil, nil, nil)
+		require.NoError(t, err)
+
+		putMsg, err := dht.CreateDNSPublishRequest(sk, *packet)
+		require.NoError(t, err)
+		return sk, d, putMsg
+	}
+
+	solve := func(t *testing.T, d did.DHT) string {
+		challenge, err := svc.GetChallenge(context.Background())
+		require.NoError(t, err)
+		return did.GenerateRetentionSolution(d.String(), challenge.Hash, challenge.Difficulty)
+	}
+
+	t.Run("test publish without a retention solution", func(t *testing.T) {
+		_, d, putMsg := newRecord(t)
+		record := dht.RecordFromBEP44(putMsg)
+
+		expiry, err := svc.PublishDID(context.Background(), d, record, "")
+		assert.NoError(t, err)
+		assert.Zero(t, expiry)
+
+		suffix, err := d.Suffix()
+		require.NoError(t, err)
+		retained, err := svc.db.ReadRetainedRecord(context.Background(), suffix)
+		assert.NoError(t, err)
+		assert.Nil(t, retained)
+	})
+
+	t.Run("test publish with a valid retention solution", func(t *testing.T) {
+		_, d, putMsg := newRecord(t)
+		record := dht.RecordFromBEP44(putMsg)
+
+		solution := solve(t, d)
+		expiry, err := svc.PublishDID(context.Background(), d, record, solution)
+		assert.NoError(t, err)
+		assert.Greater(t, expiry, time.Now().Unix())
+
+		suffix, err := d.Suffix()
+		require.NoError(t, err)
+		retained, err := svc.db.ReadRetainedRecord(context.Background(), suffix)
+		assert.NoError(t, err)
+		require.NotNil(t, retained)
+		assert.Equal(t, expiry, retained.Expiry)
+
+		gotExpiry, err := svc.GetRetentionExpiry(context.Background(), suffix)
+		assert.NoError(t, err)
+		assert.Equal(t, expiry, gotExpiry)
+	})
+
+	t.Run("test fresh retention solution extends expiry", func(t *testing.T) {
+		_, d, putMsg := newRecord(t)
+		record := dht.RecordFromBEP44(putMsg)
+
+		suffix, err := d.Suffix()
+		require.NoError(t, err)
+
+		// an expiry about to lapse is extended
+		soon := time.Now().Add(time.Minute).Unix()
+		require.NoError(t, svc.db.WriteRetainedRecord(context.Background(), dht.RetainedRecord{ID: suffix, Expiry: soon}))
+		solution := solve(t, d)
+		expiry, err := svc.PublishDID(context.Background(), d, record, solution)
+		assert.NoError(t, err)
+		assert.Greater(t, expiry, soon)
+
+		// a later expiry is never shortened
+		later := time.Now().Add(365 * 24 * time.Hour).Unix()
+		require.NoError(t, svc.db.WriteRetainedRecord(context.Background(), dht.RetainedRecord{ID: suffix, Expiry: later}))
+		expiry, err = svc.PublishDID(context.Background(), d, record, solution)
+		assert.NoError(t, err)
+		assert.Equal(t, later, expiry)
+	})
+
+	t.Run("test publish with an invalid retention solution", func(t *testing.T) {
+		_, d, putMsg := newRecord(t)
+		record := dht.RecordFromBEP44(putMsg)
+
+		_, err := svc.PublishDID(context.Background(), d, record, "bad:solution")
+		assert.ErrorIs(t, err, ErrInvalidRetentionSolution)
+	})
+
+	t.Run("test publish with retention disabled", func(t *testing.T) {
+		_, d, putMsg := newRecord(t)
+		record := dht.RecordFromBEP44(putMsg)
+
+		solution := solve(t, d)
+
+		svc.cfg.RetentionConfig.Enabled = false
+		defer func() { svc.cfg.RetentionConfig.Enabled = true }()
+
+		_, err := svc.PublishDID(context.Background(), d, record, solution)
+		assert.ErrorIs(t, err, ErrRetentionDisabled)
+	})
+
+	t.Run("test publish with retention temporarily disabled", func(t *testing.T) {
+		_, d, putMsg := newRecord(t)
+		record := dht.RecordFromBEP44(putMsg)
+
+		solution := solve(t, d)
+
+		// earlier writes in this window put the gateway over capacity
+		svc.cfg.RetentionConfig.WriteRateCapacity = 1
+		defer func() { svc.cfg.RetentionConfig.WriteRateCapacity = 0 }()
+
+		_, err := svc.GetChallenge(context.Background())
+		assert.ErrorIs(t, err, ErrRetentionUnavailable)
+
+		_, err = svc.PublishDID(context.Background(), d, record, solution)
+		assert.ErrorIs(t, err, ErrRetentionUnavailable)
+	})
+
+	t.Run("test publish with a lower sequence number", func(t *testing.T) {
+		sk, d, putMsg := newRecord(t)
+
+		_, err := svc.PublishDID(context.Background(), d, dht.RecordFromBEP44(putMsg), "")
+		require.NoError(t, err)
+
+		// re-sign the record with a lower sequence number
+		putMsg.Seq--
+		putMsg.Sign(sk)
+		_, err = svc.PublishDID(context.Background(), d, dht.RecordFromBEP44(putMsg), "")
+		assert.ErrorIs(t, err, ErrSequenceNumberTooLow)
+	})
+
+	t.Cleanup(func() { svc.Close() })
+}
+
+func TestRetentionFilter(t *testing.T) {
+	svc := newDHTService(t, "retention-filter")
+
+	ctx := context.Background()
+	now := time.Now()
+
+	// "unretained" has never been added to the set
+	require.NoError(t, svc.db.WriteRetainedRecord(ctx, dht.RetainedRecord{ID: "active", Expiry: now.Add(time.Hour).Unix()}))
+	require.NoError(t, svc.db.WriteRetainedRecord(ctx, dht.RetainedRecord{ID: "expired", Expiry: now.Add(-time.Hour).Unix()}))
+
+	t.Run("test filter without purging", func(t *testing.T) {
+		shouldRepublish, err := svc.retentionFilter(ctx)
+		require.NoError(t, err)
+		assert.True(t, shouldRepublish("active"))
+		assert.True(t, shouldRepublish("unretained"))
+		// an expired DID is no worse off than one which was never retained
+		assert.True(t, shouldRepublish("expired"))
+
+		svc.cfg.RetentionConfig.RepublishUnretained = false
+		defer func() { svc.cfg.RetentionConfig.RepublishUnretained = true }()
+
+		shouldRepublish, err = svc.retentionFilter(ctx)
+		require.NoError(t, err)
+		assert.True(t, shouldRepublish("active"))
+		assert.False(t, shouldRepublish("unretained"))
+		assert.False(t, shouldRepublish("expired"))
+	})
+
+	t.Run("test filter with retention disabled", func(t *testing.T) {
+		svc.cfg.RetentionConfig.Enabled = false
+		defer func() { svc.cfg.RetentionConfig.Enabled = true }()
+
+		shouldRepublish, err := svc.retentionFilter(ctx)
+		require.NoError(t, err)
+		assert.True(t, shouldRepublish("expired"))
+	})
+
+	t.Run("test filter with purging", func(t *testing.T) {
+		record := newTestRecord(t)
+		id := record.ID()
+		require.NoError(t, svc.db.WriteRecord(ctx, record))
+		require.NoError(t, svc.db.WriteRetainedRecord(ctx, dht.RetainedRecord{ID: id, Expiry: now.Add(-time.Hour).Unix()}))
+
+		svc.cfg.RetentionConfig.PurgeExpired = true
+		defer func() { svc.cfg.RetentionConfig.PurgeExpired = false }()
+
+		shouldRepublish, err := svc.retentionFilter(ctx)
+		require.NoError(t, err)
+		assert.False(t, shouldRepublish(id))
+
+		got, err := svc.db.ReadRecord(ctx, id)
+		assert.NoError(t, err)
+		assert.Nil(t, got)
+
+		retained, err := svc.db.ReadRetainedRecord(ctx, id)
+		assert.NoError(t, err)
+		assert.Nil(t, retained)
+	})
+
+	t.Cleanup(func() { svc.Close() })
+}
+
+func newTestRecord(t *testing.T) dht.BEP44Record {
+	sk, doc, err := did.GenerateDIDDHT(did.CreateDIDDHTOpts{})
+	require.NoError(t, err)
+
+	packet, err := did.DHT(doc.ID).ToDNSPacket(*doc, nil, nil, nil)
+	require.NoError(t, err)
+
+	putMsg, err := dht.CreateDNSPublishRequest(sk, *packet)
+	require.NoError(t, err)
+	return dht.RecordFromBEP44(putMsg)
+}
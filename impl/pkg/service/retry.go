@@ -0,0 +1,222 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/TBD54566975/did-dht/config"
+	"github.com/TBD54566975/did-dht/pkg/dht"
+	"github.com/TBD54566975/did-dht/pkg/storage"
+	"github.com/TBD54566975/did-dht/pkg/telemetry"
+)
+
+// retrier retries records which failed to be put into the DHT with exponential backoff. Failures are persisted so that
+// retries survive restarts, and a record which keeps failing is dead-lettered: it is no longer retried, but kept for
+// inspection until it is next put successfully.
+type retrier struct {
+	db                 storage.Storage
+	dht                *dht.DHT
+	baseDelay          time.Duration
+	maxDelay           time.Duration
+	deadLetterAttempts int
+
+	// mu serializes updates of failed records, which may fail concurrently from publishing and republishing
+	mu   sync.Mutex
+	wake chan struct{}
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newRetrier(cfg config.DHTServiceConfig, db storage.Storage, d *dht.DHT) *retrier {
+	defaultConfig := config.GetDefaultConfig().DHTConfig
+	baseDelay := time.Duration(cfg.RetryBaseDelaySeconds) * time.Second
+	if baseDelay <= 0 {
+		baseDelay = time.Duration(defaultConfig.RetryBaseDelaySeconds) * time.Second
+	}
+	maxDelay := time.Duration(cfg.RetryMaxDelayMinutes) * time.Minute
+	if maxDelay <= 0 {
+		maxDelay = time.Duration(defaultConfig.RetryMaxDelayMinutes) * time.Minute
+	}
+	return &retrier{
+		db:                 db,
+		dht:                d,
+		baseDelay:          baseDelay,
+		maxDelay:           max(baseDelay, maxDelay),
+		deadLetterAttempts: cfg.RetryDeadLetterAttempts,
+		wake:               make(chan struct{}, 1),
+	}
+}
+
+// start retries failed records as they become due
+func (r *retrier) start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		for {
+			timer := time.NewTimer(r.retryDue(ctx))
+			select {
+			case <-timer.C:
+			case <-r.wake:
+				timer.Stop()
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// stop stops retrying, waiting for an in-flight put to finish
+func (r *retrier) stop() {
+	if r == nil || r.cancel == nil {
+		return
+	}
+	r.cancel()
+	r.wg.Wait()
+}
+
+// backoff returns the delay before the next attempt after the given number of consecutive failures
+func (r *retrier) backoff(attempts int) time.Duration {
+	if attempts < 1 {
+		return r.baseDelay
+	}
+	if attempts > 32 {
+		return r.maxDelay
+	}
+	delay := r.baseDelay << (attempts - 1)
+	if delay <= 0 || delay > r.maxDelay {
+		return r.maxDelay
+	}
+	return delay
+}
+
+// recordFailure adds a failed put of the record with the given z-base-32 encoded ID to the retry queue, returning the
+// updated entry
+func (r *retrier) recordFailure(ctx context.Context, id string, putErr error) (*dht.FailedRecord, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	failed, err := r.db.ReadFailedRecord(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if failed == nil {
+		failed = &dht.FailedRecord{ID: id}
+	}
+	now := time.Now()
+	if failed.FirstFailure == 0 {
+		failed.FirstFailure = now.Unix()
+	}
+	failed.Count++
+	failed.LastError = putErr.Error()
+	failed.LastFailure = now.Unix()
+	failed.NextAttempt = now.Add(r.backoff(failed.Count)).Unix()
+	failed.DeadLettered = r.deadLetterAttempts > 0 && failed.Count >= r.deadLetterAttempts
+	if err = r.db.WriteFailedRecord(ctx, *failed); err != nil {
+		return nil, err
+	}
+
+	fields := logrus.Fields{"record_id": id, "attempts": failed.Count}
+	if failed.DeadLettered {
+		logrus.WithContext(ctx).WithError(putErr).WithFields(fields).Error("dead-lettered record which repeatedly failed to be put")
+		return failed, nil
+	}
+	logrus.WithContext(ctx).WithError(putErr).WithFields(fields).Debugf("record failed to be put, retrying at %s", time.Unix(failed.NextAttempt, 0))
+
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+	return failed, nil
+}
+
+// clear removes the record with the given z-base-32 encoded ID from the retry queue once it has been put
+func (r *retrier) clear(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// most records have never failed, so avoid a write unless there is something to clear
+	failed, err := r.db.ReadFailedRecord(ctx, id)
+	if err != nil || failed == nil {
+		return err
+	}
+	if err = r.db.DeleteFailedRecord(ctx, id); err != nil {
+		return err
+	}
+	logrus.WithContext(ctx).WithFields(logrus.Fields{"record_id": id, "attempts": failed.Count}).Info("record put after previous failures")
+	return nil
+}
+
+// retryDue retries each failed record whose next attempt is due, returning the time until the next attempt
+func (r *retrier) retryDue(ctx context.Context) time.Duration {
+	ctx, span := telemetry.GetTracer().Start(ctx, "DHTService.retryFailedRecords")
+	defer span.End()
+
+	wait := r.maxDelay
+	failedRecords, err := r.db.ListFailedRecords(ctx)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).Error("failed to list failed records")
+		return r.baseDelay
+	}
+
+	now := time.Now()
+	for _, failed := range failedRecords {
+		if failed.DeadLettered {
+			continue
+		}
+		if failed.NextAttempt > now.Unix() {
+			wait = min(wait, time.Unix(failed.NextAttempt, 0).Sub(now))
+			continue
+		}
+		if ctx.Err() != nil {
+			return wait
+		}
+		if next := r.retry(ctx, failed.ID); next != nil && !next.DeadLettered {
+			wait = min(wait, time.Until(time.Unix(next.NextAttempt, 0)))
+		}
+	}
+	return max(wait, time.Second)
+}
+
+// retry puts the stored record back into the DHT, returning its updated retry queue entry if it failed again
+func (r *retrier) retry(ctx context.Context, id string) *dht.FailedRecord {
+	record, err := r.db.ReadRecord(ctx, id)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).WithField("record_id", id).Warn("failed to read record for retrying")
+		return nil
+	}
+	// deleted records are no longer put
+	if record == nil {
+		if err = r.clear(ctx, id); err != nil {
+			logrus.WithContext(ctx).WithError(err).WithField("record_id", id).Warn("failed to clear failed record")
+		}
+		return nil
+	}
+
+	putCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if _, err = r.dht.Put(putCtx, record.Put()); err != nil {
+		if ctx.Err() != nil {
+			// shutting down, the record is retried on restart
+			return nil
+		}
+		failed, err := r.recordFailure(ctx, id, err)
+		if err != nil {
+			logrus.WithContext(ctx).WithError(err).WithField("record_id", id).Warn("failed to write failed record")
+			return nil
+		}
+		return failed
+	}
+
+	if err = r.clear(ctx, id); err != nil {
+		logrus.WithContext(ctx).WithError(err).WithField("record_id", id).Warn("failed to clear failed record")
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/TBD54566975/did-dht/config"
+	"github.com/TBD54566975/did-dht/pkg/dht"
+)
+
+func TestRetrierBackoff(t *testing.T) {
+	r := newRetrier(config.DHTServiceConfig{RetryBaseDelaySeconds: 60, RetryMaxDelayMinutes: 60}, nil, nil)
+	assert.Equal(t, time.Minute, r.backoff(1))
+	assert.Equal(t, 2*time.Minute, r.backoff(2))
+	assert.Equal(t, 32*time.Minute, r.backoff(6))
+	assert.Equal(t, time.Hour, r.backoff(7))
+	assert.Equal(t, time.Hour, r.backoff(100))
+
+	// defaults apply to unset delays
+	r = newRetrier(config.DHTServiceConfig{}, nil, nil)
+	assert.Equal(t, time.Minute, r.baseDelay)
+	assert.Equal(t, time.Hour, r.maxDelay)
+}
+
+func TestRetrier(t *testing.T) {
+	svc := newDHTService(t, "retrier")
+	ctx := context.Background()
+
+	r := newRetrier(config.DHTServiceConfig{RetryDeadLetterAttempts: 3}, svc.db, svc.dht)
+
+	t.Run("test failures are recorded with backoff until dead-lettered", func(t *testing.T) {
+		id := newTestRecord(t).ID()
+		before := time.Now().Unix()
+
+		failed, err := r.recordFailure(ctx, id, errors.New("first failure"))
+		require.NoError(t, err)
+		assert.Equal(t, 1, failed.Count)
+		assert.Equal(t, "first failure", failed.LastError)
+		assert.GreaterOrEqual(t, failed.FirstFailure, before)
+		assert.Equal(t, failed.FirstFailure, failed.LastFailure)
+		assert.Equal(t, failed.LastFailure+int64(r.backoff(1).Seconds()), failed.NextAttempt)
+		assert.False(t, failed.DeadLettered)
+		firstFailure := failed.FirstFailure
+
+		failed, err = r.recordFailure(ctx, id, errors.New("second failure"))
+		require.NoError(t, err)
+		assert.Equal(t, 2, failed.Count)
+		assert.Equal(t, "second failure", failed.LastError)
+		assert.Equal(t, firstFailure, failed.FirstFailure)
+		assert.Equal(t, failed.LastFailure+int64(r.backoff(2).Seconds()), failed.NextAttempt)
+		assert.False(t, failed.DeadLettered)
+
+		_, err = r.recordFailure(ctx, id, errors.New("third failure"))
+		require.NoError(t, err)
+		stored, err := svc.db.ReadFailedRecord(ctx, id)
+		require.NoError(t, err)
+		require.NotNil(t, stored)
+		assert.Equal(t, 3, stored.Count)
+		assert.True(t, stored.DeadLettered)
+
+		// success clears the record, even once dead-lettered
+		require.NoError(t, r.clear(ctx, id))
+		stored, err = svc.db.ReadFailedRecord(ctx, id)
+		assert.NoError(t, err)
+		assert.Nil(t, stored)
+
+		// clearing a record which never failed is a no-op
+		assert.NoError(t, r.clear(ctx, id))
+	})
+
+	t.Run("test dead-lettered and pending records are not retried", func(t *testing.T) {
+		now := time.Now()
+		deadLettered := dht.FailedRecord{ID: "dead-lettered", Count: 3, NextAttempt: now.Add(-time.Minute).Unix(), DeadLettered: true}
+		pending := dht.FailedRecord{ID: "pending", Count: 1, NextAttempt: now.Add(time.Minute).Unix()}
+		require.NoError(t, svc.db.WriteFailedRecord(ctx, deadLettered))
+		require.NoError(t, svc.db.WriteFailedRecord(ctx, pending))
+
+		wait := r.retryDue(ctx)
+		assert.LessOrEqual(t, wait, time.Minute)
+		assert.Greater(t, wait, 50*time.Second)
+
+		failedRecords, err := svc.db.ListFailedRecords(ctx)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []dht.FailedRecord{deadLettered, pending}, failedRecords)
+
+		require.NoError(t, svc.db.DeleteFailedRecord(ctx, deadLettered.ID))
+		require.NoError(t, svc.db.DeleteFailedRecord(ctx, pending.ID))
+	})
+
+	t.Run("test due records which were deleted are cleared", func(t *testing.T) {
+		id := newTestRecord(t).ID()
+		require.NoError(t, svc.db.WriteFailedRecord(ctx, dht.FailedRecord{ID: id, Count: 1, NextAttempt: time.Now().Add(-time.Minute).Unix()}))
+
+		r.retryDue(ctx)
+		failed, err := svc.db.ReadFailedRecord(ctx, id)
+		assert.NoError(t, err)
+		assert.Nil(t, failed)
+	})
+
+	t.Cleanup(func() { svc.Close() })
+}
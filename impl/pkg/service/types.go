@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/TBD54566975/did-dht/internal/did"
+	"github.com/TBD54566975/did-dht/pkg/dht"
+	"github.com/TBD54566975/did-dht/pkg/telemetry"
+)
+
+// ListDIDsForType returns the DIDs indexed under the given type in order, skipping offset DIDs and returning at most
+// limit DIDs https://did-dht.com/#get-a-specific-type
+func (s *DHTService) ListDIDsForType(ctx context.Context, typeIndex did.TypeIndex, offset, limit int) ([]string, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "DHTService.ListDIDsForType")
+	defer span.End()
+
+	ids, err := s.db.ListDIDsForType(ctx, typeIndex, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	dids := make([]string, 0, len(ids))
+	for _, id := range ids {
+		dids = append(dids, did.Prefix+":"+id)
+	}
+	return dids, nil
+}
+
+// indexDIDTypes replaces the indexed types of the DID for the given z-base-32 encoded ID with the types in its
+// record. Records which are not DID DHT Documents are not indexed, and deactivated DIDs are removed from the index.
+func (s *DHTService) indexDIDTypes(ctx context.Context, id string, record dht.BEP44Record) error {
+	doc, err := didDocumentFromRecord(id, record)
+	if err != nil {
+		logrus.WithContext(ctx).WithError(err).WithField("record_id", id).Debug("record is not a did document, skipping type indexing")
+		return nil
+	}
+	if doc.Deactivated {
+		logrus.WithContext(ctx).WithField("record_id", id).Debug("did is deactivated, removing it from the type index")
+		return s.db.WriteDIDTypes(ctx, id, nil)
+	}
+
+	return s.db.WriteDIDTypes(ctx, id, doc.Types)
+}
+
+// isDeactivated returns true if the record for the given z-base-32 encoded ID is a deactivated DID
+func isDeactivated(id string, record dht.BEP44Record) bool {
+	doc, err := didDocumentFromRecord(id, record)
+	return err == nil && doc.Deactivated
+}
+
+// didDocumentFromRecord decodes the DID DHT Document for the given z-base-32 encoded ID from its record, returning
+// an error if the record is not a DID DHT Document
+func didDocumentFromRecord(id string, record dht.BEP44Record) (*did.DIDDHTDocument, error) {
+	msg := new(dns.Msg)
+	if err := msg.Unpack(record.Value); err != nil {
+		return nil, errors.Wrap(err, "record is not a dns packet")
+	}
+	return did.DHT(did.Prefix + ":" + id).FromDNSPacket(msg)
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"crypto/ed25519"
+	"testing"
+
+	"github.com/anacrolix/dht/v2/bep44"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/TBD54566975/did-dht/internal/did"
+	"github.com/TBD54566975/did-dht/pkg/dht"
+)
+
+func TestDIDTypeIndexing(t *testing.T) {
+	svc := newDHTService(t, "types")
+	ctx := context.Background()
+
+	sk, doc, err := did.GenerateDIDDHT(did.CreateDIDDHTOpts{})
+	require.NoError(t, err)
+	d := did.DHT(doc.ID)
+	suffix, err := d.Suffix()
+	require.NoError(t, err)
+
+	publish := func(t *testing.T, sk ed25519.PrivateKey, types []did.TypeIndex, seq int64) {
+		packet, err := d.ToDNSPacket(*doc, types, nil, nil)
+		require.NoError(t, err)
+		putMsg, err := dht.CreateDNSPublishRequest(sk, *packet)
+		require.NoError(t, err)
+		putMsg.Seq = seq
+		putMsg.Sign(sk)
+		require.NoError(t, svc.PublishDHT(ctx, suffix, dht.RecordFromBEP44(putMsg)))
+	}
+
+	t.Run("test types are indexed on publish", func(t *testing.T) {
+		publish(t, sk, []did.TypeIndex{did.Organization, did.FinancialInstitution}, 1)
+
+		dids, err := svc.ListDIDsForType(ctx, did.Organization, 0, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{doc.ID}, dids)
+
+		dids, err = svc.ListDIDsForType(ctx, did.FinancialInstitution, 0, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{doc.ID}, dids)
+	})
+
+	t.Run("test types are removed when they change", func(t *testing.T) {
+		publish(t, sk, []did.TypeIndex{did.Organization}, 2)
+
+		dids, err := svc.ListDIDsForType(ctx, did.FinancialInstitution, 0, 10)
+		assert.NoError(t, err)
+		assert.Empty(t, dids)
+
+		publish(t, sk, nil, 3)
+
+		dids, err = svc.ListDIDsForType(ctx, did.Organization, 0, 10)
+		assert.NoError(t, err)
+		assert.Empty(t, dids)
+	})
+
+	t.Run("test deactivated dids are removed from the index", func(t *testing.T) {
+		publish(t, sk, []did.TypeIndex{did.Organization}, 4)
+
+		packet, err := d.ToDeactivatedDNSPacket()
+		require.NoError(t, err)
+		putMsg, err := dht.CreateDNSPublishRequest(sk, *packet)
+		require.NoError(t, err)
+		putMsg.Seq = 5
+		putMsg.Sign(sk)
+		record := dht.RecordFromBEP44(putMsg)
+		require.NoError(t, svc.PublishDHT(ctx, suffix, record))
+		assert.True(t, isDeactivated(suffix, record))
+
+		dids, err := svc.ListDIDsForType(ctx, did.Organization, 0, 10)
+		assert.NoError(t, err)
+		assert.Empty(t, dids)
+	})
+
+	t.Run("test records which are not did documents are not indexed", func(t *testing.T) {
+		pk, otherSK, err := ed25519.GenerateKey(nil)
+		require.NoError(t, err)
+
+		putMsg := &bep44.Put{V: []byte("not a dns packet"), K: (*[32]byte)(pk), Seq: 1}
+		putMsg.Sign(otherSK)
+		record := dht.RecordFromBEP44(putMsg)
+		require.NoError(t, svc.PublishDHT(ctx, record.ID(), record))
+
+		types, err := svc.db.ReadDIDTypes(ctx, record.ID())
+		assert.NoError(t, err)
+		assert.Empty(t, types)
+		assert.False(t, isDeactivated(record.ID(), record))
+	})
+
+	t.Cleanup(func() { svc.Close() })
+}
@@ -1,9 +1,9 @@
 package bolt
 
 import (
-	"bytes"
 	"context"
 	"encoding/binary"
+	"strconv"
 	"time"
 
 	"github.com/goccy/go-json"
@@ -12,13 +12,27 @@ import (
 	"github.com/sirupsen/logrus"
 	bolt "go.etcd.io/bbolt"
 
+	"github.com/TBD54566975/did-dht/internal/did"
 	"github.com/TBD54566975/did-dht/pkg/dht"
 	"github.com/TBD54566975/did-dht/pkg/telemetry"
 )
 
 const (
-	dhtNamespace    = "dht"
-	failedNamespace = "failed"
+	dhtNamespace      = "dht"
+	failedNamespace   = "failed"
+	retainedNamespace = "retained"
+	// historyNamespace holds a nested namespace per record, mapping each of its sequence numbers to the record
+	historyNamespace = "history"
+	// typesNamespace maps each DID to its indexed types, and typeNamespacePrefix followed by a type index is the
+	// namespace of the DIDs indexed under that type
+	typesNamespace      = "types"
+	typeNamespacePrefix = "type-"
+	// scheduleNamespace maps each record to the unix timestamp at which it is next republished
+	scheduleNamespace = "schedule"
+	// outboxNamespace holds the records waiting to be put into the DHT
+	outboxNamespace = "outbox"
+	// tombstoneNamespace holds the keys of deleted records
+	tombstoneNamespace = "tombstones"
 )
 
 type Bolt struct {
@@ -41,10 +55,10 @@ func NewBolt(path string) (*Bolt, error) {
 	return &Bolt{db: db}, nil
 }
 
-// WriteRecord writes the given record to the storage
-// TODO: don't overwrite existing records, store unique seq numbers
+// WriteRecord writes the given record to the storage and its history. The record replaces the latest record for its
+// key unless the latest record has a higher sequence number.
 func (b *Bolt) WriteRecord(ctx context.Context, record dht.BEP44Record) error {
-	ctx, span := telemetry.GetTracer().Start(ctx, "bolt.WriteRecord")
+	_, span := telemetry.GetTracer().Start(ctx, "bolt.WriteRecord")
 	defer span.End()
 
 	encoded := encodeRecord(record)
@@ -53,7 +67,61 @@ func (b *Bolt) WriteRecord(ctx context.Context, record dht.BEP44Record) error {
 		return err
 	}
 
-	return b.write(ctx, dhtNamespace, record.ID(), recordBytes)
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return writeRecord(tx, record.ID(), record.SequenceNumber, recordBytes)
+	})
+}
+
+// WriteRecordWithOutbox writes the given record like WriteRecord, and queues it in the outbox to be put into the DHT
+// in the same transaction
+func (b *Bolt) WriteRecordWithOutbox(ctx context.Context, record dht.BEP44Record) error {
+	_, span := telemetry.GetTracer().Start(ctx, "bolt.WriteRecordWithOutbox")
+	defer span.End()
+
+	encoded := encodeRecord(record)
+	recordBytes, err := json.Marshal(encoded)
+	if err != nil {
+		return err
+	}
+
+	id := record.ID()
+	return b.db.Update(func(tx *bolt.Tx) error {
+		if err = writeRecord(tx, id, record.SequenceNumber, recordBytes); err != nil {
+			return err
+		}
+		return writeOutboxRecord(tx, dht.OutboxRecord{ID: id, Seq: record.SequenceNumber, Queued: time.Now().Unix()})
+	})
+}
+
+func writeRecord(tx *bolt.Tx, id string, seq int64, recordBytes []byte) error {
+	bucket, err := tx.CreateBucketIfNotExists([]byte(dhtNamespace))
+	if err != nil {
+		return err
+	}
+
+	replaceLatest := true
+	if existingBytes := bucket.Get([]byte(id)); existingBytes != nil {
+		var existing base64BEP44Record
+		if err = json.Unmarshal(existingBytes, &existing); err != nil {
+			return err
+		}
+		replaceLatest = existing.Seq <= seq
+	}
+	if replaceLatest {
+		if err = bucket.Put([]byte(id), recordBytes); err != nil {
+			return err
+		}
+	}
+
+	historyBucket, err := tx.CreateBucketIfNotExists([]byte(historyNamespace))
+	if err != nil {
+		return err
+	}
+	recordHistoryBucket, err := historyBucket.CreateBucketIfNotExists([]byte(id))
+	if err != nil {
+		return err
+	}
+	return recordHistoryBucket.Put(sequenceKey(seq), recordBytes)
 }
 
 // ReadRecord reads the record with the given id from the storage
@@ -82,6 +150,111 @@ func (b *Bolt) ReadRecord(ctx context.Context, id string) (*dht.BEP44Record, err
 	return record, nil
 }
 
+// DeleteRecord deletes the record with the given id and its history from the storage
+func (b *Bolt) DeleteRecord(ctx context.Context, id string) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "bolt.DeleteRecord")
+	defer span.End()
+
+	if err := b.delete(ctx, dhtNamespace, id); err != nil {
+		return err
+	}
+	return b.db.Update(func(tx *bolt.Tx) error {
+		historyBucket := tx.Bucket([]byte(historyNamespace))
+		if historyBucket == nil || historyBucket.Bucket([]byte(id)) == nil {
+			return nil
+		}
+		return historyBucket.DeleteBucket([]byte(id))
+	})
+}
+
+// ReadRecordAtSequence reads the record with the given id and sequence number from the history
+func (b *Bolt) ReadRecordAtSequence(ctx context.Context, id string, seq int64) (*dht.BEP44Record, error) {
+	_, span := telemetry.GetTracer().Start(ctx, "bolt.ReadRecordAtSequence")
+	defer span.End()
+
+	var record *dht.BEP44Record
+	err := b.db.View(func(tx *bolt.Tx) error {
+		recordHistoryBucket := recordHistory(tx, id)
+		if recordHistoryBucket == nil {
+			return nil
+		}
+		recordBytes := recordHistoryBucket.Get(sequenceKey(seq))
+		if recordBytes == nil {
+			return nil
+		}
+
+		var b64record base64BEP44Record
+		if err := json.Unmarshal(recordBytes, &b64record); err != nil {
+			return err
+		}
+		decoded, err := b64record.Decode()
+		if err != nil {
+			return err
+		}
+		record = decoded
+		return nil
+	})
+	return record, err
+}
+
+// ListSequenceNumbers lists the sequence numbers in the history of the record with the given id in ascending order
+func (b *Bolt) ListSequenceNumbers(ctx context.Context, id string) ([]int64, error) {
+	_, span := telemetry.GetTracer().Start(ctx, "bolt.ListSequenceNumbers")
+	defer span.End()
+
+	var seqs []int64
+	err := b.db.View(func(tx *bolt.Tx) error {
+		recordHistoryBucket := recordHistory(tx, id)
+		if recordHistoryBucket == nil {
+			return nil
+		}
+		return recordHistoryBucket.ForEach(func(k, _ []byte) error {
+			seqs = append(seqs, int64(binary.BigEndian.Uint64(k)))
+			return nil
+		})
+	})
+	return seqs, err
+}
+
+// PruneHistory deletes all but the keep most recent records from the history of the record with the given id
+func (b *Bolt) PruneHistory(ctx context.Context, id string, keep int) error {
+	_, span := telemetry.GetTracer().Start(ctx, "bolt.PruneHistory")
+	defer span.End()
+
+	return b.db.Update(func(tx *bolt.Tx) error {
+		recordHistoryBucket := recordHistory(tx, id)
+		if recordHistoryBucket == nil {
+			return nil
+		}
+
+		toDelete := recordHistoryBucket.Stats().KeyN - keep
+		cursor := recordHistoryBucket.Cursor()
+		for k, _ := cursor.First(); k != nil && toDelete > 0; k, _ = cursor.First() {
+			if err := cursor.Delete(); err != nil {
+				return err
+			}
+			toDelete--
+		}
+		return nil
+	})
+}
+
+// recordHistory returns the history bucket for the record with the given id, nil if there is none
+func recordHistory(tx *bolt.Tx, id string) *bolt.Bucket {
+	historyBucket := tx.Bucket([]byte(historyNamespace))
+	if historyBucket == nil {
+		return nil
+	}
+	return historyBucket.Bucket([]byte(id))
+}
+
+// sequenceKey returns the key for a sequence number in a record's history, which sorts in sequence number order
+func sequenceKey(seq int64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(seq))
+	return key
+}
+
 // ListRecords lists all records in the storage
 func (b *Bolt) ListRecords(ctx context.Context, nextPageToken []byte, pageSize int) ([]dht.BEP44Record, []byte, error) {
 	ctx, span := telemetry.GetTracer().Start(ctx, "bolt.ListRecords")
@@ -136,6 +309,20 @@ func (b *Bolt) write(ctx context.Context, namespace string, key string, value []
 	})
 }
 
+func (b *Bolt) delete(ctx context.Context, namespace, key string) error {
+	_, span := telemetry.GetTracer().Start(ctx, "bolt.delete")
+	defer span.End()
+
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(namespace))
+		if bucket == nil {
+			logrus.WithContext(ctx).WithField("namespace", namespace).Info("namespace does not exist")
+			return nil
+		}
+		return bucket.Delete([]byte(key))
+	})
+}
+
 func (b *Bolt) read(ctx context.Context, namespace, key string) ([]byte, error) {
 	_, span := telemetry.GetTracer().Start(ctx, "bolt.read")
 	defer span.End()
@@ -222,31 +409,35 @@ func (b *Bolt) RecordCount(ctx context.Context) (int, error) {
 	return count, err
 }
 
-func (b *Bolt) WriteFailedRecord(ctx context.Context, id string) error {
-	_, span := telemetry.GetTracer().Start(ctx, "bolt.WriteFailedRecord")
+// WriteFailedRecord writes the given failed record to the retry queue, replacing any existing entry for its id
+func (b *Bolt) WriteFailedRecord(ctx context.Context, record dht.FailedRecord) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "bolt.WriteFailedRecord")
 	defer span.End()
 
-	return b.db.Update(func(tx *bolt.Tx) error {
-		bucket, err := tx.CreateBucketIfNotExists([]byte(failedNamespace))
-		if err != nil {
-			return err
-		}
+	recordBytes, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
 
-		var count int32 = 1
-		v := bucket.Get([]byte(id))
-		if v != nil {
-			count = int32(binary.LittleEndian.Uint32(v))
-			count++
-		}
+	return b.write(ctx, failedNamespace, record.ID, recordBytes)
+}
 
-		buf := new(bytes.Buffer)
-		if err = binary.Write(buf, binary.LittleEndian, count); err != nil {
-			return err
-		}
-		return bucket.Put([]byte(id), buf.Bytes())
-	})
+// ReadFailedRecord reads the retry queue entry for the given id, returning nil if there is none
+func (b *Bolt) ReadFailedRecord(ctx context.Context, id string) (*dht.FailedRecord, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "bolt.ReadFailedRecord")
+	defer span.End()
+
+	recordBytes, err := b.read(ctx, failedNamespace, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(recordBytes) == 0 {
+		return nil, nil
+	}
+	return decodeFailedRecord(id, recordBytes)
 }
 
+// ListFailedRecords lists all entries in the retry queue, including dead-lettered entries
 func (b *Bolt) ListFailedRecords(ctx context.Context) ([]dht.FailedRecord, error) {
 	_, span := telemetry.GetTracer().Start(ctx, "bolt.ListFailedRecords")
 	defer span.End()
@@ -261,17 +452,38 @@ func (b *Bolt) ListFailedRecords(ctx context.Context) ([]dht.FailedRecord, error
 
 		cursor := bucket.Cursor()
 		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
-			var count int
-			if err := binary.Read(bytes.NewReader(v), binary.LittleEndian, &count); err != nil {
+			record, err := decodeFailedRecord(string(k), v)
+			if err != nil {
 				return err
 			}
-			result = append(result, dht.FailedRecord{ID: string(k), Count: count})
+			result = append(result, *record)
 		}
 		return nil
 	})
 	return result, err
 }
 
+// DeleteFailedRecord removes the given id from the retry queue
+func (b *Bolt) DeleteFailedRecord(ctx context.Context, id string) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "bolt.DeleteFailedRecord")
+	defer span.End()
+
+	return b.delete(ctx, failedNamespace, id)
+}
+
+// decodeFailedRecord decodes a retry queue entry, which before the queue held more than a failure count was stored as a
+// little-endian int32 count. Such entries are due to be retried immediately.
+func decodeFailedRecord(id string, v []byte) (*dht.FailedRecord, error) {
+	if len(v) == 4 {
+		return &dht.FailedRecord{ID: id, Count: int(int32(binary.LittleEndian.Uint32(v)))}, nil
+	}
+	var record dht.FailedRecord
+	if err := json.Unmarshal(v, &record); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode failed record: %s", id)
+	}
+	return &record, nil
+}
+
 func (b *Bolt) FailedRecordCount(ctx context.Context) (int, error) {
 	_, span := telemetry.GetTracer().Start(ctx, "bolt.FailedRecordCount")
 	defer span.End()
@@ -288,3 +500,370 @@ func (b *Bolt) FailedRecordCount(ctx context.Context) (int, error) {
 	})
 	return count, err
 }
+
+// WriteRetainedRecord writes the given record to the Retained DID Set, replacing any existing entry for its id
+func (b *Bolt) WriteRetainedRecord(ctx context.Context, record dht.RetainedRecord) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "bolt.WriteRetainedRecord")
+	defer span.End()
+
+	recordBytes, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	return b.write(ctx, retainedNamespace, record.ID, recordBytes)
+}
+
+// ReadRetainedRecord reads the Retained DID Set entry for the given id, returning nil if there is none
+func (b *Bolt) ReadRetainedRecord(ctx context.Context, id string) (*dht.RetainedRecord, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "bolt.ReadRetainedRecord")
+	defer span.End()
+
+	recordBytes, err := b.read(ctx, retainedNamespace, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(recordBytes) == 0 {
+		return nil, nil
+	}
+
+	var record dht.RetainedRecord
+	if err = json.Unmarshal(recordBytes, &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// ListRetainedRecords lists all entries in the Retained DID Set, including expired entries that have not been deleted
+func (b *Bolt) ListRetainedRecords(ctx context.Context) ([]dht.RetainedRecord, error) {
+	_, span := telemetry.GetTracer().Start(ctx, "bolt.ListRetainedRecords")
+	defer span.End()
+
+	var records []dht.RetainedRecord
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(retainedNamespace))
+		if bucket == nil {
+			logrus.WithContext(ctx).WithField("namespace", retainedNamespace).Info("namespace does not exist")
+			return nil
+		}
+		return bucket.ForEach(func(_, v []byte) error {
+			var record dht.RetainedRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				return err
+			}
+			records = append(records, record)
+			return nil
+		})
+	})
+	return records, err
+}
+
+// DeleteRetainedRecord removes the given id from the Retained DID Set
+func (b *Bolt) DeleteRetainedRecord(ctx context.Context, id string) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "bolt.DeleteRetainedRecord")
+	defer span.End()
+
+	return b.delete(ctx, retainedNamespace, id)
+}
+
+// WriteRepublishSchedule sets the time at which the given record is next republished
+func (b *Bolt) WriteRepublishSchedule(ctx context.Context, record dht.ScheduledRecord) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "bolt.WriteRepublishSchedule")
+	defer span.End()
+
+	return b.write(ctx, scheduleNamespace, record.ID, sequenceKey(record.Due))
+}
+
+// ListRepublishSchedule lists the time at which each scheduled record is next republished
+func (b *Bolt) ListRepublishSchedule(ctx context.Context) ([]dht.ScheduledRecord, error) {
+	_, span := telemetry.GetTracer().Start(ctx, "bolt.ListRepublishSchedule")
+	defer span.End()
+
+	var records []dht.ScheduledRecord
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(scheduleNamespace))
+		if bucket == nil {
+			logrus.WithContext(ctx).WithField("namespace", scheduleNamespace).Info("namespace does not exist")
+			return nil
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			records = append(records, dht.ScheduledRecord{ID: string(k), Due: int64(binary.BigEndian.Uint64(v))})
+			return nil
+		})
+	})
+	return records, err
+}
+
+// DeleteRepublishSchedule removes the given record from the republish schedule
+func (b *Bolt) DeleteRepublishSchedule(ctx context.Context, id string) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "bolt.DeleteRepublishSchedule")
+	defer span.End()
+
+	return b.delete(ctx, scheduleNamespace, id)
+}
+
+// WriteOutboxRecord queues the given record in the outbox, unless a higher sequence number is already queued
+func (b *Bolt) WriteOutboxRecord(ctx context.Context, record dht.OutboxRecord) error {
+	_, span := telemetry.GetTracer().Start(ctx, "bolt.WriteOutboxRecord")
+	defer span.End()
+
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return writeOutboxRecord(tx, record)
+	})
+}
+
+func writeOutboxRecord(tx *bolt.Tx, record dht.OutboxRecord) error {
+	bucket, err := tx.CreateBucketIfNotExists([]byte(outboxNamespace))
+	if err != nil {
+		return err
+	}
+	if existingBytes := bucket.Get([]byte(record.ID)); existingBytes != nil {
+		var existing dht.OutboxRecord
+		if err = json.Unmarshal(existingBytes, &existing); err != nil {
+			return err
+		}
+		if existing.Seq > record.Seq {
+			return nil
+		}
+	}
+	recordBytes, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return bucket.Put([]byte(record.ID), recordBytes)
+}
+
+// ReadOutboxRecord reads the outbox entry for the given id, returning nil if there is none
+func (b *Bolt) ReadOutboxRecord(ctx context.Context, id string) (*dht.OutboxRecord, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "bolt.ReadOutboxRecord")
+	defer span.End()
+
+	recordBytes, err := b.read(ctx, outboxNamespace, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(recordBytes) == 0 {
+		return nil, nil
+	}
+
+	var record dht.OutboxRecord
+	if err = json.Unmarshal(recordBytes, &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// ListOutbox lists all records waiting to be put into the DHT
+func (b *Bolt) ListOutbox(ctx context.Context) ([]dht.OutboxRecord, error) {
+	_, span := telemetry.GetTracer().Start(ctx, "bolt.ListOutbox")
+	defer span.End()
+
+	var records []dht.OutboxRecord
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(outboxNamespace))
+		if bucket == nil {
+			logrus.WithContext(ctx).WithField("namespace", outboxNamespace).Info("namespace does not exist")
+			return nil
+		}
+		return bucket.ForEach(func(_, v []byte) error {
+			var record dht.OutboxRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				return err
+			}
+			records = append(records, record)
+			return nil
+		})
+	})
+	return records, err
+}
+
+// DeleteOutboxRecord removes the given id from the outbox once the given sequence number has been put, leaving any
+// higher sequence number queued since
+func (b *Bolt) DeleteOutboxRecord(ctx context.Context, id string, seq int64) error {
+	_, span := telemetry.GetTracer().Start(ctx, "bolt.DeleteOutboxRecord")
+	defer span.End()
+
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(outboxNamespace))
+		if bucket == nil {
+			return nil
+		}
+		existingBytes := bucket.Get([]byte(id))
+		if existingBytes == nil {
+			return nil
+		}
+		var existing dht.OutboxRecord
+		if err := json.Unmarshal(existingBytes, &existing); err != nil {
+			return err
+		}
+		if existing.Seq > seq {
+			return nil
+		}
+		return bucket.Delete([]byte(id))
+	})
+}
+
+// WriteTombstone writes the given tombstone, replacing any existing tombstone for its id
+func (b *Bolt) WriteTombstone(ctx context.Context, tombstone dht.Tombstone) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "bolt.WriteTombstone")
+	defer span.End()
+
+	tombstoneBytes, err := json.Marshal(tombstone)
+	if err != nil {
+		return err
+	}
+
+	return b.write(ctx, tombstoneNamespace, tombstone.ID, tombstoneBytes)
+}
+
+// ReadTombstone reads the tombstone for the given id, returning nil if there is none
+func (b *Bolt) ReadTombstone(ctx context.Context, id string) (*dht.Tombstone, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "bolt.ReadTombstone")
+	defer span.End()
+
+	tombstoneBytes, err := b.read(ctx, tombstoneNamespace, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(tombstoneBytes) == 0 {
+		return nil, nil
+	}
+
+	var tombstone dht.Tombstone
+	if err = json.Unmarshal(tombstoneBytes, &tombstone); err != nil {
+		return nil, err
+	}
+	return &tombstone, nil
+}
+
+// ListTombstones lists all tombstones, including expired tombstones that have not been deleted
+func (b *Bolt) ListTombstones(ctx context.Context) ([]dht.Tombstone, error) {
+	_, span := telemetry.GetTracer().Start(ctx, "bolt.ListTombstones")
+	defer span.End()
+
+	var tombstones []dht.Tombstone
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(tombstoneNamespace))
+		if bucket == nil {
+			logrus.WithContext(ctx).WithField("namespace", tombstoneNamespace).Info("namespace does not exist")
+			return nil
+		}
+		return bucket.ForEach(func(_, v []byte) error {
+			var tombstone dht.Tombstone
+			if err := json.Unmarshal(v, &tombstone); err != nil {
+				return err
+			}
+			tombstones = append(tombstones, tombstone)
+			return nil
+		})
+	})
+	return tombstones, err
+}
+
+// DeleteTombstone removes the tombstone for the given id
+func (b *Bolt) DeleteTombstone(ctx context.Context, id string) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "bolt.DeleteTombstone")
+	defer span.End()
+
+	return b.delete(ctx, tombstoneNamespace, id)
+}
+
+// WriteDIDTypes replaces the indexed types for the given id, removing it from the index if there are none
+func (b *Bolt) WriteDIDTypes(ctx context.Context, id string, types []did.TypeIndex) error {
+	_, span := telemetry.GetTracer().Start(ctx, "bolt.WriteDIDTypes")
+	defer span.End()
+
+	return b.db.Update(func(tx *bolt.Tx) error {
+		typesBucket, err := tx.CreateBucketIfNotExists([]byte(typesNamespace))
+		if err != nil {
+			return err
+		}
+
+		// remove the id from the namespaces of its previous types
+		if existing := typesBucket.Get([]byte(id)); existing != nil {
+			var existingTypes []did.TypeIndex
+			if err = json.Unmarshal(existing, &existingTypes); err != nil {
+				return err
+			}
+			for _, t := range existingTypes {
+				if bucket := tx.Bucket(typeNamespace(t)); bucket != nil {
+					if err = bucket.Delete([]byte(id)); err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		if len(types) == 0 {
+			return typesBucket.Delete([]byte(id))
+		}
+
+		typesBytes, err := json.Marshal(types)
+		if err != nil {
+			return err
+		}
+		if err = typesBucket.Put([]byte(id), typesBytes); err != nil {
+			return err
+		}
+		for _, t := range types {
+			bucket, err := tx.CreateBucketIfNotExists(typeNamespace(t))
+			if err != nil {
+				return err
+			}
+			if err = bucket.Put([]byte(id), []byte{}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ReadDIDTypes reads the indexed types for the given id
+func (b *Bolt) ReadDIDTypes(ctx context.Context, id string) ([]did.TypeIndex, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "bolt.ReadDIDTypes")
+	defer span.End()
+
+	typesBytes, err := b.read(ctx, typesNamespace, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(typesBytes) == 0 {
+		return nil, nil
+	}
+
+	var types []did.TypeIndex
+	if err = json.Unmarshal(typesBytes, &types); err != nil {
+		return nil, err
+	}
+	return types, nil
+}
+
+// ListDIDsForType lists the ids indexed under the given type in order, skipping offset ids and returning at most limit
+func (b *Bolt) ListDIDsForType(ctx context.Context, typeIndex did.TypeIndex, offset, limit int) ([]string, error) {
+	_, span := telemetry.GetTracer().Start(ctx, "bolt.ListDIDsForType")
+	defer span.End()
+
+	var ids []string
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(typeNamespace(typeIndex))
+		if bucket == nil {
+			return nil
+		}
+
+		cursor := bucket.Cursor()
+		skipped := 0
+		for k, _ := cursor.First(); k != nil && len(ids) < limit; k, _ = cursor.Next() {
+			if skipped < offset {
+				skipped++
+				continue
+			}
+			ids = append(ids, string(k))
+		}
+		return nil
+	})
+	return ids, err
+}
+
+func typeNamespace(typeIndex did.TypeIndex) []byte {
+	return []byte(typeNamespacePrefix + strconv.Itoa(int(typeIndex)))
+}
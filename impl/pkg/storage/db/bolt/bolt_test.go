@@ -222,3 +222,299 @@ func TestNewBolt(t *testing.T) {
 	assert.Error(t, err)
 	assert.Nil(t, b)
 }
+
+func TestRetainedRecords(t *testing.T) {
+	ctx := context.Background()
+	db := getTestDB(t)
+
+	got, err := db.ReadRetainedRecord(ctx, "missing")
+	assert.NoError(t, err)
+	assert.Nil(t, got)
+
+	record := dht.RetainedRecord{ID: "retained", Expiry: 1700000000}
+	require.NoError(t, db.WriteRetainedRecord(ctx, record))
+
+	got, err = db.ReadRetainedRecord(ctx, record.ID)
+	assert.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, record, *got)
+}
+
+func TestListAndDeleteRetainedRecords(t *testing.T) {
+	ctx := context.Background()
+	db := getTestDB(t)
+
+	records, err := db.ListRetainedRecords(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, records)
+
+	first := dht.RetainedRecord{ID: "first", Expiry: 1700000000}
+	second := dht.RetainedRecord{ID: "second", Expiry: 1800000000}
+	require.NoError(t, db.WriteRetainedRecord(ctx, first))
+	require.NoError(t, db.WriteRetainedRecord(ctx, second))
+
+	records, err = db.ListRetainedRecords(ctx)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []dht.RetainedRecord{first, second}, records)
+
+	require.NoError(t, db.DeleteRetainedRecord(ctx, first.ID))
+
+	records, err = db.ListRetainedRecords(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []dht.RetainedRecord{second}, records)
+}
+
+func TestDIDTypes(t *testing.T) {
+	ctx := context.Background()
+	db := getTestDB(t)
+
+	types, err := db.ReadDIDTypes(ctx, "missing")
+	assert.NoError(t, err)
+	assert.Empty(t, types)
+
+	ids, err := db.ListDIDsForType(ctx, did.Organization, 0, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, ids)
+
+	require.NoError(t, db.WriteDIDTypes(ctx, "a", []did.TypeIndex{did.Organization, did.Corporation}))
+	require.NoError(t, db.WriteDIDTypes(ctx, "b", []did.TypeIndex{did.Organization}))
+	require.NoError(t, db.WriteDIDTypes(ctx, "c", []did.TypeIndex{did.Organization}))
+
+	types, err = db.ReadDIDTypes(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, []did.TypeIndex{did.Organization, did.Corporation}, types)
+
+	ids, err = db.ListDIDsForType(ctx, did.Organization, 0, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, ids)
+
+	// paginate
+	ids, err = db.ListDIDsForType(ctx, did.Organization, 1, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b"}, ids)
+
+	ids, err = db.ListDIDsForType(ctx, did.Organization, 3, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, ids)
+
+	// changing types removes the DID from its old types
+	require.NoError(t, db.WriteDIDTypes(ctx, "a", []did.TypeIndex{did.Corporation}))
+	ids, err = db.ListDIDsForType(ctx, did.Organization, 0, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b", "c"}, ids)
+
+	// removing all types removes the DID from the index
+	require.NoError(t, db.WriteDIDTypes(ctx, "a", nil))
+	types, err = db.ReadDIDTypes(ctx, "a")
+	assert.NoError(t, err)
+	assert.Empty(t, types)
+
+	ids, err = db.ListDIDsForType(ctx, did.Corporation, 0, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, ids)
+}
+
+func TestRecordHistory(t *testing.T) {
+	db := getTestDB(t)
+	ctx := context.Background()
+
+	sk, doc, err := did.GenerateDIDDHT(did.CreateDIDDHTOpts{})
+	require.NoError(t, err)
+	packet, err := did.DHT(doc.ID).ToDNSPacket(*doc, nil, nil, nil)
+	require.NoError(t, err)
+	putMsg, err := dht.CreateDNSPublishRequest(sk, *packet)
+	require.NoError(t, err)
+
+	// write three versions of the record, the last one out of order
+	var records []dht.BEP44Record
+	for i := 0; i < 3; i++ {
+		putMsg.Seq++
+		putMsg.Sign(sk)
+		records = append(records, dht.RecordFromBEP44(putMsg))
+	}
+	id := records[0].ID()
+	for _, r := range []dht.BEP44Record{records[0], records[2], records[1]} {
+		require.NoError(t, db.WriteRecord(ctx, r))
+	}
+
+	// the latest record is not replaced by an older one
+	latest, err := db.ReadRecord(ctx, id)
+	require.NoError(t, err)
+	require.NotNil(t, latest)
+	assert.Equal(t, records[2].SequenceNumber, latest.SequenceNumber)
+
+	seqs, err := db.ListSequenceNumbers(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{records[0].SequenceNumber, records[1].SequenceNumber, records[2].SequenceNumber}, seqs)
+
+	for _, r := range records {
+		got, err := db.ReadRecordAtSequence(ctx, id, r.SequenceNumber)
+		require.NoError(t, err)
+		require.NotNil(t, got)
+		assert.Equal(t, r, *got)
+	}
+
+	got, err := db.ReadRecordAtSequence(ctx, id, records[2].SequenceNumber+1)
+	assert.NoError(t, err)
+	assert.Nil(t, got)
+
+	// pruning keeps the most recent sequence numbers
+	require.NoError(t, db.PruneHistory(ctx, id, 2))
+	seqs, err = db.ListSequenceNumbers(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{records[1].SequenceNumber, records[2].SequenceNumber}, seqs)
+
+	// deleting the record deletes its history
+	require.NoError(t, db.DeleteRecord(ctx, id))
+	seqs, err = db.ListSequenceNumbers(ctx, id)
+	require.NoError(t, err)
+	assert.Empty(t, seqs)
+}
+
+func TestRepublishSchedule(t *testing.T) {
+	ctx := context.Background()
+	db := getTestDB(t)
+
+	scheduled, err := db.ListRepublishSchedule(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, scheduled)
+
+	first := dht.ScheduledRecord{ID: "first", Due: 1700000000}
+	second := dht.ScheduledRecord{ID: "second", Due: 1800000000}
+	require.NoError(t, db.WriteRepublishSchedule(ctx, first))
+	require.NoError(t, db.WriteRepublishSchedule(ctx, second))
+
+	// rescheduling replaces the due time
+	first.Due = 1900000000
+	require.NoError(t, db.WriteRepublishSchedule(ctx, first))
+
+	scheduled, err = db.ListRepublishSchedule(ctx)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []dht.ScheduledRecord{first, second}, scheduled)
+
+	require.NoError(t, db.DeleteRepublishSchedule(ctx, first.ID))
+
+	scheduled, err = db.ListRepublishSchedule(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []dht.ScheduledRecord{second}, scheduled)
+}
+
+func TestFailedRecords(t *testing.T) {
+	ctx := context.Background()
+	db := getTestDB(t)
+
+	got, err := db.ReadFailedRecord(ctx, "missing")
+	assert.NoError(t, err)
+	assert.Nil(t, got)
+
+	failed := dht.FailedRecord{
+		ID:           "failed",
+		Count:        2,
+		LastError:    "failed to put",
+		FirstFailure: 1700000000,
+		LastFailure:  1700000060,
+		NextAttempt:  1700000180,
+	}
+	require.NoError(t, db.WriteFailedRecord(ctx, failed))
+
+	got, err = db.ReadFailedRecord(ctx, failed.ID)
+	assert.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, failed, *got)
+
+	// entries written before the retry queue only held a little-endian failure count
+	require.NoError(t, db.write(ctx, failedNamespace, "legacy", []byte{3, 0, 0, 0}))
+
+	failedRecords, err := db.ListFailedRecords(ctx)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []dht.FailedRecord{failed, {ID: "legacy", Count: 3}}, failedRecords)
+
+	count, err := db.FailedRecordCount(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	require.NoError(t, db.DeleteFailedRecord(ctx, "legacy"))
+	failedRecords, err = db.ListFailedRecords(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []dht.FailedRecord{failed}, failedRecords)
+}
+
+func TestOutbox(t *testing.T) {
+	ctx := context.Background()
+	db := getTestDB(t)
+
+	sk, doc, err := did.GenerateDIDDHT(did.CreateDIDDHTOpts{})
+	require.NoError(t, err)
+	packet, err := did.DHT(doc.ID).ToDNSPacket(*doc, nil, nil, nil)
+	require.NoError(t, err)
+	putMsg, err := dht.CreateDNSPublishRequest(sk, *packet)
+	require.NoError(t, err)
+	record := dht.RecordFromBEP44(putMsg)
+	id := record.ID()
+
+	got, err := db.ReadOutboxRecord(ctx, id)
+	assert.NoError(t, err)
+	assert.Nil(t, got)
+
+	// the record and its outbox entry are written together
+	require.NoError(t, db.WriteRecordWithOutbox(ctx, record))
+	stored, err := db.ReadRecord(ctx, id)
+	assert.NoError(t, err)
+	require.NotNil(t, stored)
+	assert.Equal(t, record, *stored)
+
+	got, err = db.ReadOutboxRecord(ctx, id)
+	assert.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, record.SequenceNumber, got.Seq)
+	assert.NotZero(t, got.Queued)
+
+	// a lower sequence number does not replace the queued one
+	require.NoError(t, db.WriteOutboxRecord(ctx, dht.OutboxRecord{ID: id, Seq: record.SequenceNumber - 1, Queued: got.Queued}))
+	queued, err := db.ListOutbox(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []dht.OutboxRecord{*got}, queued)
+
+	// deleting a lower sequence number leaves the entry queued
+	require.NoError(t, db.DeleteOutboxRecord(ctx, id, record.SequenceNumber-1))
+	got, err = db.ReadOutboxRecord(ctx, id)
+	assert.NoError(t, err)
+	assert.NotNil(t, got)
+
+	require.NoError(t, db.DeleteOutboxRecord(ctx, id, record.SequenceNumber))
+	queued, err = db.ListOutbox(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, queued)
+}
+
+func TestTombstones(t *testing.T) {
+	ctx := context.Background()
+	db := getTestDB(t)
+
+	tombstone, err := db.ReadTombstone(ctx, "deleted")
+	assert.NoError(t, err)
+	assert.Nil(t, tombstone)
+
+	deleted := dht.Tombstone{ID: "deleted", Deleted: 1700000000, Expiry: 1800000000}
+	forever := dht.Tombstone{ID: "forever", Deleted: 1700000000}
+	require.NoError(t, db.WriteTombstone(ctx, deleted))
+	require.NoError(t, db.WriteTombstone(ctx, forever))
+
+	// deleting again replaces the tombstone
+	deleted.Deleted, deleted.Expiry = 1750000000, 1850000000
+	require.NoError(t, db.WriteTombstone(ctx, deleted))
+
+	tombstone, err = db.ReadTombstone(ctx, deleted.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, &deleted, tombstone)
+
+	tombstones, err := db.ListTombstones(ctx)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []dht.Tombstone{deleted, forever}, tombstones)
+
+	require.NoError(t, db.DeleteTombstone(ctx, deleted.ID))
+
+	tombstones, err = db.ListTombstones(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []dht.Tombstone{forever}, tombstones)
+}
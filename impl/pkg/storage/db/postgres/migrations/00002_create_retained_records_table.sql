@@ -0,0 +1,8 @@
+-- +goose Up
+CREATE TABLE retained_records (
+    id BYTEA PRIMARY KEY,
+    expiry BIGINT NOT NULL
+);
+
+-- +goose Down
+DROP TABLE retained_records;
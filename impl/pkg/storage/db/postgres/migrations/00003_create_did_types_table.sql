@@ -0,0 +1,11 @@
+-- +goose Up
+CREATE TABLE did_types (
+    id BYTEA NOT NULL,
+    type INTEGER NOT NULL,
+    PRIMARY KEY (id, type)
+);
+
+CREATE INDEX did_types_type_id_idx ON did_types (type, id);
+
+-- +goose Down
+DROP TABLE did_types;
@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE dht_record_history (
+    key BYTEA NOT NULL,
+    seq BIGINT NOT NULL,
+    value BYTEA NOT NULL,
+    sig BYTEA NOT NULL,
+    PRIMARY KEY (key, seq)
+);
+
+INSERT INTO dht_record_history(key, seq, value, sig) SELECT key, seq, value, sig FROM dht_records;
+
+-- +goose Down
+DROP TABLE dht_record_history;
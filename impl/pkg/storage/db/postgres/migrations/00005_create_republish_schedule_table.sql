@@ -0,0 +1,8 @@
+-- +goose Up
+CREATE TABLE republish_schedule (
+    id BYTEA PRIMARY KEY,
+    due BIGINT NOT NULL
+);
+
+-- +goose Down
+DROP TABLE republish_schedule;
@@ -0,0 +1,15 @@
+-- +goose Up
+ALTER TABLE failed_records
+    ADD COLUMN last_error TEXT NOT NULL DEFAULT '',
+    ADD COLUMN first_failure BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN last_failure BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN next_attempt BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN dead_lettered BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+ALTER TABLE failed_records
+    DROP COLUMN dead_lettered,
+    DROP COLUMN next_attempt,
+    DROP COLUMN last_failure,
+    DROP COLUMN first_failure,
+    DROP COLUMN last_error;
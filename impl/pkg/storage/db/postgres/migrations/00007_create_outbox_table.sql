@@ -0,0 +1,9 @@
+-- +goose Up
+CREATE TABLE outbox (
+    id BYTEA PRIMARY KEY,
+    seq BIGINT NOT NULL,
+    queued BIGINT NOT NULL
+);
+
+-- +goose Down
+DROP TABLE outbox;
@@ -0,0 +1,9 @@
+-- +goose Up
+CREATE TABLE tombstones (
+    id BYTEA PRIMARY KEY,
+    deleted BIGINT NOT NULL,
+    expiry BIGINT NOT NULL
+);
+
+-- +goose Down
+DROP TABLE tombstones;
@@ -12,7 +12,46 @@ type DhtRecord struct {
 	Seq   int64
 }
 
+type DidType struct {
+	ID   []byte
+	Type int32
+}
+
+type DhtRecordHistory struct {
+	Key   []byte
+	Seq   int64
+	Value []byte
+	Sig   []byte
+}
+
 type FailedRecord struct {
 	ID           []byte
 	FailureCount int32
+	LastError    string
+	FirstFailure int64
+	LastFailure  int64
+	NextAttempt  int64
+	DeadLettered bool
+}
+
+type Outbox struct {
+	ID     []byte
+	Seq    int64
+	Queued int64
+}
+
+type RepublishSchedule struct {
+	ID  []byte
+	Due int64
+}
+
+type RetainedRecord struct {
+	ID     []byte
+	Expiry int64
+}
+
+type Tombstone struct {
+	ID      []byte
+	Deleted int64
+	Expiry  int64
 }
@@ -4,7 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"embed"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	_ "github.com/jackc/pgx/v5/stdlib"
@@ -12,6 +14,7 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/tv42/zbase32"
 
+	"github.com/TBD54566975/did-dht/internal/did"
 	"github.com/TBD54566975/did-dht/pkg/dht"
 	"github.com/TBD54566975/did-dht/pkg/telemetry"
 )
@@ -72,7 +75,16 @@ func (p Postgres) WriteRecord(ctx context.Context, record dht.BEP44Record) error
 	}
 	defer db.Close(ctx)
 
-	err = queries.WriteRecord(ctx, WriteRecordParams{
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// the latest record is only replaced by a record with the same or a higher sequence number, every sequence
+	// number is kept in the history
+	txQueries := queries.WithTx(tx)
+	err = txQueries.WriteRecord(ctx, WriteRecordParams{
 		Key:   record.Key[:],
 		Value: record.Value[:],
 		Sig:   record.Signature[:],
@@ -81,8 +93,64 @@ func (p Postgres) WriteRecord(ctx context.Context, record dht.BEP44Record) error
 	if err != nil {
 		return err
 	}
+	err = txQueries.WriteRecordHistory(ctx, WriteRecordHistoryParams{
+		Key:   record.Key[:],
+		Seq:   record.SequenceNumber,
+		Value: record.Value[:],
+		Sig:   record.Signature[:],
+	})
+	if err != nil {
+		return err
+	}
 
-	return nil
+	return tx.Commit(ctx)
+}
+
+func (p Postgres) WriteRecordWithOutbox(ctx context.Context, record dht.BEP44Record) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "postgres.WriteRecordWithOutbox")
+	defer span.End()
+
+	queries, db, err := p.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close(ctx)
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	txQueries := queries.WithTx(tx)
+	err = txQueries.WriteRecord(ctx, WriteRecordParams{
+		Key:   record.Key[:],
+		Value: record.Value[:],
+		Sig:   record.Signature[:],
+		Seq:   record.SequenceNumber,
+	})
+	if err != nil {
+		return err
+	}
+	err = txQueries.WriteRecordHistory(ctx, WriteRecordHistoryParams{
+		Key:   record.Key[:],
+		Seq:   record.SequenceNumber,
+		Value: record.Value[:],
+		Sig:   record.Signature[:],
+	})
+	if err != nil {
+		return err
+	}
+	err = txQueries.WriteOutboxRecord(ctx, WriteOutboxRecordParams{
+		ID:     []byte(record.ID()),
+		Seq:    record.SequenceNumber,
+		Queued: time.Now().Unix(),
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
 }
 
 func (p Postgres) ReadRecord(ctx context.Context, id string) (*dht.BEP44Record, error) {
@@ -101,6 +169,9 @@ func (p Postgres) ReadRecord(ctx context.Context, id string) (*dht.BEP44Record,
 	}
 	row, err := queries.ReadRecord(ctx, decodedID)
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
 		return nil, err
 	}
 
@@ -112,6 +183,99 @@ func (p Postgres) ReadRecord(ctx context.Context, id string) (*dht.BEP44Record,
 	return record, nil
 }
 
+func (p Postgres) DeleteRecord(ctx context.Context, id string) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "postgres.DeleteRecord")
+	defer span.End()
+
+	queries, db, err := p.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close(ctx)
+
+	decodedID, err := zbase32.DecodeString(id)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	txQueries := queries.WithTx(tx)
+	if err = txQueries.DeleteRecord(ctx, decodedID); err != nil {
+		return err
+	}
+	if err = txQueries.DeleteRecordHistory(ctx, decodedID); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (p Postgres) ReadRecordAtSequence(ctx context.Context, id string, seq int64) (*dht.BEP44Record, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "postgres.ReadRecordAtSequence")
+	defer span.End()
+
+	queries, db, err := p.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close(ctx)
+
+	decodedID, err := zbase32.DecodeString(id)
+	if err != nil {
+		return nil, err
+	}
+	row, err := queries.ReadRecordAtSequence(ctx, ReadRecordAtSequenceParams{Key: decodedID, Seq: seq})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return dht.NewBEP44Record(row.Key, row.Value, row.Sig, row.Seq)
+}
+
+func (p Postgres) ListSequenceNumbers(ctx context.Context, id string) ([]int64, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "postgres.ListSequenceNumbers")
+	defer span.End()
+
+	queries, db, err := p.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close(ctx)
+
+	decodedID, err := zbase32.DecodeString(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return queries.ListSequenceNumbers(ctx, decodedID)
+}
+
+func (p Postgres) PruneHistory(ctx context.Context, id string, keep int) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "postgres.PruneHistory")
+	defer span.End()
+
+	queries, db, err := p.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close(ctx)
+
+	decodedID, err := zbase32.DecodeString(id)
+	if err != nil {
+		return err
+	}
+
+	return queries.PruneHistory(ctx, PruneHistoryParams{Key: decodedID, Limit: int32(keep)})
+}
+
 func (p Postgres) ListRecords(ctx context.Context, nextPageToken []byte, limit int) ([]dht.BEP44Record, []byte, error) {
 	ctx, span := telemetry.GetTracer().Start(ctx, "postgres.ListRecords")
 	defer span.End()
@@ -178,7 +342,92 @@ func (p Postgres) RecordCount(ctx context.Context) (int, error) {
 	return int(count), nil
 }
 
-func (p Postgres) WriteFailedRecord(ctx context.Context, id string) error {
+func (p Postgres) WriteOutboxRecord(ctx context.Context, record dht.OutboxRecord) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "postgres.WriteOutboxRecord")
+	defer span.End()
+
+	queries, db, err := p.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close(ctx)
+
+	return queries.WriteOutboxRecord(ctx, WriteOutboxRecordParams{
+		ID:     []byte(record.ID),
+		Seq:    record.Seq,
+		Queued: record.Queued,
+	})
+}
+
+func (p Postgres) ReadOutboxRecord(ctx context.Context, id string) (*dht.OutboxRecord, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "postgres.ReadOutboxRecord")
+	defer span.End()
+
+	queries, db, err := p.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close(ctx)
+
+	row, err := queries.ReadOutboxRecord(ctx, []byte(id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &dht.OutboxRecord{
+		ID:     string(row.ID),
+		Seq:    row.Seq,
+		Queued: row.Queued,
+	}, nil
+}
+
+func (p Postgres) ListOutbox(ctx context.Context) ([]dht.OutboxRecord, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "postgres.ListOutbox")
+	defer span.End()
+
+	queries, db, err := p.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close(ctx)
+
+	rows, err := queries.ListOutbox(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []dht.OutboxRecord
+	for _, row := range rows {
+		records = append(records, dht.OutboxRecord{
+			ID:     string(row.ID),
+			Seq:    row.Seq,
+			Queued: row.Queued,
+		})
+	}
+
+	return records, nil
+}
+
+func (p Postgres) DeleteOutboxRecord(ctx context.Context, id string, seq int64) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "postgres.DeleteOutboxRecord")
+	defer span.End()
+
+	queries, db, err := p.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close(ctx)
+
+	return queries.DeleteOutboxRecord(ctx, DeleteOutboxRecordParams{
+		ID:  []byte(id),
+		Seq: seq,
+	})
+}
+
+func (p Postgres) WriteFailedRecord(ctx context.Context, record dht.FailedRecord) error {
 	ctx, span := telemetry.GetTracer().Start(ctx, "postgres.WriteFailedRecord")
 	defer span.End()
 
@@ -189,8 +438,13 @@ func (p Postgres) WriteFailedRecord(ctx context.Context, id string) error {
 	defer db.Close(ctx)
 
 	err = queries.WriteFailedRecord(ctx, WriteFailedRecordParams{
-		ID:           []byte(id),
-		FailureCount: 1,
+		ID:           []byte(record.ID),
+		FailureCount: int32(record.Count),
+		LastError:    record.LastError,
+		FirstFailure: record.FirstFailure,
+		LastFailure:  record.LastFailure,
+		NextAttempt:  record.NextAttempt,
+		DeadLettered: record.DeadLettered,
 	})
 	if err != nil {
 		return err
@@ -199,6 +453,28 @@ func (p Postgres) WriteFailedRecord(ctx context.Context, id string) error {
 	return nil
 }
 
+func (p Postgres) ReadFailedRecord(ctx context.Context, id string) (*dht.FailedRecord, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "postgres.ReadFailedRecord")
+	defer span.End()
+
+	queries, db, err := p.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close(ctx)
+
+	row, err := queries.ReadFailedRecord(ctx, []byte(id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	record := row.Record()
+	return &record, nil
+}
+
 func (p Postgres) ListFailedRecords(ctx context.Context) ([]dht.FailedRecord, error) {
 	ctx, span := telemetry.GetTracer().Start(ctx, "postgres.ListFailedRecords")
 	defer span.End()
@@ -216,15 +492,37 @@ func (p Postgres) ListFailedRecords(ctx context.Context) ([]dht.FailedRecord, er
 
 	var failedRecords []dht.FailedRecord
 	for _, row := range rows {
-		failedRecords = append(failedRecords, dht.FailedRecord{
-			ID:    string(row.ID),
-			Count: int(row.FailureCount),
-		})
+		failedRecords = append(failedRecords, row.Record())
 	}
 
 	return failedRecords, nil
 }
 
+func (p Postgres) DeleteFailedRecord(ctx context.Context, id string) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "postgres.DeleteFailedRecord")
+	defer span.End()
+
+	queries, db, err := p.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close(ctx)
+
+	return queries.DeleteFailedRecord(ctx, []byte(id))
+}
+
+func (row FailedRecord) Record() dht.FailedRecord {
+	return dht.FailedRecord{
+		ID:           string(row.ID),
+		Count:        int(row.FailureCount),
+		LastError:    row.LastError,
+		FirstFailure: row.FirstFailure,
+		LastFailure:  row.LastFailure,
+		NextAttempt:  row.NextAttempt,
+		DeadLettered: row.DeadLettered,
+	}
+}
+
 func (p Postgres) FailedRecordCount(ctx context.Context) (int, error) {
 	ctx, span := telemetry.GetTracer().Start(ctx, "postgres.FailedRecordCount")
 	defer span.End()
@@ -243,6 +541,302 @@ func (p Postgres) FailedRecordCount(ctx context.Context) (int, error) {
 	return int(count), nil
 }
 
+func (p Postgres) WriteRetainedRecord(ctx context.Context, record dht.RetainedRecord) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "postgres.WriteRetainedRecord")
+	defer span.End()
+
+	queries, db, err := p.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close(ctx)
+
+	return queries.WriteRetainedRecord(ctx, WriteRetainedRecordParams{
+		ID:     []byte(record.ID),
+		Expiry: record.Expiry,
+	})
+}
+
+func (p Postgres) ReadRetainedRecord(ctx context.Context, id string) (*dht.RetainedRecord, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "postgres.ReadRetainedRecord")
+	defer span.End()
+
+	queries, db, err := p.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close(ctx)
+
+	row, err := queries.ReadRetainedRecord(ctx, []byte(id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &dht.RetainedRecord{
+		ID:     string(row.ID),
+		Expiry: row.Expiry,
+	}, nil
+}
+
+func (p Postgres) ListRetainedRecords(ctx context.Context) ([]dht.RetainedRecord, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "postgres.ListRetainedRecords")
+	defer span.End()
+
+	queries, db, err := p.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close(ctx)
+
+	rows, err := queries.ListRetainedRecords(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var retainedRecords []dht.RetainedRecord
+	for _, row := range rows {
+		retainedRecords = append(retainedRecords, dht.RetainedRecord{
+			ID:     string(row.ID),
+			Expiry: row.Expiry,
+		})
+	}
+
+	return retainedRecords, nil
+}
+
+func (p Postgres) DeleteRetainedRecord(ctx context.Context, id string) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "postgres.DeleteRetainedRecord")
+	defer span.End()
+
+	queries, db, err := p.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close(ctx)
+
+	return queries.DeleteRetainedRecord(ctx, []byte(id))
+}
+
+func (p Postgres) WriteRepublishSchedule(ctx context.Context, record dht.ScheduledRecord) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "postgres.WriteRepublishSchedule")
+	defer span.End()
+
+	queries, db, err := p.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close(ctx)
+
+	return queries.WriteRepublishSchedule(ctx, WriteRepublishScheduleParams{
+		ID:  []byte(record.ID),
+		Due: record.Due,
+	})
+}
+
+func (p Postgres) ListRepublishSchedule(ctx context.Context) ([]dht.ScheduledRecord, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "postgres.ListRepublishSchedule")
+	defer span.End()
+
+	queries, db, err := p.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close(ctx)
+
+	rows, err := queries.ListRepublishSchedule(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var scheduledRecords []dht.ScheduledRecord
+	for _, row := range rows {
+		scheduledRecords = append(scheduledRecords, dht.ScheduledRecord{
+			ID:  string(row.ID),
+			Due: row.Due,
+		})
+	}
+
+	return scheduledRecords, nil
+}
+
+func (p Postgres) DeleteRepublishSchedule(ctx context.Context, id string) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "postgres.DeleteRepublishSchedule")
+	defer span.End()
+
+	queries, db, err := p.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close(ctx)
+
+	return queries.DeleteRepublishSchedule(ctx, []byte(id))
+}
+
+func (p Postgres) WriteTombstone(ctx context.Context, tombstone dht.Tombstone) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "postgres.WriteTombstone")
+	defer span.End()
+
+	queries, db, err := p.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close(ctx)
+
+	return queries.WriteTombstone(ctx, WriteTombstoneParams{
+		ID:      []byte(tombstone.ID),
+		Deleted: tombstone.Deleted,
+		Expiry:  tombstone.Expiry,
+	})
+}
+
+func (p Postgres) ReadTombstone(ctx context.Context, id string) (*dht.Tombstone, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "postgres.ReadTombstone")
+	defer span.End()
+
+	queries, db, err := p.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close(ctx)
+
+	row, err := queries.ReadTombstone(ctx, []byte(id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	tombstone := row.Tombstone()
+	return &tombstone, nil
+}
+
+func (p Postgres) ListTombstones(ctx context.Context) ([]dht.Tombstone, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "postgres.ListTombstones")
+	defer span.End()
+
+	queries, db, err := p.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close(ctx)
+
+	rows, err := queries.ListTombstones(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var tombstones []dht.Tombstone
+	for _, row := range rows {
+		tombstones = append(tombstones, row.Tombstone())
+	}
+
+	return tombstones, nil
+}
+
+func (p Postgres) DeleteTombstone(ctx context.Context, id string) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "postgres.DeleteTombstone")
+	defer span.End()
+
+	queries, db, err := p.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close(ctx)
+
+	return queries.DeleteTombstone(ctx, []byte(id))
+}
+
+func (row Tombstone) Tombstone() dht.Tombstone {
+	return dht.Tombstone{
+		ID:      string(row.ID),
+		Deleted: row.Deleted,
+		Expiry:  row.Expiry,
+	}
+}
+
+func (p Postgres) WriteDIDTypes(ctx context.Context, id string, types []did.TypeIndex) error {
+	ctx, span := telemetry.GetTracer().Start(ctx, "postgres.WriteDIDTypes")
+	defer span.End()
+
+	queries, db, err := p.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close(ctx)
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	txQueries := queries.WithTx(tx)
+	if err = txQueries.DeleteDIDTypes(ctx, []byte(id)); err != nil {
+		return err
+	}
+	for _, t := range types {
+		if err = txQueries.WriteDIDType(ctx, WriteDIDTypeParams{ID: []byte(id), Type: int32(t)}); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (p Postgres) ReadDIDTypes(ctx context.Context, id string) ([]did.TypeIndex, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "postgres.ReadDIDTypes")
+	defer span.End()
+
+	queries, db, err := p.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close(ctx)
+
+	rows, err := queries.ReadDIDTypes(ctx, []byte(id))
+	if err != nil {
+		return nil, err
+	}
+
+	var types []did.TypeIndex
+	for _, row := range rows {
+		types = append(types, did.TypeIndex(row))
+	}
+
+	return types, nil
+}
+
+func (p Postgres) ListDIDsForType(ctx context.Context, typeIndex did.TypeIndex, offset, limit int) ([]string, error) {
+	ctx, span := telemetry.GetTracer().Start(ctx, "postgres.ListDIDsForType")
+	defer span.End()
+
+	queries, db, err := p.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close(ctx)
+
+	rows, err := queries.ListDIDsForType(ctx, ListDIDsForTypeParams{
+		Type:   int32(typeIndex),
+		Limit:  int32(limit),
+		Offset: int32(offset),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, row := range rows {
+		ids = append(ids, string(row))
+	}
+
+	return ids, nil
+}
+
 func (p Postgres) Close() error {
 	// no-op, postgres connection is closed after each request
 	return nil
@@ -139,3 +139,194 @@ func TestDBPagination(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, beforeCnt+11, afterCnt)
 }
+
+func TestDIDTypes(t *testing.T) {
+	db := getTestDB(t)
+	ctx := context.Background()
+
+	// use fresh ids so the test does not depend on the state of the database
+	_, doc, err := did.GenerateDIDDHT(did.CreateDIDDHTOpts{})
+	require.NoError(t, err)
+	id, err := did.DHT(doc.ID).Suffix()
+	require.NoError(t, err)
+
+	require.NoError(t, db.WriteDIDTypes(ctx, id, []did.TypeIndex{did.Organization, did.Corporation}))
+
+	types, err := db.ReadDIDTypes(ctx, id)
+	assert.NoError(t, err)
+	assert.Equal(t, []did.TypeIndex{did.Organization, did.Corporation}, types)
+
+	ids, err := db.ListDIDsForType(ctx, did.Corporation, 0, 1000)
+	assert.NoError(t, err)
+	assert.Contains(t, ids, id)
+
+	// changing types removes the DID from its old types
+	require.NoError(t, db.WriteDIDTypes(ctx, id, []did.TypeIndex{did.Organization}))
+	ids, err = db.ListDIDsForType(ctx, did.Corporation, 0, 1000)
+	assert.NoError(t, err)
+	assert.NotContains(t, ids, id)
+
+	require.NoError(t, db.WriteDIDTypes(ctx, id, nil))
+	types, err = db.ReadDIDTypes(ctx, id)
+	assert.NoError(t, err)
+	assert.Empty(t, types)
+}
+
+func TestRecordHistory(t *testing.T) {
+	db := getTestDB(t)
+	ctx := context.Background()
+
+	sk, doc, err := did.GenerateDIDDHT(did.CreateDIDDHTOpts{})
+	require.NoError(t, err)
+	packet, err := did.DHT(doc.ID).ToDNSPacket(*doc, nil, nil, nil)
+	require.NoError(t, err)
+	putMsg, err := dht.CreateDNSPublishRequest(sk, *packet)
+	require.NoError(t, err)
+
+	// write three versions of the record, the last one out of order
+	var records []dht.BEP44Record
+	for i := 0; i < 3; i++ {
+		putMsg.Seq++
+		putMsg.Sign(sk)
+		records = append(records, dht.RecordFromBEP44(putMsg))
+	}
+	id := records[0].ID()
+	for _, r := range []dht.BEP44Record{records[0], records[2], records[1]} {
+		require.NoError(t, db.WriteRecord(ctx, r))
+	}
+
+	// the latest record is not replaced by an older one
+	latest, err := db.ReadRecord(ctx, id)
+	require.NoError(t, err)
+	require.NotNil(t, latest)
+	assert.Equal(t, records[2].SequenceNumber, latest.SequenceNumber)
+
+	seqs, err := db.ListSequenceNumbers(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{records[0].SequenceNumber, records[1].SequenceNumber, records[2].SequenceNumber}, seqs)
+
+	for _, r := range records {
+		got, err := db.ReadRecordAtSequence(ctx, id, r.SequenceNumber)
+		require.NoError(t, err)
+		require.NotNil(t, got)
+		assert.Equal(t, r, *got)
+	}
+
+	got, err := db.ReadRecordAtSequence(ctx, id, records[2].SequenceNumber+1)
+	assert.NoError(t, err)
+	assert.Nil(t, got)
+
+	// pruning keeps the most recent sequence numbers
+	require.NoError(t, db.PruneHistory(ctx, id, 2))
+	seqs, err = db.ListSequenceNumbers(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{records[1].SequenceNumber, records[2].SequenceNumber}, seqs)
+
+	// deleting the record deletes its history
+	require.NoError(t, db.DeleteRecord(ctx, id))
+	seqs, err = db.ListSequenceNumbers(ctx, id)
+	require.NoError(t, err)
+	assert.Empty(t, seqs)
+}
+
+func TestRepublishSchedule(t *testing.T) {
+	db := getTestDB(t)
+	ctx := context.Background()
+
+	scheduled := dht.ScheduledRecord{ID: "scheduled", Due: 1700000000}
+	require.NoError(t, db.WriteRepublishSchedule(ctx, scheduled))
+	scheduled.Due = 1800000000
+	require.NoError(t, db.WriteRepublishSchedule(ctx, scheduled))
+
+	records, err := db.ListRepublishSchedule(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, records, scheduled)
+
+	require.NoError(t, db.DeleteRepublishSchedule(ctx, scheduled.ID))
+	records, err = db.ListRepublishSchedule(ctx)
+	require.NoError(t, err)
+	assert.NotContains(t, records, scheduled)
+}
+
+func TestFailedRecords(t *testing.T) {
+	db := getTestDB(t)
+	ctx := context.Background()
+
+	failed := dht.FailedRecord{
+		ID:           "failed",
+		Count:        1,
+		LastError:    "failed to put",
+		FirstFailure: 1700000000,
+		LastFailure:  1700000000,
+		NextAttempt:  1700000060,
+	}
+	require.NoError(t, db.WriteFailedRecord(ctx, failed))
+	failed.Count = 2
+	failed.DeadLettered = true
+	require.NoError(t, db.WriteFailedRecord(ctx, failed))
+
+	got, err := db.ReadFailedRecord(ctx, failed.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, failed, *got)
+
+	require.NoError(t, db.DeleteFailedRecord(ctx, failed.ID))
+	got, err = db.ReadFailedRecord(ctx, failed.ID)
+	assert.NoError(t, err)
+	assert.Nil(t, got)
+}
+
+func TestOutbox(t *testing.T) {
+	db := getTestDB(t)
+	ctx := context.Background()
+
+	sk, doc, err := did.GenerateDIDDHT(did.CreateDIDDHTOpts{})
+	require.NoError(t, err)
+	packet, err := did.DHT(doc.ID).ToDNSPacket(*doc, nil, nil, nil)
+	require.NoError(t, err)
+	putMsg, err := dht.CreateDNSPublishRequest(sk, *packet)
+	require.NoError(t, err)
+	record := dht.RecordFromBEP44(putMsg)
+	id := record.ID()
+
+	require.NoError(t, db.WriteRecordWithOutbox(ctx, record))
+	got, err := db.ReadOutboxRecord(ctx, id)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, record.SequenceNumber, got.Seq)
+
+	require.NoError(t, db.DeleteOutboxRecord(ctx, id, record.SequenceNumber-1))
+	got, err = db.ReadOutboxRecord(ctx, id)
+	require.NoError(t, err)
+	assert.NotNil(t, got)
+
+	require.NoError(t, db.DeleteOutboxRecord(ctx, id, record.SequenceNumber))
+	got, err = db.ReadOutboxRecord(ctx, id)
+	assert.NoError(t, err)
+	assert.Nil(t, got)
+
+	require.NoError(t, db.DeleteRecord(ctx, id))
+}
+
+func TestTombstones(t *testing.T) {
+	db := getTestDB(t)
+	ctx := context.Background()
+
+	tombstone := dht.Tombstone{ID: "deleted", Deleted: 1700000000, Expiry: 1800000000}
+	require.NoError(t, db.WriteTombstone(ctx, tombstone))
+	tombstone.Expiry = 1900000000
+	require.NoError(t, db.WriteTombstone(ctx, tombstone))
+
+	read, err := db.ReadTombstone(ctx, tombstone.ID)
+	require.NoError(t, err)
+	assert.Equal(t, &tombstone, read)
+
+	tombstones, err := db.ListTombstones(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, tombstones, tombstone)
+
+	require.NoError(t, db.DeleteTombstone(ctx, tombstone.ID))
+	read, err = db.ReadTombstone(ctx, tombstone.ID)
+	require.NoError(t, err)
+	assert.Nil(t, read)
+}
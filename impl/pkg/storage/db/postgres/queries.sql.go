@@ -9,6 +9,83 @@ import (
 	"context"
 )
 
+const deleteDIDTypes = `-- name: DeleteDIDTypes :exec
+DELETE FROM did_types WHERE id = $1
+`
+
+func (q *Queries) DeleteDIDTypes(ctx context.Context, id []byte) error {
+	_, err := q.db.Exec(ctx, deleteDIDTypes, id)
+	return err
+}
+
+const deleteFailedRecord = `-- name: DeleteFailedRecord :exec
+DELETE FROM failed_records WHERE id = $1
+`
+
+func (q *Queries) DeleteFailedRecord(ctx context.Context, id []byte) error {
+	_, err := q.db.Exec(ctx, deleteFailedRecord, id)
+	return err
+}
+
+const deleteOutboxRecord = `-- name: DeleteOutboxRecord :exec
+DELETE FROM outbox WHERE id = $1 AND seq <= $2
+`
+
+type DeleteOutboxRecordParams struct {
+	ID  []byte
+	Seq int64
+}
+
+func (q *Queries) DeleteOutboxRecord(ctx context.Context, arg DeleteOutboxRecordParams) error {
+	_, err := q.db.Exec(ctx, deleteOutboxRecord, arg.ID, arg.Seq)
+	return err
+}
+
+const deleteRecord = `-- name: DeleteRecord :exec
+DELETE FROM dht_records WHERE key = $1
+`
+
+func (q *Queries) DeleteRecord(ctx context.Context, key []byte) error {
+	_, err := q.db.Exec(ctx, deleteRecord, key)
+	return err
+}
+
+const deleteRecordHistory = `-- name: DeleteRecordHistory :exec
+DELETE FROM dht_record_history WHERE key = $1
+`
+
+func (q *Queries) DeleteRecordHistory(ctx context.Context, key []byte) error {
+	_, err := q.db.Exec(ctx, deleteRecordHistory, key)
+	return err
+}
+
+const deleteRepublishSchedule = `-- name: DeleteRepublishSchedule :exec
+DELETE FROM republish_schedule WHERE id = $1
+`
+
+func (q *Queries) DeleteRepublishSchedule(ctx context.Context, id []byte) error {
+	_, err := q.db.Exec(ctx, deleteRepublishSchedule, id)
+	return err
+}
+
+const deleteRetainedRecord = `-- name: DeleteRetainedRecord :exec
+DELETE FROM retained_records WHERE id = $1
+`
+
+func (q *Queries) DeleteRetainedRecord(ctx context.Context, id []byte) error {
+	_, err := q.db.Exec(ctx, deleteRetainedRecord, id)
+	return err
+}
+
+const deleteTombstone = `-- name: DeleteTombstone :exec
+DELETE FROM tombstones WHERE id = $1
+`
+
+func (q *Queries) DeleteTombstone(ctx context.Context, id []byte) error {
+	_, err := q.db.Exec(ctx, deleteTombstone, id)
+	return err
+}
+
 const failedRecordCount = `-- name: FailedRecordCount :one
 SELECT count(*) AS exact_count FROM failed_records
 `
@@ -20,8 +97,38 @@ func (q *Queries) FailedRecordCount(ctx context.Context) (int64, error) {
 	return exact_count, err
 }
 
+const listDIDsForType = `-- name: ListDIDsForType :many
+SELECT id FROM did_types WHERE type = $1 ORDER BY id ASC LIMIT $2 OFFSET $3
+`
+
+type ListDIDsForTypeParams struct {
+	Type   int32
+	Limit  int32
+	Offset int32
+}
+
+func (q *Queries) ListDIDsForType(ctx context.Context, arg ListDIDsForTypeParams) ([][]byte, error) {
+	rows, err := q.db.Query(ctx, listDIDsForType, arg.Type, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items [][]byte
+	for rows.Next() {
+		var id []byte
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listFailedRecords = `-- name: ListFailedRecords :many
-SELECT id, failure_count FROM failed_records
+SELECT id, failure_count, last_error, first_failure, last_failure, next_attempt, dead_lettered FROM failed_records
 `
 
 func (q *Queries) ListFailedRecords(ctx context.Context) ([]FailedRecord, error) {
@@ -33,7 +140,39 @@ func (q *Queries) ListFailedRecords(ctx context.Context) ([]FailedRecord, error)
 	var items []FailedRecord
 	for rows.Next() {
 		var i FailedRecord
-		if err := rows.Scan(&i.ID, &i.FailureCount); err != nil {
+		if err := rows.Scan(
+			&i.ID,
+			&i.FailureCount,
+			&i.LastError,
+			&i.FirstFailure,
+			&i.LastFailure,
+			&i.NextAttempt,
+			&i.DeadLettered,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listOutbox = `-- name: ListOutbox :many
+SELECT id, seq, queued FROM outbox ORDER BY queued ASC
+`
+
+func (q *Queries) ListOutbox(ctx context.Context) ([]Outbox, error) {
+	rows, err := q.db.Query(ctx, listOutbox)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Outbox
+	for rows.Next() {
+		var i Outbox
+		if err := rows.Scan(&i.ID, &i.Seq, &i.Queued); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -109,6 +248,172 @@ func (q *Queries) ListRecordsFirstPage(ctx context.Context, limit int32) ([]DhtR
 	return items, nil
 }
 
+const listSequenceNumbers = `-- name: ListSequenceNumbers :many
+SELECT seq FROM dht_record_history WHERE key = $1 ORDER BY seq ASC
+`
+
+func (q *Queries) ListSequenceNumbers(ctx context.Context, key []byte) ([]int64, error) {
+	rows, err := q.db.Query(ctx, listSequenceNumbers, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int64
+	for rows.Next() {
+		var seq int64
+		if err := rows.Scan(&seq); err != nil {
+			return nil, err
+		}
+		items = append(items, seq)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listRepublishSchedule = `-- name: ListRepublishSchedule :many
+SELECT id, due FROM republish_schedule
+`
+
+func (q *Queries) ListRepublishSchedule(ctx context.Context) ([]RepublishSchedule, error) {
+	rows, err := q.db.Query(ctx, listRepublishSchedule)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []RepublishSchedule
+	for rows.Next() {
+		var i RepublishSchedule
+		if err := rows.Scan(&i.ID, &i.Due); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listRetainedRecords = `-- name: ListRetainedRecords :many
+SELECT id, expiry FROM retained_records
+`
+
+func (q *Queries) ListRetainedRecords(ctx context.Context) ([]RetainedRecord, error) {
+	rows, err := q.db.Query(ctx, listRetainedRecords)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []RetainedRecord
+	for rows.Next() {
+		var i RetainedRecord
+		if err := rows.Scan(&i.ID, &i.Expiry); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTombstones = `-- name: ListTombstones :many
+SELECT id, deleted, expiry FROM tombstones
+`
+
+func (q *Queries) ListTombstones(ctx context.Context) ([]Tombstone, error) {
+	rows, err := q.db.Query(ctx, listTombstones)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Tombstone
+	for rows.Next() {
+		var i Tombstone
+		if err := rows.Scan(&i.ID, &i.Deleted, &i.Expiry); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const readDIDTypes = `-- name: ReadDIDTypes :many
+SELECT type FROM did_types WHERE id = $1 ORDER BY type ASC
+`
+
+func (q *Queries) ReadDIDTypes(ctx context.Context, id []byte) ([]int32, error) {
+	rows, err := q.db.Query(ctx, readDIDTypes, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int32
+	for rows.Next() {
+		var type_ int32
+		if err := rows.Scan(&type_); err != nil {
+			return nil, err
+		}
+		items = append(items, type_)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const pruneHistory = `-- name: PruneHistory :exec
+DELETE FROM dht_record_history WHERE dht_record_history.key = $1 AND seq NOT IN (
+    SELECT h.seq FROM dht_record_history h WHERE h.key = $1 ORDER BY h.seq DESC LIMIT $2
+)
+`
+
+type PruneHistoryParams struct {
+	Key   []byte
+	Limit int32
+}
+
+func (q *Queries) PruneHistory(ctx context.Context, arg PruneHistoryParams) error {
+	_, err := q.db.Exec(ctx, pruneHistory, arg.Key, arg.Limit)
+	return err
+}
+
+const readFailedRecord = `-- name: ReadFailedRecord :one
+SELECT id, failure_count, last_error, first_failure, last_failure, next_attempt, dead_lettered FROM failed_records WHERE id = $1 LIMIT 1
+`
+
+func (q *Queries) ReadFailedRecord(ctx context.Context, id []byte) (FailedRecord, error) {
+	row := q.db.QueryRow(ctx, readFailedRecord, id)
+	var i FailedRecord
+	err := row.Scan(
+		&i.ID,
+		&i.FailureCount,
+		&i.LastError,
+		&i.FirstFailure,
+		&i.LastFailure,
+		&i.NextAttempt,
+		&i.DeadLettered,
+	)
+	return i, err
+}
+
+const readOutboxRecord = `-- name: ReadOutboxRecord :one
+SELECT id, seq, queued FROM outbox WHERE id = $1 LIMIT 1
+`
+
+func (q *Queries) ReadOutboxRecord(ctx context.Context, id []byte) (Outbox, error) {
+	row := q.db.QueryRow(ctx, readOutboxRecord, id)
+	var i Outbox
+	err := row.Scan(&i.ID, &i.Seq, &i.Queued)
+	return i, err
+}
+
 const readRecord = `-- name: ReadRecord :one
 SELECT id, key, value, sig, seq FROM dht_records WHERE key = $1 LIMIT 1
 `
@@ -126,6 +431,49 @@ func (q *Queries) ReadRecord(ctx context.Context, key []byte) (DhtRecord, error)
 	return i, err
 }
 
+const readRecordAtSequence = `-- name: ReadRecordAtSequence :one
+SELECT key, seq, value, sig FROM dht_record_history WHERE key = $1 AND seq = $2 LIMIT 1
+`
+
+type ReadRecordAtSequenceParams struct {
+	Key []byte
+	Seq int64
+}
+
+func (q *Queries) ReadRecordAtSequence(ctx context.Context, arg ReadRecordAtSequenceParams) (DhtRecordHistory, error) {
+	row := q.db.QueryRow(ctx, readRecordAtSequence, arg.Key, arg.Seq)
+	var i DhtRecordHistory
+	err := row.Scan(
+		&i.Key,
+		&i.Seq,
+		&i.Value,
+		&i.Sig,
+	)
+	return i, err
+}
+
+const readRetainedRecord = `-- name: ReadRetainedRecord :one
+SELECT id, expiry FROM retained_records WHERE id = $1 LIMIT 1
+`
+
+func (q *Queries) ReadRetainedRecord(ctx context.Context, id []byte) (RetainedRecord, error) {
+	row := q.db.QueryRow(ctx, readRetainedRecord, id)
+	var i RetainedRecord
+	err := row.Scan(&i.ID, &i.Expiry)
+	return i, err
+}
+
+const readTombstone = `-- name: ReadTombstone :one
+SELECT id, deleted, expiry FROM tombstones WHERE id = $1 LIMIT 1
+`
+
+func (q *Queries) ReadTombstone(ctx context.Context, id []byte) (Tombstone, error) {
+	row := q.db.QueryRow(ctx, readTombstone, id)
+	var i Tombstone
+	err := row.Scan(&i.ID, &i.Deleted, &i.Expiry)
+	return i, err
+}
+
 const recordCount = `-- name: RecordCount :one
 SELECT count(*) AS exact_count FROM dht_records
 `
@@ -137,24 +485,73 @@ func (q *Queries) RecordCount(ctx context.Context) (int64, error) {
 	return exact_count, err
 }
 
+const writeDIDType = `-- name: WriteDIDType :exec
+INSERT INTO did_types(id, type) VALUES($1, $2) ON CONFLICT DO NOTHING
+`
+
+type WriteDIDTypeParams struct {
+	ID   []byte
+	Type int32
+}
+
+func (q *Queries) WriteDIDType(ctx context.Context, arg WriteDIDTypeParams) error {
+	_, err := q.db.Exec(ctx, writeDIDType, arg.ID, arg.Type)
+	return err
+}
+
 const writeFailedRecord = `-- name: WriteFailedRecord :exec
-INSERT INTO failed_records(id, failure_count)
-VALUES($1, $2)
-ON CONFLICT (id) DO UPDATE SET failure_count = failed_records.failure_count + 1
+INSERT INTO failed_records(id, failure_count, last_error, first_failure, last_failure, next_attempt, dead_lettered)
+VALUES($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (id) DO UPDATE SET failure_count = EXCLUDED.failure_count, last_error = EXCLUDED.last_error,
+first_failure = EXCLUDED.first_failure, last_failure = EXCLUDED.last_failure, next_attempt = EXCLUDED.next_attempt,
+dead_lettered = EXCLUDED.dead_lettered
 `
 
 type WriteFailedRecordParams struct {
 	ID           []byte
 	FailureCount int32
+	LastError    string
+	FirstFailure int64
+	LastFailure  int64
+	NextAttempt  int64
+	DeadLettered bool
 }
 
 func (q *Queries) WriteFailedRecord(ctx context.Context, arg WriteFailedRecordParams) error {
-	_, err := q.db.Exec(ctx, writeFailedRecord, arg.ID, arg.FailureCount)
+	_, err := q.db.Exec(ctx, writeFailedRecord,
+		arg.ID,
+		arg.FailureCount,
+		arg.LastError,
+		arg.FirstFailure,
+		arg.LastFailure,
+		arg.NextAttempt,
+		arg.DeadLettered,
+	)
+	return err
+}
+
+const writeOutboxRecord = `-- name: WriteOutboxRecord :exec
+INSERT INTO outbox(id, seq, queued)
+VALUES($1, $2, $3)
+ON CONFLICT (id) DO UPDATE SET seq = EXCLUDED.seq, queued = EXCLUDED.queued
+WHERE outbox.seq <= EXCLUDED.seq
+`
+
+type WriteOutboxRecordParams struct {
+	ID     []byte
+	Seq    int64
+	Queued int64
+}
+
+func (q *Queries) WriteOutboxRecord(ctx context.Context, arg WriteOutboxRecordParams) error {
+	_, err := q.db.Exec(ctx, writeOutboxRecord, arg.ID, arg.Seq, arg.Queued)
 	return err
 }
 
 const writeRecord = `-- name: WriteRecord :exec
 INSERT INTO dht_records(key, value, sig, seq) VALUES($1, $2, $3, $4)
+ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, sig = EXCLUDED.sig, seq = EXCLUDED.seq
+WHERE dht_records.seq <= EXCLUDED.seq
 `
 
 type WriteRecordParams struct {
@@ -173,3 +570,74 @@ func (q *Queries) WriteRecord(ctx context.Context, arg WriteRecordParams) error
 	)
 	return err
 }
+
+const writeRecordHistory = `-- name: WriteRecordHistory :exec
+INSERT INTO dht_record_history(key, seq, value, sig) VALUES($1, $2, $3, $4)
+ON CONFLICT (key, seq) DO UPDATE SET value = EXCLUDED.value, sig = EXCLUDED.sig
+`
+
+type WriteRecordHistoryParams struct {
+	Key   []byte
+	Seq   int64
+	Value []byte
+	Sig   []byte
+}
+
+func (q *Queries) WriteRecordHistory(ctx context.Context, arg WriteRecordHistoryParams) error {
+	_, err := q.db.Exec(ctx, writeRecordHistory,
+		arg.Key,
+		arg.Seq,
+		arg.Value,
+		arg.Sig,
+	)
+	return err
+}
+
+const writeRepublishSchedule = `-- name: WriteRepublishSchedule :exec
+INSERT INTO republish_schedule(id, due)
+VALUES($1, $2)
+ON CONFLICT (id) DO UPDATE SET due = EXCLUDED.due
+`
+
+type WriteRepublishScheduleParams struct {
+	ID  []byte
+	Due int64
+}
+
+func (q *Queries) WriteRepublishSchedule(ctx context.Context, arg WriteRepublishScheduleParams) error {
+	_, err := q.db.Exec(ctx, writeRepublishSchedule, arg.ID, arg.Due)
+	return err
+}
+
+const writeRetainedRecord = `-- name: WriteRetainedRecord :exec
+INSERT INTO retained_records(id, expiry)
+VALUES($1, $2)
+ON CONFLICT (id) DO UPDATE SET expiry = EXCLUDED.expiry
+`
+
+type WriteRetainedRecordParams struct {
+	ID     []byte
+	Expiry int64
+}
+
+func (q *Queries) WriteRetainedRecord(ctx context.Context, arg WriteRetainedRecordParams) error {
+	_, err := q.db.Exec(ctx, writeRetainedRecord, arg.ID, arg.Expiry)
+	return err
+}
+
+const writeTombstone = `-- name: WriteTombstone :exec
+INSERT INTO tombstones(id, deleted, expiry)
+VALUES($1, $2, $3)
+ON CONFLICT (id) DO UPDATE SET deleted = EXCLUDED.deleted, expiry = EXCLUDED.expiry
+`
+
+type WriteTombstoneParams struct {
+	ID      []byte
+	Deleted int64
+	Expiry  int64
+}
+
+func (q *Queries) WriteTombstone(ctx context.Context, arg WriteTombstoneParams) error {
+	_, err := q.db.Exec(ctx, writeTombstone, arg.ID, arg.Deleted, arg.Expiry)
+	return err
+}
@@ -1,5 +1,7 @@
 -- name: WriteRecord :exec
-INSERT INTO dht_records(key, value, sig, seq) VALUES($1, $2, $3, $4);
+INSERT INTO dht_records(key, value, sig, seq) VALUES($1, $2, $3, $4)
+ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, sig = EXCLUDED.sig, seq = EXCLUDED.seq
+WHERE dht_records.seq <= EXCLUDED.seq;
 
 -- name: ReadRecord :one
 SELECT * FROM dht_records WHERE key = $1 LIMIT 1;
@@ -10,16 +12,111 @@ SELECT * FROM dht_records WHERE id > (SELECT id FROM dht_records WHERE dht_recor
 -- name: ListRecordsFirstPage :many
 SELECT * FROM dht_records ORDER BY id ASC LIMIT $1;
 
+-- name: DeleteRecord :exec
+DELETE FROM dht_records WHERE key = $1;
+
 -- name: RecordCount :one
 SELECT count(*) AS exact_count FROM dht_records;
 
 -- name: WriteFailedRecord :exec
-INSERT INTO failed_records(id, failure_count)
-VALUES($1, $2)
-ON CONFLICT (id) DO UPDATE SET failure_count = failed_records.failure_count + 1;
+INSERT INTO failed_records(id, failure_count, last_error, first_failure, last_failure, next_attempt, dead_lettered)
+VALUES($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (id) DO UPDATE SET failure_count = EXCLUDED.failure_count, last_error = EXCLUDED.last_error,
+first_failure = EXCLUDED.first_failure, last_failure = EXCLUDED.last_failure, next_attempt = EXCLUDED.next_attempt,
+dead_lettered = EXCLUDED.dead_lettered;
+
+-- name: ReadFailedRecord :one
+SELECT * FROM failed_records WHERE id = $1 LIMIT 1;
 
 -- name: ListFailedRecords :many
 SELECT * FROM failed_records;
 
+-- name: DeleteFailedRecord :exec
+DELETE FROM failed_records WHERE id = $1;
+
 -- name: FailedRecordCount :one
-SELECT count(*) AS exact_count FROM failed_records;
\ No newline at end of file
+SELECT count(*) AS exact_count FROM failed_records;
+
+-- name: WriteRetainedRecord :exec
+INSERT INTO retained_records(id, expiry)
+VALUES($1, $2)
+ON CONFLICT (id) DO UPDATE SET expiry = EXCLUDED.expiry;
+
+-- name: ReadRetainedRecord :one
+SELECT * FROM retained_records WHERE id = $1 LIMIT 1;
+
+-- name: ListRetainedRecords :many
+SELECT * FROM retained_records;
+
+-- name: DeleteRetainedRecord :exec
+DELETE FROM retained_records WHERE id = $1;
+
+-- name: DeleteDIDTypes :exec
+DELETE FROM did_types WHERE id = $1;
+
+-- name: WriteDIDType :exec
+INSERT INTO did_types(id, type) VALUES($1, $2) ON CONFLICT DO NOTHING;
+
+-- name: ReadDIDTypes :many
+SELECT type FROM did_types WHERE id = $1 ORDER BY type ASC;
+
+-- name: ListDIDsForType :many
+SELECT id FROM did_types WHERE type = $1 ORDER BY id ASC LIMIT $2 OFFSET $3;
+
+-- name: WriteRecordHistory :exec
+INSERT INTO dht_record_history(key, seq, value, sig) VALUES($1, $2, $3, $4)
+ON CONFLICT (key, seq) DO UPDATE SET value = EXCLUDED.value, sig = EXCLUDED.sig;
+
+-- name: ReadRecordAtSequence :one
+SELECT * FROM dht_record_history WHERE key = $1 AND seq = $2 LIMIT 1;
+
+-- name: ListSequenceNumbers :many
+SELECT seq FROM dht_record_history WHERE key = $1 ORDER BY seq ASC;
+
+-- name: PruneHistory :exec
+DELETE FROM dht_record_history WHERE dht_record_history.key = $1 AND seq NOT IN (
+    SELECT h.seq FROM dht_record_history h WHERE h.key = $1 ORDER BY h.seq DESC LIMIT $2
+);
+
+-- name: DeleteRecordHistory :exec
+DELETE FROM dht_record_history WHERE key = $1;
+
+-- name: WriteRepublishSchedule :exec
+INSERT INTO republish_schedule(id, due)
+VALUES($1, $2)
+ON CONFLICT (id) DO UPDATE SET due = EXCLUDED.due;
+
+-- name: ListRepublishSchedule :many
+SELECT * FROM republish_schedule;
+
+-- name: DeleteRepublishSchedule :exec
+DELETE FROM republish_schedule WHERE id = $1;
+
+-- name: WriteOutboxRecord :exec
+INSERT INTO outbox(id, seq, queued)
+VALUES($1, $2, $3)
+ON CONFLICT (id) DO UPDATE SET seq = EXCLUDED.seq, queued = EXCLUDED.queued
+WHERE outbox.seq <= EXCLUDED.seq;
+
+-- name: ReadOutboxRecord :one
+SELECT * FROM outbox WHERE id = $1 LIMIT 1;
+
+-- name: ListOutbox :many
+SELECT * FROM outbox ORDER BY queued ASC;
+
+-- name: DeleteOutboxRecord :exec
+DELETE FROM outbox WHERE id = $1 AND seq <= $2;
+
+-- name: WriteTombstone :exec
+INSERT INTO tombstones(id, deleted, expiry)
+VALUES($1, $2, $3)
+ON CONFLICT (id) DO UPDATE SET deleted = EXCLUDED.deleted, expiry = EXCLUDED.expiry;
+
+-- name: ReadTombstone :one
+SELECT * FROM tombstones WHERE id = $1 LIMIT 1;
+
+-- name: ListTombstones :many
+SELECT * FROM tombstones;
+
+-- name: DeleteTombstone :exec
+DELETE FROM tombstones WHERE id = $1;
@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/TBD54566975/did-dht/internal/did"
+	"github.com/TBD54566975/did-dht/pkg/dht"
+	"github.com/TBD54566975/did-dht/pkg/storage/db/bolt"
+	"github.com/TBD54566975/did-dht/pkg/storage/db/postgres"
+	"github.com/TBD54566975/did-dht/pkg/telemetry"
+)
+
+// instrumented is a Storage which records the latency and outcome of each operation of the wrapped backend
+type instrumented struct {
+	db      Storage
+	backend string
+}
+
+// WithMetrics returns the given storage with the latency and outcome of each operation recorded, labeled with its
+// backend
+func WithMetrics(db Storage) Storage {
+	backend := "unknown"
+	switch db.(type) {
+	case *bolt.Bolt:
+		backend = "bolt"
+	case postgres.Postgres:
+		backend = "postgres"
+	}
+	return instrumented{db: db, backend: backend}
+}
+
+func (i instrumented) observe(ctx context.Context, operation string, start time.Time, err *error) {
+	telemetry.RecordStorageOperation(ctx, i.backend, operation, time.Since(start), *err)
+}
+
+func (i instrumented) WriteRecord(ctx context.Context, record dht.BEP44Record) (err error) {
+	defer i.observe(ctx, "WriteRecord", time.Now(), &err)
+	return i.db.WriteRecord(ctx, record)
+}
+
+func (i instrumented) ReadRecord(ctx context.Context, id string) (record *dht.BEP44Record, err error) {
+	defer i.observe(ctx, "ReadRecord", time.Now(), &err)
+	return i.db.ReadRecord(ctx, id)
+}
+
+func (i instrumented) DeleteRecord(ctx context.Context, id string) (err error) {
+	defer i.observe(ctx, "DeleteRecord", time.Now(), &err)
+	return i.db.DeleteRecord(ctx, id)
+}
+
+func (i instrumented) ListRecords(ctx context.Context, nextPageToken []byte, pageSize int) (records []dht.BEP44Record, nextPage []byte, err error) {
+	defer i.observe(ctx, "ListRecords", time.Now(), &err)
+	return i.db.ListRecords(ctx, nextPageToken, pageSize)
+}
+
+func (i instrumented) RecordCount(ctx context.Context) (count int, err error) {
+	defer i.observe(ctx, "RecordCount", time.Now(), &err)
+	return i.db.RecordCount(ctx)
+}
+
+func (i instrumented) ReadRecordAtSequence(ctx context.Context, id string, seq int64) (record *dht.BEP44Record, err error) {
+	defer i.observe(ctx, "ReadRecordAtSequence", time.Now(), &err)
+	return i.db.ReadRecordAtSequence(ctx, id, seq)
+}
+
+func (i instrumented) ListSequenceNumbers(ctx context.Context, id string) (seqs []int64, err error) {
+	defer i.observe(ctx, "ListSequenceNumbers", time.Now(), &err)
+	return i.db.ListSequenceNumbers(ctx, id)
+}
+
+func (i instrumented) PruneHistory(ctx context.Context, id string, keep int) (err error) {
+	defer i.observe(ctx, "PruneHistory", time.Now(), &err)
+	return i.db.PruneHistory(ctx, id, keep)
+}
+
+func (i instrumented) WriteRecordWithOutbox(ctx context.Context, record dht.BEP44Record) (err error) {
+	defer i.observe(ctx, "WriteRecordWithOutbox", time.Now(), &err)
+	return i.db.WriteRecordWithOutbox(ctx, record)
+}
+
+func (i instrumented) WriteOutboxRecord(ctx context.Context, record dht.OutboxRecord) (err error) {
+	defer i.observe(ctx, "WriteOutboxRecord", time.Now(), &err)
+	return i.db.WriteOutboxRecord(ctx, record)
+}
+
+func (i instrumented) ReadOutboxRecord(ctx context.Context, id string) (record *dht.OutboxRecord, err error) {
+	defer i.observe(ctx, "ReadOutboxRecord", time.Now(), &err)
+	return i.db.ReadOutboxRecord(ctx, id)
+}
+
+func (i instrumented) ListOutbox(ctx context.Context) (records []dht.OutboxRecord, err error) {
+	defer i.observe(ctx, "ListOutbox", time.Now(), &err)
+	return i.db.ListOutbox(ctx)
+}
+
+func (i instrumented) DeleteOutboxRecord(ctx context.Context, id string, seq int64) (err error) {
+	defer i.observe(ctx, "DeleteOutboxRecord", time.Now(), &err)
+	return i.db.DeleteOutboxRecord(ctx, id, seq)
+}
+
+func (i instrumented) WriteFailedRecord(ctx context.Context, record dht.FailedRecord) (err error) {
+	defer i.observe(ctx, "WriteFailedRecord", time.Now(), &err)
+	return i.db.WriteFailedRecord(ctx, record)
+}
+
+func (i instrumented) ReadFailedRecord(ctx context.Context, id string) (record *dht.FailedRecord, err error) {
+	defer i.observe(ctx, "ReadFailedRecord", time.Now(), &err)
+	return i.db.ReadFailedRecord(ctx, id)
+}
+
+func (i instrumented) ListFailedRecords(ctx context.Context) (records []dht.FailedRecord, err error) {
+	defer i.observe(ctx, "ListFailedRecords", time.Now(), &err)
+	return i.db.ListFailedRecords(ctx)
+}
+
+func (i instrumented) DeleteFailedRecord(ctx context.Context, id string) (err error) {
+	defer i.observe(ctx, "DeleteFailedRecord", time.Now(), &err)
+	return i.db.DeleteFailedRecord(ctx, id)
+}
+
+func (i instrumented) FailedRecordCount(ctx context.Context) (count int, err error) {
+	defer i.observe(ctx, "FailedRecordCount", time.Now(), &err)
+	return i.db.FailedRecordCount(ctx)
+}
+
+func (i instrumented) WriteRetainedRecord(ctx context.Context, record dht.RetainedRecord) (err error) {
+	defer i.observe(ctx, "WriteRetainedRecord", time.Now(), &err)
+	return i.db.WriteRetainedRecord(ctx, record)
+}
+
+func (i instrumented) ReadRetainedRecord(ctx context.Context, id string) (record *dht.RetainedRecord, err error) {
+	defer i.observe(ctx, "ReadRetainedRecord", time.Now(), &err)
+	return i.db.ReadRetainedRecord(ctx, id)
+}
+
+func (i instrumented) ListRetainedRecords(ctx context.Context) (records []dht.RetainedRecord, err error) {
+	defer i.observe(ctx, "ListRetainedRecords", time.Now(), &err)
+	return i.db.ListRetainedRecords(ctx)
+}
+
+func (i instrumented) DeleteRetainedRecord(ctx context.Context, id string) (err error) {
+	defer i.observe(ctx, "DeleteRetainedRecord", time.Now(), &err)
+	return i.db.DeleteRetainedRecord(ctx, id)
+}
+
+func (i instrumented) WriteRepublishSchedule(ctx context.Context, record dht.ScheduledRecord) (err error) {
+	defer i.observe(ctx, "WriteRepublishSchedule", time.Now(), &err)
+	return i.db.WriteRepublishSchedule(ctx, record)
+}
+
+func (i instrumented) ListRepublishSchedule(ctx context.Context) (records []dht.ScheduledRecord, err error) {
+	defer i.observe(ctx, "ListRepublishSchedule", time.Now(), &err)
+	return i.db.ListRepublishSchedule(ctx)
+}
+
+func (i instrumented) DeleteRepublishSchedule(ctx context.Context, id string) (err error) {
+	defer i.observe(ctx, "DeleteRepublishSchedule", time.Now(), &err)
+	return i.db.DeleteRepublishSchedule(ctx, id)
+}
+
+func (i instrumented) WriteTombstone(ctx context.Context, tombstone dht.Tombstone) (err error) {
+	defer i.observe(ctx, "WriteTombstone", time.Now(), &err)
+	return i.db.WriteTombstone(ctx, tombstone)
+}
+
+func (i instrumented) ReadTombstone(ctx context.Context, id string) (tombstone *dht.Tombstone, err error) {
+	defer i.observe(ctx, "ReadTombstone", time.Now(), &err)
+	return i.db.ReadTombstone(ctx, id)
+}
+
+func (i instrumented) ListTombstones(ctx context.Context) (tombstones []dht.Tombstone, err error) {
+	defer i.observe(ctx, "ListTombstones", time.Now(), &err)
+	return i.db.ListTombstones(ctx)
+}
+
+func (i instrumented) DeleteTombstone(ctx context.Context, id string) (err error) {
+	defer i.observe(ctx, "DeleteTombstone", time.Now(), &err)
+	return i.db.DeleteTombstone(ctx, id)
+}
+
+func (i instrumented) WriteDIDTypes(ctx context.Context, id string, types []did.TypeIndex) (err error) {
+	defer i.observe(ctx, "WriteDIDTypes", time.Now(), &err)
+	return i.db.WriteDIDTypes(ctx, id, types)
+}
+
+func (i instrumented) ReadDIDTypes(ctx context.Context, id string) (types []did.TypeIndex, err error) {
+	defer i.observe(ctx, "ReadDIDTypes", time.Now(), &err)
+	return i.db.ReadDIDTypes(ctx, id)
+}
+
+func (i instrumented) ListDIDsForType(ctx context.Context, typeIndex did.TypeIndex, offset, limit int) (ids []string, err error) {
+	defer i.observe(ctx, "ListDIDsForType", time.Now(), &err)
+	return i.db.ListDIDsForType(ctx, typeIndex, offset, limit)
+}
+
+func (i instrumented) Close() error {
+	return i.db.Close()
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/sirupsen/logrus"
 
+	"github.com/TBD54566975/did-dht/internal/did"
 	"github.com/TBD54566975/did-dht/pkg/dht"
 	"github.com/TBD54566975/did-dht/pkg/storage/db/bolt"
 	"github.com/TBD54566975/did-dht/pkg/storage/db/postgres"
@@ -16,13 +17,44 @@ import (
 type Storage interface {
 	WriteRecord(ctx context.Context, record dht.BEP44Record) error
 	ReadRecord(ctx context.Context, id string) (*dht.BEP44Record, error)
+	DeleteRecord(ctx context.Context, id string) error
 	ListRecords(ctx context.Context, nextPageToken []byte, pageSize int) (records []dht.BEP44Record, nextPage []byte, err error)
 	RecordCount(ctx context.Context) (int, error)
 
-	WriteFailedRecord(ctx context.Context, id string) error
+	ReadRecordAtSequence(ctx context.Context, id string, seq int64) (*dht.BEP44Record, error)
+	ListSequenceNumbers(ctx context.Context, id string) ([]int64, error)
+	PruneHistory(ctx context.Context, id string, keep int) error
+
+	WriteRecordWithOutbox(ctx context.Context, record dht.BEP44Record) error
+	WriteOutboxRecord(ctx context.Context, record dht.OutboxRecord) error
+	ReadOutboxRecord(ctx context.Context, id string) (*dht.OutboxRecord, error)
+	ListOutbox(ctx context.Context) ([]dht.OutboxRecord, error)
+	DeleteOutboxRecord(ctx context.Context, id string, seq int64) error
+
+	WriteFailedRecord(ctx context.Context, record dht.FailedRecord) error
+	ReadFailedRecord(ctx context.Context, id string) (*dht.FailedRecord, error)
 	ListFailedRecords(ctx context.Context) ([]dht.FailedRecord, error)
+	DeleteFailedRecord(ctx context.Context, id string) error
 	FailedRecordCount(ctx context.Context) (int, error)
 
+	WriteRetainedRecord(ctx context.Context, record dht.RetainedRecord) error
+	ReadRetainedRecord(ctx context.Context, id string) (*dht.RetainedRecord, error)
+	ListRetainedRecords(ctx context.Context) ([]dht.RetainedRecord, error)
+	DeleteRetainedRecord(ctx context.Context, id string) error
+
+	WriteRepublishSchedule(ctx context.Context, record dht.ScheduledRecord) error
+	ListRepublishSchedule(ctx context.Context) ([]dht.ScheduledRecord, error)
+	DeleteRepublishSchedule(ctx context.Context, id string) error
+
+	WriteTombstone(ctx context.Context, tombstone dht.Tombstone) error
+	ReadTombstone(ctx context.Context, id string) (*dht.Tombstone, error)
+	ListTombstones(ctx context.Context) ([]dht.Tombstone, error)
+	DeleteTombstone(ctx context.Context, id string) error
+
+	WriteDIDTypes(ctx context.Context, id string, types []did.TypeIndex) error
+	ReadDIDTypes(ctx context.Context, id string) ([]did.TypeIndex, error)
+	ListDIDsForType(ctx context.Context, typeIndex did.TypeIndex, offset, limit int) ([]string, error)
+
 	Close() error
 }
 
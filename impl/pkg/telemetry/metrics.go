@@ -0,0 +1,217 @@
+package telemetry
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/TBD54566975/did-dht/config"
+)
+
+const (
+	// CacheRecords is the cache of resolved records
+	CacheRecords = "records"
+	// CacheBadGet is the cache of keys which recently failed to resolve
+	CacheBadGet = "bad_get"
+
+	// DHTOperationPut is a put of a record into the DHT
+	DHTOperationPut = "put"
+	// DHTOperationGet is a get of a record from the DHT
+	DHTOperationGet = "get"
+
+	// RepublishSuccess is a record which was put back into the DHT
+	RepublishSuccess = "success"
+	// RepublishFailure is a record which failed to be put back into the DHT
+	RepublishFailure = "failure"
+	// RepublishSkipped is a record which is no longer republished, such as a deleted record or deactivated DID
+	RepublishSkipped = "skipped"
+
+	// PublishStored is a published record which was stored and queued to be put into the DHT
+	PublishStored = "stored"
+	// PublishRefreshed is a republished copy of the stored record, which was only queued to be put into the DHT
+	PublishRefreshed = "refreshed"
+	// PublishUnchanged is a published record which matched the cached record
+	PublishUnchanged = "unchanged"
+	// PublishRejected is a published record which conflicted with the stored record
+	PublishRejected = "rejected"
+	// PublishRateLimited is a published record which was not stored because its record was updated too often
+	PublishRateLimited = "rate_limited"
+
+	// ResolvedFromCache is a record resolved from the record cache
+	ResolvedFromCache = "cache"
+	// ResolvedFromDHT is a record resolved from the DHT
+	ResolvedFromDHT = "dht"
+	// ResolvedFromStorage is a record which could not be resolved from the DHT, but was stored
+	ResolvedFromStorage = "storage"
+	// ResolvedNotFound is a record which could not be resolved
+	ResolvedNotFound = "not_found"
+)
+
+var (
+	// registry is the Prometheus registry served by MetricsHandler, which metrics are registered with when telemetry
+	// is set up with Prometheus enabled
+	registry = prometheus.NewRegistry()
+
+	// durationBuckets are the histogram boundaries for latencies in seconds, from a cached lookup to a DHT traversal
+	durationBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+	// batchBuckets are the histogram boundaries for the number of records republished at once
+	batchBuckets = []float64{1, 2, 5, 10, 25, 50, 100, 250, 500, 1000}
+
+	httpRequests         metric.Int64Counter
+	httpRequestDuration  metric.Float64Histogram
+	recordsPublished     metric.Int64Counter
+	recordsResolved      metric.Int64Counter
+	cacheLookups         metric.Int64Counter
+	dhtOperationDuration metric.Float64Histogram
+	republishRecords     metric.Int64Counter
+	republishBatchSize   metric.Int64Histogram
+	storageDuration      metric.Float64Histogram
+	routingTableNodes    metric.Int64ObservableGauge
+	routingTableGood     metric.Int64ObservableGauge
+)
+
+// instruments are created from the global meter provider, which forwards them to the provider installed by
+// SetupTelemetry, and records nothing until then
+func init() {
+	meter := otel.Meter(scopeName, metric.WithInstrumentationVersion(config.Version))
+
+	var err error
+	if httpRequests, err = meter.Int64Counter("http.server.requests",
+		metric.WithDescription("HTTP requests handled by the gateway")); err != nil {
+		logrus.WithError(err).Error("failed to create http requests counter")
+	}
+	if httpRequestDuration, err = meter.Float64Histogram("http.server.request.duration", metric.WithUnit("s"),
+		metric.WithDescription("Latency of HTTP requests handled by the gateway"),
+		metric.WithExplicitBucketBoundaries(durationBuckets...)); err != nil {
+		logrus.WithError(err).Error("failed to create http request duration histogram")
+	}
+	if recordsPublished, err = meter.Int64Counter("records.published",
+		metric.WithDescription("Records published to the gateway, by result")); err != nil {
+		logrus.WithError(err).Error("failed to create records published counter")
+	}
+	if recordsResolved, err = meter.Int64Counter("records.resolved",
+		metric.WithDescription("Records resolved by the gateway, by source")); err != nil {
+		logrus.WithError(err).Error("failed to create records resolved counter")
+	}
+	if cacheLookups, err = meter.Int64Counter("cache.lookups",
+		metric.WithDescription("Lookups of the record and bad get caches, by result")); err != nil {
+		logrus.WithError(err).Error("failed to create cache lookups counter")
+	}
+	if dhtOperationDuration, err = meter.Float64Histogram("dht.operation.duration", metric.WithUnit("s"),
+		metric.WithDescription("Latency of DHT puts and gets, by outcome"),
+		metric.WithExplicitBucketBoundaries(durationBuckets...)); err != nil {
+		logrus.WithError(err).Error("failed to create dht operation duration histogram")
+	}
+	if republishRecords, err = meter.Int64Counter("republish.records",
+		metric.WithDescription("Records due to be republished, by outcome")); err != nil {
+		logrus.WithError(err).Error("failed to create republish records counter")
+	}
+	if republishBatchSize, err = meter.Int64Histogram("republish.batch.size",
+		metric.WithDescription("Records which became due to be republished at once"),
+		metric.WithExplicitBucketBoundaries(batchBuckets...)); err != nil {
+		logrus.WithError(err).Error("failed to create republish batch size histogram")
+	}
+	if storageDuration, err = meter.Float64Histogram("storage.operation.duration", metric.WithUnit("s"),
+		metric.WithDescription("Latency of storage operations, by backend and outcome"),
+		metric.WithExplicitBucketBoundaries(durationBuckets...)); err != nil {
+		logrus.WithError(err).Error("failed to create storage operation duration histogram")
+	}
+	if routingTableNodes, err = meter.Int64ObservableGauge("dht.routing_table.nodes",
+		metric.WithDescription("Nodes in the DHT routing table")); err != nil {
+		logrus.WithError(err).Error("failed to create routing table nodes gauge")
+	}
+	if routingTableGood, err = meter.Int64ObservableGauge("dht.routing_table.good_nodes",
+		metric.WithDescription("Nodes in the DHT routing table which responded to their last query")); err != nil {
+		logrus.WithError(err).Error("failed to create routing table good nodes gauge")
+	}
+}
+
+// MetricsHandler returns the handler serving metrics in the Prometheus exposition format
+func MetricsHandler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// RecordHTTPRequest records a request handled by the gateway, labeled with its route rather than its path so that IDs
+// do not create a series each
+func RecordHTTPRequest(ctx context.Context, method, route string, status int, duration time.Duration) {
+	attrs := metric.WithAttributes(
+		attribute.String("method", method),
+		attribute.String("route", route),
+		attribute.String("status", strconv.Itoa(status)),
+	)
+	httpRequests.Add(ctx, 1, attrs)
+	httpRequestDuration.Record(ctx, duration.Seconds(), attrs)
+}
+
+// RecordPublish records the result of publishing a record to the gateway
+func RecordPublish(ctx context.Context, result string) {
+	recordsPublished.Add(ctx, 1, metric.WithAttributes(attribute.String("result", result)))
+}
+
+// RecordResolution records the source a record was resolved from
+func RecordResolution(ctx context.Context, source string) {
+	recordsResolved.Add(ctx, 1, metric.WithAttributes(attribute.String("source", source)))
+}
+
+// RecordCacheLookup records a hit or miss of the named cache
+func RecordCacheLookup(ctx context.Context, cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	cacheLookups.Add(ctx, 1, metric.WithAttributes(attribute.String("cache", cache), attribute.String("result", result)))
+}
+
+// RecordDHTOperation records the latency and outcome of a DHT put or get
+func RecordDHTOperation(ctx context.Context, operation string, duration time.Duration, err error) {
+	dhtOperationDuration.Record(ctx, duration.Seconds(), metric.WithAttributes(
+		attribute.String("operation", operation),
+		attribute.String("outcome", outcome(err)),
+	))
+}
+
+// RecordRepublish records the outcome of republishing a record
+func RecordRepublish(ctx context.Context, result string) {
+	republishRecords.Add(ctx, 1, metric.WithAttributes(attribute.String("outcome", result)))
+}
+
+// RecordRepublishBatch records the number of records which became due to be republished at once
+func RecordRepublishBatch(ctx context.Context, size int) {
+	republishBatchSize.Record(ctx, int64(size))
+}
+
+// RecordStorageOperation records the latency and outcome of an operation of the given storage backend
+func RecordStorageOperation(ctx context.Context, backend, operation string, duration time.Duration, err error) {
+	storageDuration.Record(ctx, duration.Seconds(), metric.WithAttributes(
+		attribute.String("backend", backend),
+		attribute.String("operation", operation),
+		attribute.String("outcome", outcome(err)),
+	))
+}
+
+// ObserveRoutingTable reports the size of a DHT routing table whenever metrics are collected, until the returned
+// registration is unregistered
+func ObserveRoutingTable(stats func() (nodes, goodNodes int)) (metric.Registration, error) {
+	meter := otel.Meter(scopeName, metric.WithInstrumentationVersion(config.Version))
+	return meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		nodes, goodNodes := stats()
+		o.ObserveInt64(routingTableNodes, int64(nodes))
+		o.ObserveInt64(routingTableGood, int64(goodNodes))
+		return nil
+	}, routingTableNodes, routingTableGood)
+}
+
+func outcome(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
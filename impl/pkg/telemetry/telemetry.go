@@ -2,13 +2,22 @@ package telemetry
 
 import (
 	"context"
+	"fmt"
+	"os"
+	"sort"
 	"time"
 
 	"github.com/sirupsen/logrus"
 	"go.opentelemetry.io/contrib/instrumentation/runtime"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	otelprometheus "go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
 	"go.opentelemetry.io/otel/propagation"
 	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/resource"
@@ -21,6 +30,15 @@ import (
 
 const (
 	scopeName = "github.com/TBD54566975/did-dht"
+
+	// ExporterOTLPHTTP exports traces and metrics to an OTLP collector over HTTP
+	ExporterOTLPHTTP = "otlp-http"
+	// ExporterOTLPGRPC exports traces and metrics to an OTLP collector over gRPC
+	ExporterOTLPGRPC = "otlp-grpc"
+	// ExporterStdout writes traces and metrics to stdout, for local debugging
+	ExporterStdout = "stdout"
+	// ExporterFile appends traces and metrics to a file, for local debugging
+	ExporterFile = "file"
 )
 
 var (
@@ -28,32 +46,53 @@ var (
 	traceProvider *sdktrace.TracerProvider
 	meterProvider *sdkmetric.MeterProvider
 	propagator    propagation.TextMapPropagator
+	// exportFile is the file written by the file exporter, which is closed on shutdown
+	exportFile *os.File
 )
 
-// SetupTelemetry initializes the OpenTelemetry SDK with the appropriate exporters and propagators.
-func SetupTelemetry(ctx context.Context) error {
-	r, err := resource.Merge(
-		resource.Default(),
-		resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(scopeName)),
-	)
+// SetupTelemetry initializes the OpenTelemetry SDK with the appropriate exporters and propagators. Traces and metrics
+// are exported as configured if telemetry is enabled, and metrics are served by MetricsHandler if metrics are enabled.
+func SetupTelemetry(ctx context.Context, cfg *config.Config) error {
+	r, err := newResource(cfg)
 	if err != nil {
 		return err
 	}
 
-	// setup tracing
-	traceExporter, err := otlptracehttp.New(ctx)
-	if err != nil {
-		return err
+	meterOpts := []sdkmetric.Option{sdkmetric.WithResource(r)}
+	if cfg.ServerConfig.Telemetry {
+		traceExporter, metricExporter, err := newExporters(ctx, cfg.TelemetryConfig)
+		if err != nil {
+			return err
+		}
+
+		// setup tracing
+		sampleRatio := 1.0
+		if cfg.TelemetryConfig.SampleRatio != nil {
+			sampleRatio = *cfg.TelemetryConfig.SampleRatio
+		}
+		traceProvider = sdktrace.NewTracerProvider(
+			sdktrace.WithBatcher(traceExporter),
+			sdktrace.WithResource(r),
+			sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+		)
+		otel.SetTracerProvider(traceProvider)
+
+		interval := time.Duration(cfg.TelemetryConfig.MetricIntervalSeconds) * time.Second
+		if interval <= 0 {
+			interval = time.Duration(config.GetDefaultConfig().TelemetryConfig.MetricIntervalSeconds) * time.Second
+		}
+		meterOpts = append(meterOpts, sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter, sdkmetric.WithInterval(interval))))
 	}
-	traceProvider = sdktrace.NewTracerProvider(sdktrace.WithBatcher(traceExporter), sdktrace.WithResource(r))
-	otel.SetTracerProvider(traceProvider)
 
 	// setup metrics
-	metricExporter, err := otlpmetrichttp.New(ctx)
-	if err != nil {
-		return err
+	if cfg.ServerConfig.Metrics {
+		promExporter, err := otelprometheus.New(otelprometheus.WithRegisterer(registry), otelprometheus.WithoutScopeInfo())
+		if err != nil {
+			return err
+		}
+		meterOpts = append(meterOpts, sdkmetric.WithReader(promExporter))
 	}
-	meterProvider = sdkmetric.NewMeterProvider(sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter)))
+	meterProvider = sdkmetric.NewMeterProvider(meterOpts...)
 	otel.SetMeterProvider(meterProvider)
 
 	// setup memory metrics
@@ -69,6 +108,104 @@ func SetupTelemetry(ctx context.Context) error {
 	return nil
 }
 
+// newResource returns the resource describing the gateway, with its service name, version, and environment, and the
+// configured resource attributes
+func newResource(cfg *config.Config) (*resource.Resource, error) {
+	attrs := []attribute.KeyValue{
+		semconv.ServiceName(config.ServiceName),
+		semconv.ServiceVersion(config.Version),
+		semconv.DeploymentEnvironment(string(cfg.ServerConfig.Environment)),
+	}
+	keys := make([]string, 0, len(cfg.TelemetryConfig.ResourceAttributes))
+	for key := range cfg.TelemetryConfig.ResourceAttributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		attrs = append(attrs, attribute.String(key, cfg.TelemetryConfig.ResourceAttributes[key]))
+	}
+	return resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, attrs...))
+}
+
+// newExporters returns the trace and metric exporters for the configured exporter. OTLP exporters fall back to the
+// standard OTEL_EXPORTER_OTLP_* environment variables for anything not configured.
+func newExporters(ctx context.Context, cfg config.TelemetryConfig) (sdktrace.SpanExporter, sdkmetric.Exporter, error) {
+	switch cfg.Exporter {
+	case ExporterOTLPHTTP, "":
+		var traceOpts []otlptracehttp.Option
+		var metricOpts []otlpmetrichttp.Option
+		if cfg.Endpoint != "" {
+			traceOpts = append(traceOpts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+			metricOpts = append(metricOpts, otlpmetrichttp.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			traceOpts = append(traceOpts, otlptracehttp.WithInsecure())
+			metricOpts = append(metricOpts, otlpmetrichttp.WithInsecure())
+		}
+		traceExporter, err := otlptracehttp.New(ctx, traceOpts...)
+		if err != nil {
+			return nil, nil, err
+		}
+		metricExporter, err := otlpmetrichttp.New(ctx, metricOpts...)
+		if err != nil {
+			return nil, nil, err
+		}
+		return traceExporter, metricExporter, nil
+	case ExporterOTLPGRPC:
+		var traceOpts []otlptracegrpc.Option
+		var metricOpts []otlpmetricgrpc.Option
+		if cfg.Endpoint != "" {
+			traceOpts = append(traceOpts, otlptracegrpc.WithEndpoint(cfg.Endpoint))
+			metricOpts = append(metricOpts, otlpmetricgrpc.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			traceOpts = append(traceOpts, otlptracegrpc.WithInsecure())
+			metricOpts = append(metricOpts, otlpmetricgrpc.WithInsecure())
+		}
+		traceExporter, err := otlptracegrpc.New(ctx, traceOpts...)
+		if err != nil {
+			return nil, nil, err
+		}
+		metricExporter, err := otlpmetricgrpc.New(ctx, metricOpts...)
+		if err != nil {
+			return nil, nil, err
+		}
+		return traceExporter, metricExporter, nil
+	case ExporterStdout:
+		traceExporter, err := stdouttrace.New(stdouttrace.WithPrettyPrint())
+		if err != nil {
+			return nil, nil, err
+		}
+		metricExporter, err := stdoutmetric.New(stdoutmetric.WithPrettyPrint())
+		if err != nil {
+			return nil, nil, err
+		}
+		return traceExporter, metricExporter, nil
+	case ExporterFile:
+		if cfg.FilePath == "" {
+			return nil, nil, fmt.Errorf("telemetry exporter %s requires a file path", cfg.Exporter)
+		}
+		f, err := os.OpenFile(cfg.FilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, nil, err
+		}
+		traceExporter, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			_ = f.Close()
+			return nil, nil, err
+		}
+		metricExporter, err := stdoutmetric.New(stdoutmetric.WithWriter(f))
+		if err != nil {
+			_ = f.Close()
+			return nil, nil, err
+		}
+		exportFile = f
+		return traceExporter, metricExporter, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported telemetry exporter: %s", cfg.Exporter)
+	}
+}
+
 // Shutdown stops the telemetry providers and exporters safely.
 func Shutdown(ctx context.Context) {
 	if traceProvider != nil {
@@ -82,6 +219,12 @@ func Shutdown(ctx context.Context) {
 			logrus.WithError(err).Error("error shutting down meter provider")
 		}
 	}
+
+	if exportFile != nil {
+		if err := exportFile.Close(); err != nil {
+			logrus.WithError(err).Error("error closing telemetry export file")
+		}
+	}
 }
 
 // GetTracer returns the tracer for the application. If the tracer is not yet initialized, it will be created.
@@ -0,0 +1,77 @@
+package telemetry
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/TBD54566975/did-dht/config"
+)
+
+func TestNewResource(t *testing.T) {
+	cfg := config.GetDefaultConfig()
+	cfg.TelemetryConfig.ResourceAttributes = map[string]string{"service.namespace": "did", "region": "us-east-1"}
+
+	r, err := newResource(&cfg)
+	require.NoError(t, err)
+
+	attrs := attribute.NewSet(r.Attributes()...)
+	for key, want := range map[attribute.Key]string{
+		"service.name":           config.ServiceName,
+		"service.version":        config.Version,
+		"deployment.environment": string(config.EnvironmentDev),
+		"service.namespace":      "did",
+		"region":                 "us-east-1",
+	} {
+		got, ok := attrs.Value(key)
+		assert.True(t, ok, key)
+		assert.Equal(t, want, got.AsString(), key)
+	}
+}
+
+func TestNewExporters(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("test otlp exporters", func(t *testing.T) {
+		for _, exporter := range []string{"", ExporterOTLPHTTP, ExporterOTLPGRPC} {
+			traceExporter, metricExporter, err := newExporters(ctx, config.TelemetryConfig{Exporter: exporter, Endpoint: "localhost:4317", Insecure: true})
+			require.NoError(t, err, exporter)
+			assert.NotNil(t, traceExporter)
+			assert.NotNil(t, metricExporter)
+			assert.NoError(t, traceExporter.Shutdown(ctx))
+			assert.NoError(t, metricExporter.Shutdown(ctx))
+		}
+	})
+
+	t.Run("test stdout exporters", func(t *testing.T) {
+		traceExporter, metricExporter, err := newExporters(ctx, config.TelemetryConfig{Exporter: ExporterStdout})
+		require.NoError(t, err)
+		assert.NotNil(t, traceExporter)
+		assert.NotNil(t, metricExporter)
+	})
+
+	t.Run("test file exporters", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "telemetry.json")
+		traceExporter, metricExporter, err := newExporters(ctx, config.TelemetryConfig{Exporter: ExporterFile, FilePath: path})
+		require.NoError(t, err)
+		assert.NotNil(t, traceExporter)
+		assert.NotNil(t, metricExporter)
+		assert.FileExists(t, path)
+
+		require.NotNil(t, exportFile)
+		assert.NoError(t, exportFile.Close())
+		exportFile = nil
+
+		_, _, err = newExporters(ctx, config.TelemetryConfig{Exporter: ExporterFile})
+		assert.ErrorContains(t, err, "requires a file path")
+	})
+
+	t.Run("test unsupported exporter", func(t *testing.T) {
+		_, _, err := newExporters(ctx, config.TelemetryConfig{Exporter: "zipkin"})
+		assert.ErrorContains(t, err, "unsupported telemetry exporter")
+	})
+}